@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ncbray/cmdline"
+	"github.com/ncbray/rommy/runtime"
+	"os"
+)
+
+// rommyc compat old_schema new_schema
+//
+// List the changes between two versions of a schema that matter to the binary
+// encoding.  Exits with an error if data written with the old version cannot
+// be read with the new version.
+func compatMain(args []string) {
+	inputFile := &cmdline.FilePath{
+		MustExist: true,
+	}
+
+	var old_file string
+	var new_file string
+	var diagnostics string
+
+	app := cmdline.MakeApp("rommyc compat")
+	app.Flags([]*cmdline.Flag{
+		{
+			Long:  "diagnostics",
+			Value: cmdline.String.Set(&diagnostics),
+		},
+	})
+	app.RequiredArgs([]*cmdline.Argument{
+		{
+			Name:  "old",
+			Value: inputFile.Set(&old_file),
+		},
+		{
+			Name:  "new",
+			Value: inputFile.Set(&new_file),
+		},
+	})
+	app.Run(args)
+
+	status := createStatus(diagnostics)
+	old_regions := loadSchema(old_file, status)
+	new_regions := loadSchema(new_file, status)
+
+	breaking := 0
+	for _, c := range runtime.CompareRegions(old_regions, new_regions) {
+		if c.Breaking {
+			breaking += 1
+			fmt.Println("BREAKING " + c.String())
+		} else {
+			fmt.Println("compatible " + c.String())
+		}
+	}
+	if breaking > 0 {
+		println(fmt.Sprintf("ERROR %d breaking changes, data written with %s cannot be read with %s", breaking, old_file, new_file))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"github.com/ncbray/cmdline"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"github.com/ncbray/rommy/schema"
+	"io/ioutil"
+	"os"
+)
+
+// Find the region data is written in.  A schema with a single region does not
+// need to name it.
+func selectRegion(regions []*runtime.RegionSchema, name string) *runtime.RegionSchema {
+	if name == "" {
+		if len(regions) != 1 {
+			println("ERROR the schema has more than one region, specify one with --region")
+			os.Exit(1)
+		}
+		return regions[0]
+	}
+	for _, r := range regions {
+		if r.Name == name {
+			return r
+		}
+	}
+	println("ERROR the schema does not have a region named " + name)
+	os.Exit(1)
+	return nil
+}
+
+// Load the regions declared in a schema file.
+func loadSchema(schema_file string, status *parser.Status) []*runtime.RegionSchema {
+	data, err := ioutil.ReadFile(schema_file)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+	regions, ok := schema.LoadSchema(schema_file, data, status)
+	if !ok || status.ShouldStop() {
+		os.Exit(1)
+	}
+	return regions
+}
+
+// Load a region from a schema file.  Data can be handled with the schema alone,
+// no generated code is needed.
+func loadRegion(schema_file string, region_name string, status *parser.Status) *runtime.RegionSchema {
+	return selectRegion(loadSchema(schema_file, status), region_name)
+}
+
+// rommyc compile schema data --out file
+//
+// Serialize a data file into the binary form read by the generated
+// UnmarshalBinary.  The data is checked against the constraints in the schema
+// first.
+func compileMain(args []string) {
+	inputFile := &cmdline.FilePath{
+		MustExist: true,
+	}
+	outputFile := &cmdline.FilePath{
+		MustExist: false,
+	}
+
+	var schema_file string
+	var data_file string
+	var out string
+	var region_name string
+	var diagnostics string
+
+	app := cmdline.MakeApp("rommyc compile")
+	app.Flags([]*cmdline.Flag{
+		{
+			Long:  "out",
+			Value: outputFile.Set(&out),
+		},
+		{
+			Long:  "region",
+			Value: cmdline.String.Set(&region_name),
+		},
+		{
+			Long:  "diagnostics",
+			Value: cmdline.String.Set(&diagnostics),
+		},
+	})
+	app.RequiredArgs([]*cmdline.Argument{
+		{
+			Name:  "schema",
+			Value: inputFile.Set(&schema_file),
+		},
+		{
+			Name:  "data",
+			Value: inputFile.Set(&data_file),
+		},
+	})
+	app.Run(args)
+
+	if out == "" {
+		println("ERROR no output specified for " + data_file)
+		os.Exit(1)
+	}
+
+	status := createStatus(diagnostics)
+	rs := loadRegion(schema_file, region_name, status)
+
+	data, err := ioutil.ReadFile(data_file)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+	region := runtime.CreateDynamicRegion(rs)
+	// Any error, even one a pass recovered from, means the data is wrong.
+	_, locations, ok := human.ParseProjectWithLocations(data_file, data, region, status)
+	if !ok || status.ShouldStop() {
+		os.Exit(1)
+	}
+	if !human.Validate(region, locations, status) {
+		os.Exit(1)
+	}
+
+	encoded, err := region.MarshalBinary()
+	if err != nil {
+		println("ERROR " + err.Error())
+		os.Exit(1)
+	}
+	err = ioutil.WriteFile(out, encoded, 0644)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ncbray/cmdline"
+	"github.com/ncbray/rommy/runtime"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func describeStruct(o *runtime.DynamicStruct) string {
+	return o.Schema().Name + ":" + strconv.Itoa(o.PoolIndex)
+}
+
+// Find the root named by the --root flag, either a type or a type and an index
+// in its pool, such as Node:2.  Otherwise the root is the only struct that is
+// not referenced by another struct.
+func selectRoot(region *runtime.DynamicRegion, root string) (*runtime.DynamicStruct, []*runtime.DynamicStruct) {
+	roots := region.Roots()
+	if root == "" {
+		if len(roots) == 1 {
+			return roots[0], nil
+		}
+		if len(roots) == 0 {
+			println("ERROR cannot detect the root, every struct is referenced, specify one with --root")
+		} else {
+			candidates := make([]string, len(roots))
+			for i, o := range roots {
+				candidates[i] = describeStruct(o)
+			}
+			println("ERROR cannot detect the root, could be " + strings.Join(candidates, ", ") + ", specify one with --root")
+		}
+		os.Exit(1)
+	}
+	name := root
+	index := 0
+	if i := strings.Index(root, ":"); i >= 0 {
+		name = root[:i]
+		var err error
+		index, err = strconv.Atoi(root[i+1:])
+		if err != nil {
+			println("ERROR invalid root " + root + ", expected a type and an index such as Node:0")
+			os.Exit(1)
+		}
+	}
+	s, ok := region.Schema().StructLUT[name]
+	if !ok {
+		println("ERROR the region does not have a struct named " + name)
+		os.Exit(1)
+	}
+	pool := region.Pool(s)
+	if index < 0 || index >= len(pool) {
+		println(fmt.Sprintf("ERROR cannot use %s as the root, there are %d structs of type %s", root, len(pool), name))
+		os.Exit(1)
+	}
+	o := pool[index]
+	others := []*runtime.DynamicStruct{}
+	for _, other := range roots {
+		if other != o {
+			others = append(others, other)
+		}
+	}
+	return o, others
+}
+
+// rommyc decompile schema binary [--out file]
+//
+// Print a binary region as text, using only the schema.
+func decompileMain(args []string) {
+	inputFile := &cmdline.FilePath{
+		MustExist: true,
+	}
+	outputFile := &cmdline.FilePath{
+		MustExist: false,
+	}
+
+	var schema_file string
+	var binary_file string
+	var out string
+	var region_name string
+	var root string
+	var diagnostics string
+
+	app := cmdline.MakeApp("rommyc decompile")
+	app.Flags([]*cmdline.Flag{
+		{
+			Long:  "out",
+			Value: outputFile.Set(&out),
+		},
+		{
+			Long:  "region",
+			Value: cmdline.String.Set(&region_name),
+		},
+		{
+			Long:  "root",
+			Value: cmdline.String.Set(&root),
+		},
+		{
+			Long:  "diagnostics",
+			Value: cmdline.String.Set(&diagnostics),
+		},
+	})
+	app.RequiredArgs([]*cmdline.Argument{
+		{
+			Name:  "schema",
+			Value: inputFile.Set(&schema_file),
+		},
+		{
+			Name:  "binary",
+			Value: inputFile.Set(&binary_file),
+		},
+	})
+	app.Run(args)
+
+	data, err := ioutil.ReadFile(binary_file)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+	header, err := runtime.ReadHeader(runtime.MakeDeserializer(data))
+	if err != nil {
+		println("ERROR cannot decode " + binary_file + ", " + err.Error())
+		os.Exit(1)
+	}
+	if region_name == "" {
+		// The header names the region.
+		region_name = header.Region
+	}
+
+	status := createStatus(diagnostics)
+	rs := loadRegion(schema_file, region_name, status)
+	region := runtime.CreateDynamicRegion(rs)
+	err = region.UnmarshalBinary(data)
+	if err != nil {
+		println("ERROR cannot decode " + binary_file + ", " + err.Error())
+		os.Exit(1)
+	}
+
+	o, others := selectRoot(region, root)
+	if len(others) > 0 {
+		skipped := make([]string, len(others))
+		for i, other := range others {
+			skipped[i] = describeStruct(other)
+		}
+		println("WARNING not shown, unreachable from the root: " + strings.Join(skipped, ", "))
+	}
+
+	var text bytes.Buffer
+	runtime.DumpText(o, &text)
+	if out == "" {
+		os.Stdout.Write(text.Bytes())
+		return
+	}
+	err = ioutil.WriteFile(out, text.Bytes(), 0644)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+}
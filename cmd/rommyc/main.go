@@ -1,4 +1,16 @@
 // Command rommyc generates Go sources from schema declarations.
+//
+//	rommyc schema --go_out dir
+//	rommyc compile schema data --out file
+//	rommyc decompile schema binary [--root Type:index]
+//	rommyc compat old_schema new_schema
+//	rommyc migrate schema migration data
+//
+// The compile mode serializes a data file into the binary form read by the
+// generated code, and the decompile mode prints a binary region as text.  The
+// compat mode checks whether data written with one version of a schema can be
+// read with another, and the migrate mode rewrites data files after a schema
+// changes.
 package main
 
 import (
@@ -7,6 +19,7 @@ import (
 	"github.com/ncbray/compilerutil/writer"
 	"github.com/ncbray/rommy/generate/golang"
 	"github.com/ncbray/rommy/generate/haxe"
+	"github.com/ncbray/rommy/parser"
 	"github.com/ncbray/rommy/runtime"
 	"github.com/ncbray/rommy/schema"
 	"go/format"
@@ -63,7 +76,39 @@ func generateGo(input_file string, regions []*runtime.RegionSchema, output_dir s
 	}
 }
 
+// Create a status that reports diagnostics in the requested format.
+func createStatus(diagnostics string) *parser.Status {
+	var sink parser.Sink
+	switch diagnostics {
+	case "", "text":
+		sink = &parser.TextSink{Out: os.Stderr}
+	case "json":
+		sink = &parser.JSONSink{Out: os.Stderr}
+	default:
+		println("ERROR unknown diagnostics format " + diagnostics + ", expected text or json")
+		os.Exit(1)
+	}
+	return parser.CreateStatus(parser.CreateSourceSet(), sink)
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compile":
+			compileMain(os.Args[2:])
+			return
+		case "decompile":
+			decompileMain(os.Args[2:])
+			return
+		case "compat":
+			compatMain(os.Args[2:])
+			return
+		case "migrate":
+			migrateMain(os.Args[2:])
+			return
+		}
+	}
+
 	inputFile := &cmdline.FilePath{
 		MustExist: true,
 	}
@@ -75,6 +120,7 @@ func main() {
 	var go_out string
 	var haxe_out string
 	var haxe_package string
+	var diagnostics string
 
 	app := cmdline.MakeApp("rommyc")
 	app.Flags([]*cmdline.Flag{
@@ -90,6 +136,10 @@ func main() {
 			Long:  "haxe_package",
 			Value: cmdline.String.Set(&haxe_package),
 		},
+		{
+			Long:  "diagnostics",
+			Value: cmdline.String.Set(&diagnostics),
+		},
 	})
 	app.RequiredArgs([]*cmdline.Argument{
 		{
@@ -122,12 +172,11 @@ func main() {
 		os.Exit(1)
 	}
 
-	_, result, ok := schema.ParseSchema(input, data)
-	if !ok {
+	status := createStatus(diagnostics)
+	regions, ok := schema.LoadSchema(input, data, status)
+	if !ok || status.ShouldStop() {
 		os.Exit(1)
 	}
-	//runtime.DumpText(result, os.Stdout)
-	regions := schema.Resolve(result)
 
 	tmp, err := fs.MakeTempDir("rommyc_")
 	if err != nil {
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ncbray/cmdline"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/migrate"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const dataExtension = ".rommy"
+
+// Find the data files to migrate.  Directories are searched for .rommy files.
+func findDataFiles(path string) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || file != path && (filepath.Ext(file) != dataExtension || strings.HasPrefix(filepath.Base(file), ".")) {
+			return nil
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+// rommyc migrate schema migration data
+//
+// Rewrite data files written with a schema according to a migration, such as
+// after renaming structs or fields.  The schema is the old version, the one
+// the data is written in.  Data may be a file or a directory of .rommy files.
+// Every file that changes is rewritten in place and listed.
+func migrateMain(args []string) {
+	inputFile := &cmdline.FilePath{
+		MustExist: true,
+	}
+
+	var schema_file string
+	var migration_file string
+	var data_path string
+	var region_name string
+	var diagnostics string
+
+	app := cmdline.MakeApp("rommyc migrate")
+	app.Flags([]*cmdline.Flag{
+		{
+			Long:  "region",
+			Value: cmdline.String.Set(&region_name),
+		},
+		{
+			Long:  "diagnostics",
+			Value: cmdline.String.Set(&diagnostics),
+		},
+	})
+	app.RequiredArgs([]*cmdline.Argument{
+		{
+			Name:  "schema",
+			Value: inputFile.Set(&schema_file),
+		},
+		{
+			Name:  "migration",
+			Value: inputFile.Set(&migration_file),
+		},
+		{
+			Name:  "data",
+			Value: inputFile.Set(&data_path),
+		},
+	})
+	app.Run(args)
+
+	status := createStatus(diagnostics)
+	rs := loadRegion(schema_file, region_name, status)
+
+	data, err := ioutil.ReadFile(migration_file)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+	m, ok := migrate.LoadMigration(migration_file, data, rs, status)
+	if !ok || status.ShouldStop() {
+		os.Exit(1)
+	}
+
+	files, err := findDataFiles(data_path)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+	all_ok := true
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			println(err.Error())
+			all_ok = false
+			continue
+		}
+		errors := status.ErrorCount()
+		info := status.Sources.Add(file, data)
+		doc := human.ParseDocument(info, data, status)
+		if status.ErrorCount() > errors {
+			// Text that could not be parsed would be lost.
+			all_ok = false
+			continue
+		}
+		edits := m.Rewrite(doc)
+		if edits == 0 {
+			continue
+		}
+		var out bytes.Buffer
+		human.WriteDocument(doc, &out)
+		err = ioutil.WriteFile(file, out.Bytes(), 0644)
+		if err != nil {
+			println(err.Error())
+			all_ok = false
+			continue
+		}
+		plural := "s"
+		if edits == 1 {
+			plural = ""
+		}
+		fmt.Printf("%s: %d edit%s\n", file, edits, plural)
+	}
+	if !all_ok {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,176 @@
+// Command rommyfmt formats data files and schema definition files.
+//
+// Without flags the formatted files are written to standard output.  Files
+// ending in .rschema are formatted as schema definitions, all others as data.
+// Directories are searched for .rommy and .rschema files.  With no paths,
+// data is read from standard input.
+//
+//	rommyfmt [flags] [path ...]
+//
+//	-l  list files whose formatting differs
+//	-d  print a diff of the changes
+//	-w  write the formatted text back to the files
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/schema"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const dataExtension = ".rommy"
+
+var (
+	list   = flag.Bool("l", false, "list files whose formatting differs from rommyfmt's")
+	diff   = flag.Bool("d", false, "display diffs instead of rewriting files")
+	write  = flag.Bool("w", false, "write result to the source file instead of stdout")
+	indent = flag.String("indent", human.DefaultStyle.Indent, "text for each level of indentation")
+	width  = flag.Int("width", human.DefaultStyle.Width, "split expressions that do not fit in this many columns, 0 for no limit")
+)
+
+// Format the text of a file.  Files with syntax errors are not formatted, as
+// the text that could not be parsed would be lost.  The status is shared by
+// every file, so only the errors in this file are counted.
+func format(file string, data []byte, style human.Style, status *parser.Status) ([]byte, bool) {
+	info := status.Sources.Add(file, data)
+	errors := status.ErrorCount()
+	var out bytes.Buffer
+	if filepath.Ext(file) == schema.DefinitionExtension {
+		f := schema.ParseSchemaFile(info, data, status)
+		if status.ErrorCount() > errors {
+			return nil, false
+		}
+		schema.WriteSchemaFile(f, style, &out)
+	} else {
+		doc := human.ParseDocument(info, data, status)
+		if status.ErrorCount() > errors {
+			return nil, false
+		}
+		human.FormatDocument(doc, style, &out)
+	}
+	return out.Bytes(), true
+}
+
+func showDiff(file string, before []byte, after []byte, stdout io.Writer) error {
+	dir, err := ioutil.TempDir("", "rommyfmt")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "before")
+	b := filepath.Join(dir, "after")
+	if err := ioutil.WriteFile(a, before, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(b, after, 0644); err != nil {
+		return err
+	}
+	out, err := exec.Command("diff", "-u", "--label", file+".orig", "--label", file, a, b).CombinedOutput()
+	if len(out) == 0 && err != nil {
+		return err
+	}
+	// diff exits with 1 when the files differ.
+	stdout.Write(out)
+	return nil
+}
+
+func processFile(file string, data []byte, style human.Style, status *parser.Status, stdout io.Writer) bool {
+	formatted, ok := format(file, data, style, status)
+	if !ok {
+		return false
+	}
+	if !*list && !*diff && !*write {
+		stdout.Write(formatted)
+		return true
+	}
+	if bytes.Equal(data, formatted) {
+		return true
+	}
+	if *list {
+		fmt.Fprintln(stdout, file)
+	}
+	if *write {
+		info, err := os.Stat(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+		if err := ioutil.WriteFile(file, formatted, info.Mode().Perm()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+	}
+	if *diff {
+		if err := showDiff(file, data, formatted, stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+	}
+	return true
+}
+
+func isFormattable(path string) bool {
+	ext := filepath.Ext(path)
+	return (ext == dataExtension || ext == schema.DefinitionExtension) && !strings.HasPrefix(filepath.Base(path), ".")
+}
+
+// Format a file, or the formattable files in a directory.  Every file is
+// processed even if some cannot be formatted.
+func processPath(path string, style human.Style, status *parser.Status, stdout io.Writer) bool {
+	all_ok := true
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Named files are formatted whatever their extension.
+		if info.IsDir() || file != path && !isFormattable(file) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		all_ok = processFile(file, data, style, status, stdout) && all_ok
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		all_ok = false
+	}
+	return all_ok
+}
+
+func main() {
+	flag.Parse()
+	style := human.Style{Indent: *indent, Width: *width}
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.TextSink{Out: os.Stderr})
+
+	all_ok := true
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "ERROR cannot use -w with standard input")
+			os.Exit(2)
+		}
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		all_ok = processFile("<standard input>", data, style, status, os.Stdout)
+	}
+	for _, path := range flag.Args() {
+		all_ok = processPath(path, style, status, os.Stdout) && all_ok
+	}
+	if !all_ok {
+		os.Exit(1)
+	}
+}
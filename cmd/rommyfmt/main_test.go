@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListContinuesAfterSyntaxError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rommyfmt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.rommy":   "Item {name: }\n",
+		"b.rommy":   "Item {name:\"b\"}\n",
+		"c.rommy":   "Item {name: \"c\"}\n",
+		"d.rschema": "region R {struct A {}}\n",
+	}
+	for name, text := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644))
+	}
+
+	*list = true
+	defer func() { *list = false }()
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+	var out bytes.Buffer
+	ok := processPath(dir, human.DefaultStyle, status, &out)
+	assert.False(t, ok)
+	assert.Equal(t, filepath.Join(dir, "b.rommy")+"\n"+filepath.Join(dir, "d.rschema")+"\n", out.String())
+	assert.Equal(t, 1, status.ErrorCount())
+}
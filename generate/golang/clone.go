@@ -9,7 +9,7 @@ import (
 
 func generateValueClone(src_path string, dst_path string, level int, t runtime.TypeSchema, r *runtime.RegionSchema, out *writer.TabbedWriter) {
 	switch t := t.(type) {
-	case *runtime.IntegerSchema, *runtime.FloatSchema, *runtime.StringSchema, *runtime.BooleanSchema:
+	case *runtime.IntegerSchema, *runtime.FloatSchema, *runtime.StringSchema, *runtime.BooleanSchema, *runtime.EnumSchema:
 		out.WriteString(dst_path)
 		out.WriteString(" = ")
 		out.WriteString(src_path)
@@ -22,6 +22,22 @@ func generateValueClone(src_path string, dst_path string, level int, t runtime.T
 		out.WriteString(src_path)
 		out.WriteString(")")
 		out.EndOfLine()
+	case *runtime.UnionSchema:
+		child_path := "u" + strconv.Itoa(level)
+		out.WriteLine("switch " + child_path + " := " + src_path + ".(type) {")
+		for _, s := range t.Arms {
+			out.WriteLine("case *" + s.Name + ":")
+			out.Indent()
+			generateValueClone(child_path, dst_path, level+1, s, r, out)
+			out.Dedent()
+		}
+		out.WriteLine("}")
+	case *runtime.OptionalSchema:
+		out.WriteLine("if " + src_path + " != nil {")
+		out.Indent()
+		generateValueClone(src_path, dst_path, level, t.Element, r, out)
+		out.Dedent()
+		out.WriteLine("}")
 	case *runtime.ListSchema:
 		out.WriteString(dst_path)
 		out.WriteString(" = make(")
@@ -38,6 +54,34 @@ func generateValueClone(src_path string, dst_path string, level int, t runtime.T
 		generateValueClone(src_path+index_op, dst_path+index_op, level+1, t.Element, r, out)
 		out.Dedent()
 		out.WriteLine("}")
+	case *runtime.ArraySchema:
+		child_index := "i" + strconv.Itoa(level)
+		out.WriteLine("for " + child_index + ", _ := range " + src_path + " {")
+		out.Indent()
+		index_op := "[" + child_index + "]"
+		generateValueClone(src_path+index_op, dst_path+index_op, level+1, t.Element, r, out)
+		out.Dedent()
+		out.WriteLine("}")
+	case *runtime.BytesSchema:
+		// The destination must not alias the source.
+		out.WriteLine(dst_path + " = append([]byte(nil), " + src_path + "...)")
+	case *runtime.MapSchema:
+		out.WriteString(dst_path)
+		out.WriteString(" = make(")
+		out.WriteString(goTypeRef(t))
+		out.WriteString(", len(")
+		out.WriteString(src_path)
+		out.WriteString("))")
+		out.EndOfLine()
+
+		// Keys are scalars and can be copied directly.
+		child_key := "k" + strconv.Itoa(level)
+		out.WriteLine("for " + child_key + ", _ := range " + src_path + " {")
+		out.Indent()
+		index_op := "[" + child_key + "]"
+		generateValueClone(src_path+index_op, dst_path+index_op, level+1, t.Value, r, out)
+		out.Dedent()
+		out.WriteLine("}")
 		// Copy
 	default:
 		panic(t)
@@ -1,7 +1,11 @@
 package golang
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/ncbray/compilerutil/names"
+	"github.com/ncbray/compilerutil/writer"
 	"github.com/ncbray/rommy/runtime"
 )
 
@@ -19,13 +23,35 @@ func goTypeRef(t runtime.TypeSchema) string {
 		return "bool"
 	case *runtime.StructSchema:
 		return "*" + t.Name
+	case *runtime.EnumSchema:
+		return t.Name
+	case *runtime.OptionalSchema:
+		return goTypeRef(t.Element)
+	case *runtime.UnionSchema:
+		return t.Name
 	case *runtime.ListSchema:
 		return "[]" + goTypeRef(t.Element)
+	case *runtime.ArraySchema:
+		return "[" + strconv.Itoa(t.Length) + "]" + goTypeRef(t.Element)
+	case *runtime.BytesSchema:
+		return "[]byte"
+	case *runtime.MapSchema:
+		return "map[" + goTypeRef(t.Key) + "]" + goTypeRef(t.Value)
 	default:
 		panic(t)
 	}
 }
 
+// Write a doc string from the schema as a Go comment.
+func writeDocComment(doc string, out *writer.TabbedWriter) {
+	if doc == "" {
+		return
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		out.WriteLine(strings.TrimRight("// "+line, " "))
+	}
+}
+
 func fieldName(f *runtime.FieldSchema) string {
 	return names.JoinCamelCase(names.SplitSnakeCase(f.Name), true)
 }
@@ -34,6 +60,23 @@ func structSchemaName(s *runtime.StructSchema) string {
 	return names.JoinCamelCase(names.SplitCamelCase(s.Name+"Schema"), false)
 }
 
+func enumSchemaName(e *runtime.EnumSchema) string {
+	return names.JoinCamelCase(names.SplitCamelCase(e.Name+"Schema"), false)
+}
+
+func unionSchemaName(u *runtime.UnionSchema) string {
+	return names.JoinCamelCase(names.SplitCamelCase(u.Name+"Schema"), false)
+}
+
+// The marker method implemented by each arm of a union.
+func unionMethodName(u *runtime.UnionSchema) string {
+	return "is" + u.Name
+}
+
+func enumValueName(e *runtime.EnumSchema, value string) string {
+	return e.Name + names.JoinCamelCase(names.SplitSnakeCase(value), true)
+}
+
 func regionStructName(r *runtime.RegionSchema) string {
 	return names.JoinCamelCase(names.SplitCamelCase(r.Name+"Region"), true)
 }
@@ -43,13 +86,46 @@ func regionSchemaName(r *runtime.RegionSchema) string {
 }
 
 func poolField(r *runtime.RegionSchema, s *runtime.StructSchema) string {
-	return names.JoinCamelCase(names.SplitCamelCase(s.Name+"Pool"), true)
+	return s.PoolGoName()
 }
 
 func regionClonerName(r *runtime.RegionSchema) string {
 	return names.JoinCamelCase(names.SplitCamelCase(r.Name+"Cloner"), true)
 }
 
+func keyIndexField(r *runtime.RegionSchema, s *runtime.StructSchema) string {
+	return names.JoinCamelCase(names.SplitCamelCase(s.Name+"Keys"), false)
+}
+
+func keyIndexMethod(r *runtime.RegionSchema, s *runtime.StructSchema) string {
+	return "index" + s.Name
+}
+
+func keySetterMethod(r *runtime.RegionSchema, s *runtime.StructSchema) string {
+	return "Set" + s.Name + "Key"
+}
+
 func mapingField(r *runtime.RegionSchema, s *runtime.StructSchema) string {
 	return names.JoinCamelCase(names.SplitCamelCase(s.Name+"Map"), false)
 }
+
+// A Go literal for a default value, assignable to the field.
+func goConstant(v interface{}, t runtime.TypeSchema) string {
+	switch t := t.(type) {
+	case *runtime.IntegerSchema:
+		if t.Unsigned {
+			return strconv.FormatUint(v.(uint64), 10)
+		}
+		return strconv.FormatInt(v.(int64), 10)
+	case *runtime.FloatSchema:
+		return strconv.FormatFloat(v.(float64), 'g', -1, int(t.Bits))
+	case *runtime.StringSchema:
+		return strconv.Quote(v.(string))
+	case *runtime.BooleanSchema:
+		return strconv.FormatBool(v.(bool))
+	case *runtime.EnumSchema:
+		return enumValueName(t, t.Values[v.(int)])
+	default:
+		panic(t)
+	}
+}
@@ -42,6 +42,11 @@ func deserialize(path string, level int, r *runtime.RegionSchema, t runtime.Type
 		out.WriteString(", err = d.ReadBool()")
 		out.EndOfLine()
 		abortDeserializeOnError(out)
+	case *runtime.BytesSchema:
+		out.WriteString(path)
+		out.WriteString(", err = d.ReadBytes()")
+		out.EndOfLine()
+		abortDeserializeOnError(out)
 	case *runtime.StructSchema:
 		f := poolField(r, t)
 		out.WriteString("index, err = d.ReadIndex(len(r.")
@@ -55,6 +60,33 @@ func deserialize(path string, level int, r *runtime.RegionSchema, t runtime.Type
 		out.WriteString(f)
 		out.WriteString("[index]")
 		out.EndOfLine()
+	case *runtime.UnionSchema:
+		deserializeUnion(path, r, t, false, out)
+	case *runtime.OptionalSchema:
+		if u, ok := t.Element.(*runtime.UnionSchema); ok {
+			deserializeUnion(path, r, u, true, out)
+			break
+		}
+		f := poolField(r, t.Element.(*runtime.StructSchema))
+		out.WriteLine("index, err = d.ReadOptionalIndex(len(r." + f + "))")
+		abortDeserializeOnError(out)
+		out.WriteLine("if index != runtime.NoIndex {")
+		out.Indent()
+		out.WriteLine(path + " = r." + f + "[index]")
+		out.Dedent()
+		out.WriteLine("}")
+	case *runtime.EnumSchema:
+		out.WriteString("index, err = d.ReadIndex(")
+		out.WriteString(strconv.Itoa(len(t.Values)))
+		out.WriteString(")")
+		out.EndOfLine()
+		abortDeserializeOnError(out)
+
+		out.WriteString(path)
+		out.WriteString(" = ")
+		out.WriteString(t.Name)
+		out.WriteString("(index)")
+		out.EndOfLine()
 	case *runtime.ListSchema:
 		out.WriteLine("index, err = d.ReadCount()")
 		abortDeserializeOnError(out)
@@ -75,11 +107,82 @@ func deserialize(path string, level int, r *runtime.RegionSchema, t runtime.Type
 		deserialize(path+"["+child_index+"]", level+1, r, t.Element, out)
 		out.Dedent()
 		out.WriteLine("}")
+	case *runtime.ArraySchema:
+		child_index := "i" + strconv.Itoa(level)
+		out.WriteString("for ")
+		out.WriteString(child_index)
+		out.WriteString(", _ := range ")
+		out.WriteString(path)
+		out.WriteString(" {")
+		out.EndOfLine()
+		out.Indent()
+		deserialize(path+"["+child_index+"]", level+1, r, t.Element, out)
+		out.Dedent()
+		out.WriteLine("}")
+	case *runtime.MapSchema:
+		out.WriteLine("index, err = d.ReadCount()")
+		abortDeserializeOnError(out)
+		out.WriteString(path)
+		out.WriteString(" = make(")
+		out.WriteString(goTypeRef(t))
+		out.WriteString(", index)")
+		out.EndOfLine()
+
+		// Deserializing the entries clobbers index.
+		child_index := "i" + strconv.Itoa(level)
+		child_count := "n" + strconv.Itoa(level)
+		key_path := "k" + strconv.Itoa(level)
+		value_path := "v" + strconv.Itoa(level)
+		out.WriteString("for ")
+		out.WriteString(child_index)
+		out.WriteString(", ")
+		out.WriteString(child_count)
+		out.WriteString(" := 0, index; ")
+		out.WriteString(child_index)
+		out.WriteString(" < ")
+		out.WriteString(child_count)
+		out.WriteString("; ")
+		out.WriteString(child_index)
+		out.WriteString("++ {")
+		out.EndOfLine()
+		out.Indent()
+		out.WriteLine("var " + key_path + " " + goTypeRef(t.Key))
+		out.WriteLine("var " + value_path + " " + goTypeRef(t.Value))
+		deserialize(key_path, level+1, r, t.Key, out)
+		deserialize(value_path, level+1, r, t.Value, out)
+		out.WriteLine("if _, ok := " + path + "[" + key_path + "]; ok {")
+		out.Indent()
+		out.WriteLine("return runtime.ErrDuplicateKey")
+		out.Dedent()
+		out.WriteLine("}")
+		out.WriteLine(path + "[" + key_path + "] = " + value_path)
+		out.Dedent()
+		out.WriteLine("}")
 	default:
 		panic(t)
 	}
 }
 
+func deserializeUnion(path string, r *runtime.RegionSchema, t *runtime.UnionSchema, optional bool, out *writer.TabbedWriter) {
+	read := "ReadIndex"
+	if optional {
+		read = "ReadOptionalIndex"
+	}
+	out.WriteLine("index, err = d." + read + "(" + strconv.Itoa(len(t.Arms)) + ")")
+	abortDeserializeOnError(out)
+	out.WriteLine("switch index {")
+	for i, s := range t.Arms {
+		f := poolField(r, s)
+		out.WriteLine("case " + strconv.Itoa(i) + ":")
+		out.Indent()
+		out.WriteLine("index, err = d.ReadIndex(len(r." + f + "))")
+		abortDeserializeOnError(out)
+		out.WriteLine(path + " = r." + f + "[index]")
+		out.Dedent()
+	}
+	out.WriteLine("}")
+}
+
 func generateRegionDeserialize(r *runtime.RegionSchema, out *writer.TabbedWriter) {
 	structName := regionStructName(r)
 
@@ -93,6 +196,8 @@ func generateRegionDeserialize(r *runtime.RegionSchema, out *writer.TabbedWriter
 	out.WriteLine("d := runtime.MakeDeserializer(data)")
 	out.WriteLine("var index int")
 	out.WriteLine("var err error")
+	out.WriteLine("err = runtime.CheckHeader(d, r.Schema())")
+	abortDeserializeOnError(out)
 
 	// Allocate objects
 	for _, s := range r.Structs {
@@ -124,6 +229,16 @@ func generateRegionDeserialize(r *runtime.RegionSchema, out *writer.TabbedWriter
 		out.Dedent()
 		out.WriteLine("}")
 	}
+	// The keys are known once the fields are read.
+	for _, s := range r.Structs {
+		if s.Key != nil {
+			out.WriteString("err = r.")
+			out.WriteString(keyIndexMethod(r, s))
+			out.WriteString("()")
+			out.EndOfLine()
+			abortDeserializeOnError(out)
+		}
+	}
 	out.WriteLine("return nil")
 	out.Dedent()
 	out.WriteLine("}")
@@ -16,7 +16,8 @@ func abortSerializeOnError(out *writer.TabbedWriter) {
 	out.WriteLine("}")
 }
 
-func serialize(path string, level int, r *runtime.RegionSchema, t runtime.TypeSchema, out *writer.TabbedWriter) {
+// field describes what is being serialized for error messages.
+func serialize(path string, field string, level int, r *runtime.RegionSchema, t runtime.TypeSchema, out *writer.TabbedWriter) {
 	switch t := t.(type) {
 	case *runtime.IntegerSchema:
 		out.WriteString("s.Write")
@@ -42,7 +43,17 @@ func serialize(path string, level int, r *runtime.RegionSchema, t runtime.TypeSc
 		out.WriteString(path)
 		out.WriteString(")")
 		out.EndOfLine()
+	case *runtime.BytesSchema:
+		out.WriteString("s.WriteBytes(")
+		out.WriteString(path)
+		out.WriteString(")")
+		out.EndOfLine()
 	case *runtime.StructSchema:
+		out.WriteLine("if " + path + " == nil {")
+		out.Indent()
+		out.WriteLine("return nil, runtime.NilReference(" + strconv.Quote(field) + ")")
+		out.Dedent()
+		out.WriteLine("}")
 		out.WriteString("err = s.WriteIndex(")
 		out.WriteString(path)
 		out.WriteString(".PoolIndex, len(r.")
@@ -50,6 +61,33 @@ func serialize(path string, level int, r *runtime.RegionSchema, t runtime.TypeSc
 		out.WriteString("))")
 		out.EndOfLine()
 		abortSerializeOnError(out)
+	case *runtime.UnionSchema:
+		serializeUnion(path, field, level, r, t, false, out)
+	case *runtime.OptionalSchema:
+		if u, ok := t.Element.(*runtime.UnionSchema); ok {
+			serializeUnion(path, field, level, r, u, true, out)
+			break
+		}
+		e := t.Element.(*runtime.StructSchema)
+		pool := "len(r." + poolField(r, e) + ")"
+		out.WriteLine("if " + path + " == nil {")
+		out.Indent()
+		out.WriteLine("err = s.WriteOptionalIndex(runtime.NoIndex, " + pool + ")")
+		out.Dedent()
+		out.WriteLine("} else {")
+		out.Indent()
+		out.WriteLine("err = s.WriteOptionalIndex(" + path + ".PoolIndex, " + pool + ")")
+		out.Dedent()
+		out.WriteLine("}")
+		abortSerializeOnError(out)
+	case *runtime.EnumSchema:
+		out.WriteString("err = s.WriteIndex(int(")
+		out.WriteString(path)
+		out.WriteString("), ")
+		out.WriteString(strconv.Itoa(len(t.Values)))
+		out.WriteString(")")
+		out.EndOfLine()
+		abortSerializeOnError(out)
 	case *runtime.ListSchema:
 		out.WriteString("err = s.WriteCount(len(")
 		out.WriteString(path)
@@ -65,7 +103,42 @@ func serialize(path string, level int, r *runtime.RegionSchema, t runtime.TypeSc
 		out.WriteString(" {")
 		out.EndOfLine()
 		out.Indent()
-		serialize(child_path, level+1, r, t.Element, out)
+		serialize(child_path, field, level+1, r, t.Element, out)
+		out.Dedent()
+		out.WriteLine("}")
+	case *runtime.ArraySchema:
+		// The length is part of the type.
+		child_path := "o" + strconv.Itoa(level)
+		out.WriteString("for _, ")
+		out.WriteString(child_path)
+		out.WriteString(" := range ")
+		out.WriteString(path)
+		out.WriteString(" {")
+		out.EndOfLine()
+		out.Indent()
+		serialize(child_path, field, level+1, r, t.Element, out)
+		out.Dedent()
+		out.WriteLine("}")
+	case *runtime.MapSchema:
+		out.WriteString("err = s.WriteCount(len(")
+		out.WriteString(path)
+		out.WriteString("))")
+		out.EndOfLine()
+		abortSerializeOnError(out)
+
+		// Iterate in key order so the encoding is deterministic.
+		key_path := "k" + strconv.Itoa(level)
+		out.WriteString("for _, ")
+		out.WriteString(key_path)
+		out.WriteString(" := range runtime.SortedKeys(")
+		out.WriteString(path)
+		out.WriteString(").([]")
+		out.WriteString(goTypeRef(t.Key))
+		out.WriteString(") {")
+		out.EndOfLine()
+		out.Indent()
+		serialize(key_path, field, level+1, r, t.Key, out)
+		serialize(path+"["+key_path+"]", field, level+1, r, t.Value, out)
 		out.Dedent()
 		out.WriteLine("}")
 	default:
@@ -73,6 +146,38 @@ func serialize(path string, level int, r *runtime.RegionSchema, t runtime.TypeSc
 	}
 }
 
+// Write the tag of the arm, then the index of the struct in its pool.
+func serializeUnion(path string, field string, level int, r *runtime.RegionSchema, t *runtime.UnionSchema, optional bool, out *writer.TabbedWriter) {
+	write := "WriteIndex"
+	if optional {
+		write = "WriteOptionalIndex"
+	}
+	arms := strconv.Itoa(len(t.Arms))
+	child_path := "u" + strconv.Itoa(level)
+	out.WriteLine("switch " + child_path + " := " + path + ".(type) {")
+	for i, s := range t.Arms {
+		out.WriteLine("case *" + s.Name + ":")
+		out.Indent()
+		out.WriteLine("err = s." + write + "(" + strconv.Itoa(i) + ", " + arms + ")")
+		abortSerializeOnError(out)
+		out.WriteLine("err = s.WriteIndex(" + child_path + ".PoolIndex, len(r." + poolField(r, s) + "))")
+		out.Dedent()
+	}
+	if optional {
+		out.WriteLine("case nil:")
+		out.Indent()
+		out.WriteLine("err = s.WriteOptionalIndex(runtime.NoIndex, " + arms + ")")
+		out.Dedent()
+	} else {
+		out.WriteLine("default:")
+		out.Indent()
+		out.WriteLine("return nil, runtime.NilReference(" + strconv.Quote(field) + ")")
+		out.Dedent()
+	}
+	out.WriteLine("}")
+	abortSerializeOnError(out)
+}
+
 func generateRegionSerialize(r *runtime.RegionSchema, out *writer.TabbedWriter) {
 	structName := regionStructName(r)
 
@@ -85,6 +190,7 @@ func generateRegionSerialize(r *runtime.RegionSchema, out *writer.TabbedWriter)
 	out.Indent()
 	out.WriteLine("s := runtime.MakeSerializer()")
 	out.WriteLine("var err error")
+	out.WriteLine("runtime.WriteHeader(s, r.Schema())")
 
 	// indexs
 	for _, s := range r.Structs {
@@ -106,7 +212,7 @@ func generateRegionSerialize(r *runtime.RegionSchema, out *writer.TabbedWriter)
 
 		for _, f := range s.Fields {
 			path := "o." + fieldName(f)
-			serialize(path, 0, r, f.Type, out)
+			serialize(path, s.Name+"."+f.Name, 0, r, f.Type, out)
 		}
 		out.Dedent()
 		out.WriteLine("}")
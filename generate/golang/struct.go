@@ -3,6 +3,7 @@ package golang
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/ncbray/compilerutil/writer"
 	"github.com/ncbray/rommy/runtime"
@@ -18,18 +19,75 @@ func schemaFieldType(t runtime.TypeSchema) string {
 		return "&runtime.StringSchema{}"
 	case *runtime.BooleanSchema:
 		return "&runtime.BooleanSchema{}"
+	case *runtime.BytesSchema:
+		return "&runtime.BytesSchema{}"
 	case *runtime.StructSchema:
 		return structSchemaName(t)
+	case *runtime.EnumSchema:
+		return enumSchemaName(t)
+	case *runtime.OptionalSchema:
+		return "&runtime.OptionalSchema{Element: " + schemaFieldType(t.Element) + "}"
+	case *runtime.UnionSchema:
+		return unionSchemaName(t)
 	case *runtime.ListSchema:
 		// Precedence issues with "&" operator.
 		return "(" + schemaFieldType(t.Element) + ").List()"
+	case *runtime.ArraySchema:
+		return fmt.Sprintf("&runtime.ArraySchema{Element: %s, Length: %d}", schemaFieldType(t.Element), t.Length)
+	case *runtime.MapSchema:
+		return "&runtime.MapSchema{Key: " + schemaFieldType(t.Key) + ", Value: " + schemaFieldType(t.Value) + "}"
 	default:
 		panic(t)
 	}
 }
 
+// A default or bound as stored in the schema.
+func schemaConstant(v interface{}, t runtime.TypeSchema) string {
+	switch v.(type) {
+	case int64:
+		return "int64(" + goConstant(v, t) + ")"
+	case uint64:
+		return "uint64(" + goConstant(v, t) + ")"
+	case float64:
+		return "float64(" + goConstant(v, t) + ")"
+	case int:
+		return strconv.Itoa(v.(int))
+	default:
+		return goConstant(v, t)
+	}
+}
+
+// The constraints on a field as stored in the schema.
+func schemaConstraints(f *runtime.FieldSchema) string {
+	c := f.Constraints
+	parts := []string{}
+	if c.Min != nil {
+		parts = append(parts, "Min: "+schemaConstant(c.Min, f.Type))
+	}
+	if c.Max != nil {
+		parts = append(parts, "Max: "+schemaConstant(c.Max, f.Type))
+	}
+	if c.MinLength != 0 {
+		parts = append(parts, "MinLength: "+strconv.Itoa(c.MinLength))
+	}
+	if c.MaxLength != 0 {
+		parts = append(parts, "MaxLength: "+strconv.Itoa(c.MaxLength))
+	}
+	if c.Pattern != "" {
+		parts = append(parts, "Pattern: "+strconv.Quote(c.Pattern))
+	}
+	if c.NonEmpty {
+		parts = append(parts, "NonEmpty: true")
+	}
+	if c.Unique {
+		parts = append(parts, "Unique: true")
+	}
+	return "&runtime.Constraints{" + strings.Join(parts, ", ") + "}"
+}
+
 func generateStructDecls(r *runtime.RegionSchema, s *runtime.StructSchema, out *writer.TabbedWriter) {
 	out.EndOfLine()
+	writeDocComment(s.Doc, out)
 	out.WriteString("type ")
 	out.WriteString(s.Name)
 	out.WriteString(" struct {")
@@ -37,6 +95,13 @@ func generateStructDecls(r *runtime.RegionSchema, s *runtime.StructSchema, out *
 	out.Indent()
 	out.WriteLine("PoolIndex int")
 	for _, f := range s.Fields {
+		writeDocComment(f.Doc, out)
+		if f.Deprecated {
+			if f.Doc != "" {
+				out.WriteLine("//")
+			}
+			out.WriteLine("// Deprecated: the schema marks " + f.Name + " as deprecated, do not set it in new data.")
+		}
 		out.WriteString(fieldName(f))
 		out.WriteString(" ")
 		out.WriteString(goTypeRef(f.Type))
@@ -72,16 +137,109 @@ func generateStructDecls(r *runtime.RegionSchema, s *runtime.StructSchema, out *
 	out.EndOfLine()
 }
 
+func generateEnumDecls(r *runtime.RegionSchema, e *runtime.EnumSchema, out *writer.TabbedWriter) {
+	out.EndOfLine()
+	out.WriteString("type ")
+	out.WriteString(e.Name)
+	out.WriteString(" uint32")
+	out.EndOfLine()
+
+	out.EndOfLine()
+	out.WriteLine("const (")
+	out.Indent()
+	for i, v := range e.Values {
+		out.WriteString(enumValueName(e, v))
+		if i == 0 {
+			out.WriteString(" ")
+			out.WriteString(e.Name)
+			out.WriteString(" = iota")
+		}
+		out.EndOfLine()
+	}
+	out.Dedent()
+	out.WriteLine(")")
+
+	out.EndOfLine()
+	out.WriteString("var ")
+	out.WriteString(enumSchemaName(e))
+	out.WriteString(" = &runtime.EnumSchema{")
+	out.WriteString("Name: ")
+	out.WriteString(strconv.Quote(e.Name))
+	out.WriteString(", Values: []string{")
+	for i, v := range e.Values {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(strconv.Quote(v))
+	}
+	out.WriteString("}, GoType: ")
+	out.WriteString(e.Name)
+	out.WriteString("(0)}")
+	out.EndOfLine()
+}
+
+func generateUnionDecls(r *runtime.RegionSchema, u *runtime.UnionSchema, out *writer.TabbedWriter) {
+	method := unionMethodName(u)
+
+	out.EndOfLine()
+	out.WriteString("type ")
+	out.WriteString(u.Name)
+	out.WriteString(" interface {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteLine("Schema() *runtime.StructSchema")
+	out.WriteLine(method + "()")
+	out.Dedent()
+	out.WriteLine("}")
+
+	for _, s := range u.Arms {
+		out.EndOfLine()
+		out.WriteString("func (s *")
+		out.WriteString(s.Name)
+		out.WriteString(") ")
+		out.WriteString(method)
+		out.WriteString("() {")
+		out.EndOfLine()
+		out.WriteLine("}")
+	}
+
+	out.EndOfLine()
+	out.WriteString("var ")
+	out.WriteString(unionSchemaName(u))
+	out.WriteString(" = &runtime.UnionSchema{Name: ")
+	out.WriteString(strconv.Quote(u.Name))
+	out.WriteString(", Arms: []*runtime.StructSchema{")
+	for i, s := range u.Arms {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(structSchemaName(s))
+	}
+	out.WriteString("}, GoType: (*")
+	out.WriteString(u.Name)
+	out.WriteString(")(nil)}")
+	out.EndOfLine()
+}
+
 func generateRegionDecls(r *runtime.RegionSchema, out *writer.TabbedWriter) {
+	for _, e := range r.Enums {
+		generateEnumDecls(r, e, out)
+	}
+
 	for _, s := range r.Structs {
 		generateStructDecls(r, s, out)
 	}
 
+	for _, u := range r.Unions {
+		generateUnionDecls(r, u, out)
+	}
+
 	structName := regionStructName(r)
 	schemaName := regionSchemaName(r)
 
 	// Type decl
 	out.EndOfLine()
+	writeDocComment(r.Doc, out)
 	out.WriteString("type ")
 	out.WriteString(structName)
 	out.WriteString(" struct {")
@@ -93,6 +251,17 @@ func generateRegionDecls(r *runtime.RegionSchema, out *writer.TabbedWriter) {
 		out.WriteString(goTypeRef(s.List()))
 		out.EndOfLine()
 	}
+	for _, s := range r.Structs {
+		if s.Key == nil {
+			continue
+		}
+		out.WriteString(keyIndexField(r, s))
+		out.WriteString(" map[")
+		out.WriteString(goTypeRef(s.Key.Type))
+		out.WriteString("]")
+		out.WriteString(goTypeRef(s))
+		out.EndOfLine()
+	}
 	out.Dedent()
 	out.WriteLine("}")
 
@@ -153,6 +322,16 @@ func generateRegionDecls(r *runtime.RegionSchema, out *writer.TabbedWriter) {
 		out.WriteString(s.Name)
 		out.WriteString("{}")
 		out.EndOfLine()
+		for _, f := range s.Fields {
+			if f.Default == nil {
+				continue
+			}
+			out.WriteString("o.")
+			out.WriteString(fieldName(f))
+			out.WriteString(" = ")
+			out.WriteString(goConstant(f.Default, f.Type))
+			out.EndOfLine()
+		}
 
 		f := poolField(r, s)
 
@@ -213,6 +392,30 @@ func generateStructInit(r *runtime.RegionSchema, s *runtime.StructSchema, out *w
 		out.WriteString(strconv.Quote(f.Name))
 		out.WriteString(", Type: ")
 		out.WriteString(schemaFieldType(f.Type))
+		if f.Default != nil {
+			out.WriteString(", Default: ")
+			out.WriteString(schemaConstant(f.Default, f.Type))
+		}
+		if len(f.Aliases) > 0 {
+			out.WriteString(", Aliases: []string{")
+			for i, a := range f.Aliases {
+				if i > 0 {
+					out.WriteString(", ")
+				}
+				out.WriteString(strconv.Quote(a))
+			}
+			out.WriteString("}")
+		}
+		if f.Deprecated {
+			out.WriteString(", Deprecated: true")
+		}
+		if f.Constraints != nil {
+			out.WriteString(", Constraints: ")
+			out.WriteString(schemaConstraints(f))
+		}
+		if f.Key {
+			out.WriteString(", Key: true")
+		}
 		out.WriteString("},")
 		out.EndOfLine()
 	}
@@ -220,6 +423,197 @@ func generateStructInit(r *runtime.RegionSchema, s *runtime.StructSchema, out *w
 	out.WriteLine("}")
 }
 
+// Lookups of structs by key.  The index is built by UnmarshalBinary and kept
+// up to date by the key setter, so a lookup only reads the map.
+func generateRegionLookups(r *runtime.RegionSchema, s *runtime.StructSchema, out *writer.TabbedWriter) {
+	structName := regionStructName(r)
+	index := keyIndexField(r, s)
+	pool := poolField(r, s)
+	key := fieldName(s.Key)
+	keyType := goTypeRef(s.Key.Type)
+	mapType := "map[" + keyType + "]" + goTypeRef(s)
+
+	out.EndOfLine()
+	out.WriteString("// Index the ")
+	out.WriteString(s.Name)
+	out.WriteString(" pool by key.  Two structs with the same key are an error.")
+	out.EndOfLine()
+	out.WriteString("func (r *")
+	out.WriteString(structName)
+	out.WriteString(") ")
+	out.WriteString(keyIndexMethod(r, s))
+	out.WriteString("() error {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteString("r.")
+	out.WriteString(index)
+	out.WriteString(" = make(")
+	out.WriteString(mapType)
+	out.WriteString(", len(r.")
+	out.WriteString(pool)
+	out.WriteString("))")
+	out.EndOfLine()
+	out.WriteString("for _, o := range r.")
+	out.WriteString(pool)
+	out.WriteString(" {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteString("if _, ok := r.")
+	out.WriteString(index)
+	out.WriteString("[o.")
+	out.WriteString(key)
+	out.WriteString("]; ok {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteLine("return runtime.ErrDuplicateKey")
+	out.Dedent()
+	out.WriteLine("}")
+	out.WriteString("r.")
+	out.WriteString(index)
+	out.WriteString("[o.")
+	out.WriteString(key)
+	out.WriteString("] = o")
+	out.EndOfLine()
+	out.Dedent()
+	out.WriteLine("}")
+	out.WriteLine("return nil")
+	out.Dedent()
+	out.WriteLine("}")
+
+	out.EndOfLine()
+	out.WriteString("// Give a ")
+	out.WriteString(s.Name)
+	out.WriteString(" a key, so it can be found with ")
+	out.WriteString(s.Name)
+	out.WriteString(".  Fails if another")
+	out.EndOfLine()
+	out.WriteString("// ")
+	out.WriteString(s.Name)
+	out.WriteString(" already has the key.")
+	out.EndOfLine()
+	out.WriteString("func (r *")
+	out.WriteString(structName)
+	out.WriteString(") ")
+	out.WriteString(keySetterMethod(r, s))
+	out.WriteString("(o ")
+	out.WriteString(goTypeRef(s))
+	out.WriteString(", key ")
+	out.WriteString(keyType)
+	out.WriteString(") error {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteString("if other, ok := r.")
+	out.WriteString(index)
+	out.WriteString("[key]; ok && other != o {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteLine("return runtime.ErrDuplicateKey")
+	out.Dedent()
+	out.WriteLine("}")
+	out.WriteString("if r.")
+	out.WriteString(index)
+	out.WriteString(" == nil {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteString("r.")
+	out.WriteString(index)
+	out.WriteString(" = ")
+	out.WriteString(mapType)
+	out.WriteString("{}")
+	out.EndOfLine()
+	out.Dedent()
+	out.WriteLine("}")
+	out.WriteString("if r.")
+	out.WriteString(index)
+	out.WriteString("[o.")
+	out.WriteString(key)
+	out.WriteString("] == o {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteString("delete(r.")
+	out.WriteString(index)
+	out.WriteString(", o.")
+	out.WriteString(key)
+	out.WriteString(")")
+	out.EndOfLine()
+	out.Dedent()
+	out.WriteLine("}")
+	out.WriteString("o.")
+	out.WriteString(key)
+	out.WriteString(" = key")
+	out.EndOfLine()
+	out.WriteString("r.")
+	out.WriteString(index)
+	out.WriteString("[key] = o")
+	out.EndOfLine()
+	out.WriteLine("return nil")
+	out.Dedent()
+	out.WriteLine("}")
+
+	out.EndOfLine()
+	out.WriteString("// Find the ")
+	out.WriteString(s.Name)
+	out.WriteString(" with a key.")
+	out.EndOfLine()
+	out.WriteString("func (r *")
+	out.WriteString(structName)
+	out.WriteString(") ")
+	out.WriteString(s.Name)
+	out.WriteString("(key ")
+	out.WriteString(keyType)
+	out.WriteString(") (")
+	out.WriteString(goTypeRef(s))
+	out.WriteString(", bool) {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteString("o, ok := r.")
+	out.WriteString(index)
+	out.WriteString("[key]")
+	out.EndOfLine()
+	out.WriteLine("return o, ok")
+	out.Dedent()
+	out.WriteLine("}")
+}
+
+// Rebuild the key indexes after keys were assigned directly, rather than with
+// the key setters.
+func generateRegionReindex(r *runtime.RegionSchema, out *writer.TabbedWriter) {
+	out.EndOfLine()
+	out.WriteLine("// Rebuild the indexes used to find structs by key, after keys were assigned")
+	out.WriteLine("// without the key setters.  Two structs with the same key are an error.")
+	out.WriteString("func (r *")
+	out.WriteString(regionStructName(r))
+	out.WriteString(") Reindex() error {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteLine("var err error")
+	for _, s := range r.Structs {
+		if s.Key != nil {
+			out.WriteString("err = r.")
+			out.WriteString(keyIndexMethod(r, s))
+			out.WriteString("()")
+			out.EndOfLine()
+			abortDeserializeOnError(out)
+		}
+	}
+	out.WriteLine("return nil")
+	out.Dedent()
+	out.WriteLine("}")
+}
+
+func generateRegionValidate(r *runtime.RegionSchema, out *writer.TabbedWriter) {
+	out.EndOfLine()
+	out.WriteLine("// Check the region against the constraints in its schema.")
+	out.WriteString("func (r *")
+	out.WriteString(regionStructName(r))
+	out.WriteString(") Validate() error {")
+	out.EndOfLine()
+	out.Indent()
+	out.WriteLine("return runtime.Validate(r)")
+	out.Dedent()
+	out.WriteLine("}")
+}
+
 func generateRegionInit(r *runtime.RegionSchema, out *writer.TabbedWriter) {
 	for _, s := range r.Structs {
 		generateStructInit(r, s, out)
@@ -241,6 +635,36 @@ func generateRegionInit(r *runtime.RegionSchema, out *writer.TabbedWriter) {
 	out.Dedent()
 	out.WriteLine("}")
 
+	if len(r.Unions) > 0 {
+		out.WriteString(schemaName)
+		out.WriteString(".Unions = []*runtime.UnionSchema{")
+		out.EndOfLine()
+
+		out.Indent()
+		for _, u := range r.Unions {
+			out.WriteString(unionSchemaName(u))
+			out.WriteString(",")
+			out.EndOfLine()
+		}
+		out.Dedent()
+		out.WriteLine("}")
+	}
+
+	if len(r.Enums) > 0 {
+		out.WriteString(schemaName)
+		out.WriteString(".Enums = []*runtime.EnumSchema{")
+		out.EndOfLine()
+
+		out.Indent()
+		for _, e := range r.Enums {
+			out.WriteString(enumSchemaName(e))
+			out.WriteString(",")
+			out.EndOfLine()
+		}
+		out.Dedent()
+		out.WriteLine("}")
+	}
+
 	out.WriteString(schemaName)
 	out.WriteString(".Init()")
 	out.EndOfLine()
@@ -266,6 +690,17 @@ func GenerateSource(pkg string, regions []*runtime.RegionSchema, out *writer.Tab
 		generateRegionSerialize(r, out)
 		generateRegionDeserialize(r, out)
 		generateRegionCloner(r, out)
+		keyed := false
+		for _, s := range r.Structs {
+			if s.Key != nil {
+				generateRegionLookups(r, s, out)
+				keyed = true
+			}
+		}
+		if keyed {
+			generateRegionReindex(r, out)
+		}
+		generateRegionValidate(r, out)
 	}
 
 	// Init
@@ -1,14 +1,44 @@
 package haxe
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/ncbray/compilerutil/names"
+	"github.com/ncbray/compilerutil/writer"
 	"github.com/ncbray/rommy/runtime"
 )
 
+// Write a doc string from the schema as a Haxe doc comment.
+func writeDocComment(doc string, out *writer.TabbedWriter) {
+	if doc == "" {
+		return
+	}
+	// The doc string cannot end the comment early.
+	doc = strings.Replace(doc, "*/", "*\\/", -1)
+	out.WriteLine("/**")
+	for _, line := range strings.Split(doc, "\n") {
+		out.WriteLine(strings.TrimRight(" * "+line, " "))
+	}
+	out.WriteLine(" */")
+}
+
 func structName(s *runtime.StructSchema) string {
 	return s.Name
 }
 
+func enumName(e *runtime.EnumSchema) string {
+	return e.Name
+}
+
+func unionName(u *runtime.UnionSchema) string {
+	return u.Name
+}
+
+func enumValueName(value string) string {
+	return names.JoinCamelCase(names.SplitSnakeCase(value), true)
+}
+
 func fieldName(f *runtime.FieldSchema) string {
 	return names.JoinCamelCase(names.SplitSnakeCase(f.Name), false)
 }
@@ -41,8 +71,41 @@ func haxeTypeRef(t runtime.TypeSchema) string {
 		return "Bool"
 	case *runtime.StructSchema:
 		return structName(t)
+	case *runtime.EnumSchema:
+		return enumName(t)
+	case *runtime.OptionalSchema:
+		return "Null<" + haxeTypeRef(t.Element) + ">"
+	case *runtime.UnionSchema:
+		return unionName(t)
 	case *runtime.ListSchema:
 		return "Array<" + haxeTypeRef(t.Element) + ">"
+	case *runtime.ArraySchema:
+		return "Array<" + haxeTypeRef(t.Element) + ">"
+	case *runtime.BytesSchema:
+		return "haxe.io.Bytes"
+	case *runtime.MapSchema:
+		return "Map<" + haxeTypeRef(t.Key) + ", " + haxeTypeRef(t.Value) + ">"
+	default:
+		panic(t)
+	}
+}
+
+// A Haxe literal for a default value.
+func haxeConstant(v interface{}, t runtime.TypeSchema) string {
+	switch t := t.(type) {
+	case *runtime.IntegerSchema:
+		if t.Unsigned {
+			return strconv.FormatUint(v.(uint64), 10)
+		}
+		return strconv.FormatInt(v.(int64), 10)
+	case *runtime.FloatSchema:
+		return strconv.FormatFloat(v.(float64), 'g', -1, int(t.Bits))
+	case *runtime.StringSchema:
+		return strconv.Quote(v.(string))
+	case *runtime.BooleanSchema:
+		return strconv.FormatBool(v.(bool))
+	case *runtime.EnumSchema:
+		return enumName(t) + "." + enumValueName(t.Values[v.(int)])
 	default:
 		panic(t)
 	}
@@ -3,6 +3,7 @@ package haxe
 import (
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/ncbray/compilerutil/fs"
 	"github.com/ncbray/compilerutil/names"
@@ -14,12 +15,14 @@ func generateStruct(pkg string, r *runtime.RegionSchema, s *runtime.StructSchema
 	out.WriteLine("package " + pkg + ";")
 
 	out.EndOfLine()
+	writeDocComment(s.Doc, out)
 	out.WriteLine("class " + structName(s) + " {")
 	out.Indent()
 
 	// Fields
 	out.WriteLine("public var poolIndex:Int;")
 	for _, f := range s.Fields {
+		writeDocComment(f.Doc, out)
 		out.WriteLine("public var " + fieldName(f) + ":" + haxeTypeRef(f.Type) + ";")
 	}
 
@@ -35,6 +38,32 @@ func generateStruct(pkg string, r *runtime.RegionSchema, s *runtime.StructSchema
 	out.WriteLine("}")
 }
 
+func generateEnum(pkg string, e *runtime.EnumSchema, out *writer.TabbedWriter) {
+	out.WriteLine("package " + pkg + ";")
+
+	out.EndOfLine()
+	out.WriteLine("@:enum abstract " + enumName(e) + "(Int) {")
+	out.Indent()
+	for i, v := range e.Values {
+		out.WriteLine("var " + enumValueName(v) + " = " + strconv.Itoa(i) + ";")
+	}
+	out.Dedent()
+	out.WriteLine("}")
+}
+
+func generateUnion(pkg string, u *runtime.UnionSchema, out *writer.TabbedWriter) {
+	out.WriteLine("package " + pkg + ";")
+
+	out.EndOfLine()
+	out.WriteLine("enum " + unionName(u) + " {")
+	out.Indent()
+	for _, s := range u.Arms {
+		out.WriteLine(structName(s) + "(value:" + structName(s) + ");")
+	}
+	out.Dedent()
+	out.WriteLine("}")
+}
+
 func abortDeserializeOnError(out *writer.TabbedWriter) {
 	out.WriteLine("if (d.hasErrored()){")
 	out.Indent()
@@ -57,11 +86,29 @@ func deserialize(path string, level int, r *runtime.RegionSchema, t runtime.Type
 	case *runtime.BooleanSchema:
 		out.WriteLine(path + " = d.readBool();")
 		abortDeserializeOnError(out)
+	case *runtime.BytesSchema:
+		out.WriteLine(path + " = d.readBytes();")
+		abortDeserializeOnError(out)
 	case *runtime.StructSchema:
 		pf := poolField(r, t)
 		out.WriteLine("index = d.readIndex(" + pf + ".length);")
 		abortDeserializeOnError(out)
 		out.WriteLine(path + " = " + pf + "[index];")
+	case *runtime.UnionSchema:
+		deserializeUnion(path, r, t, false, out)
+	case *runtime.OptionalSchema:
+		if u, ok := t.Element.(*runtime.UnionSchema); ok {
+			deserializeUnion(path, r, u, true, out)
+			break
+		}
+		// Index zero is reserved for null.
+		pf := poolField(r, t.Element.(*runtime.StructSchema))
+		out.WriteLine("index = d.readIndex(" + pf + ".length + 1);")
+		abortDeserializeOnError(out)
+		out.WriteLine(path + " = index == 0 ? null : " + pf + "[index - 1];")
+	case *runtime.EnumSchema:
+		out.WriteLine(path + " = cast d.readIndex(" + strconv.Itoa(len(t.Values)) + ");")
+		abortDeserializeOnError(out)
 	case *runtime.ListSchema:
 		out.WriteLine("index = d.readCount();")
 		abortDeserializeOnError(out)
@@ -74,11 +121,90 @@ func deserialize(path string, level int, r *runtime.RegionSchema, t runtime.Type
 		deserialize(path+"["+child_index+"]", level+1, r, t.Element, out)
 		out.Dedent()
 		out.WriteLine("}")
+	case *runtime.ArraySchema:
+		// The length is part of the type.
+		child_index := "i" + strconv.Itoa(level)
+		out.WriteLine(path + " = new " + haxeTypeRef(t) + "();")
+		out.WriteLine("for (" + child_index + " in 0..." + strconv.Itoa(t.Length) + ") {")
+		out.Indent()
+		// HACK
+		out.WriteLine(path + ".push(null);")
+		deserialize(path+"["+child_index+"]", level+1, r, t.Element, out)
+		out.Dedent()
+		out.WriteLine("}")
+	case *runtime.MapSchema:
+		out.WriteLine("index = d.readCount();")
+		abortDeserializeOnError(out)
+		child_index := "i" + strconv.Itoa(level)
+		key_path := "k" + strconv.Itoa(level)
+		value_path := "v" + strconv.Itoa(level)
+		out.WriteLine(path + " = new " + haxeTypeRef(t) + "();")
+		out.WriteLine("for (" + child_index + " in 0...index) {")
+		out.Indent()
+		out.WriteLine("var " + key_path + ":" + haxeTypeRef(t.Key) + ";")
+		out.WriteLine("var " + value_path + ":" + haxeTypeRef(t.Value) + ";")
+		deserialize(key_path, level+1, r, t.Key, out)
+		deserialize(value_path, level+1, r, t.Value, out)
+		// Reject duplicate keys, like the Go reader.
+		out.WriteLine("if (" + path + ".exists(" + key_path + ")) {")
+		out.Indent()
+		out.WriteLine("return false;")
+		out.Dedent()
+		out.WriteLine("}")
+		out.WriteLine(path + ".set(" + key_path + ", " + value_path + ");")
+		out.Dedent()
+		out.WriteLine("}")
 	default:
 		panic(t)
 	}
 }
 
+// Read the tag of the arm, then the index of the struct in its pool.  Optional
+// unions reserve tag zero for null.
+func deserializeUnion(path string, r *runtime.RegionSchema, t *runtime.UnionSchema, optional bool, out *writer.TabbedWriter) {
+	offset := 0
+	if optional {
+		offset = 1
+		out.WriteLine(path + " = null;")
+	}
+	out.WriteLine("index = d.readIndex(" + strconv.Itoa(len(t.Arms)+offset) + ");")
+	abortDeserializeOnError(out)
+	out.WriteLine("switch (index) {")
+	out.Indent()
+	for i, s := range t.Arms {
+		pf := poolField(r, s)
+		out.WriteLine("case " + strconv.Itoa(i+offset) + ":")
+		out.Indent()
+		out.WriteLine("index = d.readIndex(" + pf + ".length);")
+		abortDeserializeOnError(out)
+		out.WriteLine(path + " = " + unionName(t) + "." + structName(s) + "(" + pf + "[index]);")
+		out.Dedent()
+	}
+	out.Dedent()
+	out.WriteLine("}")
+}
+
+// Reject data that was not written with the schema the code is generated from,
+// see runtime.WriteHeader.
+func generateHeaderCheck(r *runtime.RegionSchema, out *writer.TabbedWriter) {
+	s := runtime.MakeSerializer()
+	runtime.WriteHeader(s, r)
+	header := []string{}
+	for _, b := range s.Data() {
+		header = append(header, strconv.Itoa(int(b)))
+	}
+	out.EndOfLine()
+	out.WriteLine("for (b in [" + strings.Join(header, ", ") + "]) {")
+	out.Indent()
+	out.WriteLine("if (d.readUint8() != b) {")
+	out.Indent()
+	out.WriteLine("return false;")
+	out.Dedent()
+	out.WriteLine("}")
+	out.Dedent()
+	out.WriteLine("}")
+}
+
 func generateRegion(pkg string, r *runtime.RegionSchema, out *writer.TabbedWriter) {
 	out.WriteLine("package " + pkg + ";")
 
@@ -87,6 +213,7 @@ func generateRegion(pkg string, r *runtime.RegionSchema, out *writer.TabbedWrite
 	out.WriteLine("import rommy.runtime.Deserializer;")
 
 	out.EndOfLine()
+	writeDocComment(r.Doc, out)
 	out.WriteLine("class " + regionName(r) + " {")
 	out.Indent()
 
@@ -112,6 +239,11 @@ func generateRegion(pkg string, r *runtime.RegionSchema, out *writer.TabbedWrite
 		out.WriteLine("public function allocate" + structName(s) + "():" + haxeTypeRef(s) + " {")
 		out.Indent()
 		out.WriteLine("var o = new " + haxeTypeRef(s) + "();")
+		for _, f := range s.Fields {
+			if f.Default != nil {
+				out.WriteLine("o." + fieldName(f) + " = " + haxeConstant(f.Default, f.Type) + ";")
+			}
+		}
 		out.WriteLine("o.poolIndex = " + pf + ".length;")
 		out.WriteLine(pf + ".push(o);")
 		out.WriteLine("return o;")
@@ -125,6 +257,7 @@ func generateRegion(pkg string, r *runtime.RegionSchema, out *writer.TabbedWrite
 	out.Indent()
 	out.WriteLine("var d = new Deserializer(data);")
 	out.WriteLine("var index:Int;")
+	generateHeaderCheck(r, out)
 	for _, s := range r.Structs {
 		out.EndOfLine()
 		out.WriteLine("index = d.readCount();")
@@ -155,6 +288,26 @@ func generateRegion(pkg string, r *runtime.RegionSchema, out *writer.TabbedWrite
 
 func GenerateSources(input_file string, regions []*runtime.RegionSchema, output_dir string, pkg string, buffered fs.BufferedFileSystem) error {
 	for _, r := range regions {
+		for _, e := range r.Enums {
+			outf := buffered.OutputFile(filepath.Join(output_dir, enumName(e)+".hx"), 0644)
+			ow, err := outf.GetWriter()
+			if err != nil {
+				return err
+			}
+			defer ow.Close()
+			out := writer.MakeTabbedWriter("\t", ow)
+			generateEnum(pkg, e, out)
+		}
+		for _, u := range r.Unions {
+			outf := buffered.OutputFile(filepath.Join(output_dir, unionName(u)+".hx"), 0644)
+			ow, err := outf.GetWriter()
+			if err != nil {
+				return err
+			}
+			defer ow.Close()
+			out := writer.MakeTabbedWriter("\t", ow)
+			generateUnion(pkg, u, out)
+		}
 		for _, s := range r.Structs {
 			outf := buffered.OutputFile(filepath.Join(output_dir, structName(s)+".hx"), 0644)
 			ow, err := outf.GetWriter()
@@ -6,16 +6,27 @@ import (
 
 type Expr interface {
 	isExpr()
+	Attached() *parser.Comments
 }
 
 type Integer struct {
+	parser.Comments
 	Raw parser.SourceString
 }
 
 func (node *Integer) isExpr() {
 }
 
+type Float struct {
+	parser.Comments
+	Raw parser.SourceString
+}
+
+func (node *Float) isExpr() {
+}
+
 type Boolean struct {
+	parser.Comments
 	Loc   parser.Location
 	Value bool
 }
@@ -24,6 +35,7 @@ func (node *Boolean) isExpr() {
 }
 
 type String struct {
+	parser.Comments
 	Raw   parser.SourceString
 	Value string
 }
@@ -31,7 +43,20 @@ type String struct {
 func (node *String) isExpr() {
 }
 
+// Bytes literal, written as x"hex" or b64"base64".
+type Bytes struct {
+	parser.Comments
+	Raw   parser.SourceString
+	Value []byte
+}
+
+func (node *Bytes) isExpr() {
+}
+
+// KeywordArg sets a field of a struct.  A field that could not be parsed has
+// no name, and its text is held by a *Bad value.
 type KeywordArg struct {
+	parser.Comments
 	Name  parser.SourceString
 	Value Expr
 }
@@ -40,19 +65,112 @@ type TypeRef struct {
 	Raw parser.SourceString
 }
 
+// Label names a struct so it can be referenced elsewhere.
+type Label struct {
+	Raw parser.SourceString
+}
+
 type Struct struct {
-	Type *TypeRef
-	Loc  parser.Location
-	Args []*KeywordArg
+	parser.Comments
+	Label *Label
+	Type  *TypeRef
+	Loc   parser.Location
+	Args  []*KeywordArg
+	// Comments after the last argument.
+	Dangling []*parser.Comment
 }
 
 func (node *Struct) isExpr() {
 }
 
 type List struct {
+	parser.Comments
 	Loc  parser.Location
 	Args []Expr
+	// Comments after the last element.
+	Dangling []*parser.Comment
 }
 
 func (node *List) isExpr() {
 }
+
+// Map literal, written as [key: value, ...] or [:] when empty.
+type Map struct {
+	parser.Comments
+	Loc     parser.Location
+	Entries []*MapEntry
+	// Comments after the last entry.
+	Dangling []*parser.Comment
+}
+
+func (node *Map) isExpr() {
+}
+
+type MapEntry struct {
+	parser.Comments
+	Key   Expr
+	Value Expr
+}
+
+// Reference refers to a labeled struct.
+type Reference struct {
+	parser.Comments
+	Raw  parser.SourceString
+	Name parser.SourceString
+}
+
+func (node *Reference) isExpr() {
+}
+
+// KeyReference refers to a struct by the value of its key field, such as
+// &"iron_sword".
+type KeyReference struct {
+	parser.Comments
+	Raw parser.SourceString
+	// A string or number literal.
+	Key Expr
+}
+
+func (node *KeyReference) isExpr() {
+}
+
+// Null is the absent value of an optional reference.
+type Null struct {
+	parser.Comments
+	Loc parser.Location
+}
+
+func (node *Null) isExpr() {
+}
+
+// Symbol is a bare identifier, such as an enum value.
+type Symbol struct {
+	parser.Comments
+	Raw parser.SourceString
+}
+
+func (node *Symbol) isExpr() {
+}
+
+// Bad is text that could not be parsed.  The error has been reported, and
+// parsing continued after it.
+type Bad struct {
+	parser.Comments
+	Raw parser.SourceString
+}
+
+func (node *Bad) isExpr() {
+}
+
+// Include pulls another data file into the project.
+type Include struct {
+	parser.Comments
+	Loc  parser.Location
+	Path *String
+}
+
+// Document is the contents of a single data file.
+type Document struct {
+	Includes []*Include
+	Root     Expr
+}
@@ -0,0 +1,76 @@
+package human
+
+import (
+	"fmt"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+)
+
+// Evaluate a literal of a scalar type, such as the default value of a field.
+// The result has the representation used by runtime.FieldSchema.Default.
+func ConstantValue(node Expr, t runtime.TypeSchema, status *parser.Status) (interface{}, bool) {
+	switch t.(type) {
+	case *runtime.IntegerSchema, *runtime.FloatSchema, *runtime.StringSchema, *runtime.BooleanSchema, *runtime.EnumSchema:
+	default:
+		status.Error(Location(node), fmt.Sprintf("type %s cannot have a default value", t.CanonicalName()))
+		return nil, false
+	}
+	switch node.(type) {
+	case *Integer, *Float, *String, *Boolean, *Symbol:
+	default:
+		status.Error(Location(node), "expected a literal")
+		return nil, false
+	}
+
+	c := createDataContext(nil, status)
+	rv, ok := c.handleData(node, t)
+	if !ok {
+		return nil, false
+	}
+	switch t := t.(type) {
+	case *runtime.IntegerSchema:
+		if t.Unsigned {
+			return rv.Uint(), true
+		}
+		return rv.Int(), true
+	case *runtime.FloatSchema:
+		return rv.Float(), true
+	case *runtime.EnumSchema:
+		return int(rv.Uint()), true
+	default:
+		return rv.Interface(), true
+	}
+}
+
+// Parse and evaluate a literal of a scalar type.
+func ParseConstant(info *parser.SourceInfo, input []byte, t runtime.TypeSchema, status *parser.Status) (interface{}, bool) {
+	node := ParseData(info, input, status)
+	if status.ShouldStop() {
+		return nil, false
+	}
+	return ConstantValue(node, t, status)
+}
+
+// The value of a field when it is not set explicitly.
+func defaultValue(f *runtime.FieldSchema) interface{} {
+	if f.Default != nil {
+		return f.Default
+	}
+	switch t := f.Type.(type) {
+	case *runtime.IntegerSchema:
+		if t.Unsigned {
+			return uint64(0)
+		}
+		return int64(0)
+	case *runtime.FloatSchema:
+		return float64(0)
+	case *runtime.StringSchema:
+		return ""
+	case *runtime.BooleanSchema:
+		return false
+	case *runtime.EnumSchema:
+		return 0
+	default:
+		return nil
+	}
+}
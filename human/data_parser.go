@@ -1,94 +1,53 @@
 package human
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"github.com/ncbray/rommy/parser"
 )
 
 func punc(state *parser.RuneParserState, value rune) bool {
-	if state.Is(value) {
-		state.GetNext()
-		return true
-	} else {
-		return false
-	}
+	return parser.Punc(state, value)
 }
 
 func s(state *parser.RuneParserState) {
-	for state.IsSpace() {
-		state.GetNext()
-	}
+	parser.SkipComments(state)
+}
+
+// Move the comments leading a value, and any comments inside the syntax that
+// introduces it, so they lead the node that holds the value.
+func hoistComments(c *parser.Comments, inner []*parser.Comment, value Expr) {
+	c.Leading = append(c.Leading, inner...)
+	v := value.Attached()
+	c.Leading = append(c.Leading, v.Leading...)
+	v.Leading = nil
 }
 
 func identifier(state *parser.RuneParserState) (parser.SourceString, bool) {
-	if state.IsLetter() || state.Is('_') {
-		begin := state.Position()
-		state.GetNext()
-		for state.IsLetter() || state.IsDigit() || state.Is('_') {
-			state.GetNext()
-		}
-		return state.Slice(begin), true
-	} else {
-		return parser.SourceString{}, false
-	}
+	return parser.Identifier(state)
 }
 
 func parseKeywordArg(state *parser.RuneParserState) (*KeywordArg, bool) {
 	name, ok := identifier(state)
 	if !ok {
+		state.Expected("field name")
 		return nil, false
 	}
-	s(state)
+	inner := parser.SkipComments(state)
 	if !state.Is(':') {
+		state.Expected("':' after field name")
 		return nil, false
 	}
 	state.GetNext()
-	s(state)
+	inner = append(inner, parser.SkipComments(state)...)
 
 	expr, ok := parseExpr(state)
 	if !ok {
 		return nil, false
 	}
-	return &KeywordArg{Name: name, Value: expr}, true
-}
-
-func parseBoolean(state *parser.RuneParserState) (*Boolean, bool) {
-	p := state.Position()
-	if state.Is('t') {
-		state.GetNext()
-		if !state.Is('r') {
-			return nil, false
-		}
-		state.GetNext()
-		if !state.Is('u') {
-			return nil, false
-		}
-		state.GetNext()
-		if !state.Is('e') {
-			return nil, false
-		}
-		state.GetNext()
-		return &Boolean{Loc: state.Slice(p).Loc, Value: true}, true
-	} else if state.Is('f') {
-		state.GetNext()
-		if !state.Is('a') {
-			return nil, false
-		}
-		state.GetNext()
-		if !state.Is('l') {
-			return nil, false
-		}
-		state.GetNext()
-		if !state.Is('s') {
-			return nil, false
-		}
-		state.GetNext()
-		if !state.Is('e') {
-			return nil, false
-		}
-		state.GetNext()
-		return &Boolean{Loc: state.Slice(p).Loc, Value: false}, true
-	}
-	return nil, false
+	arg := &KeywordArg{Name: name, Value: expr}
+	hoistComments(&arg.Comments, inner, expr)
+	return arg, true
 }
 
 func parseString(state *parser.RuneParserState) (*String, bool) {
@@ -102,7 +61,6 @@ func parseString(state *parser.RuneParserState) (*String, bool) {
 		state.GetNext()
 		if current == '\\' {
 			current = state.Peek()
-			state.GetNext()
 			switch current {
 			case '"', '\\':
 				// Pass through
@@ -111,168 +69,416 @@ func parseString(state *parser.RuneParserState) (*String, bool) {
 			case 't':
 				current = '\t'
 			default:
+				state.Expected("escape sequence \\\", \\\\, \\n, or \\t")
 				return nil, false
 			}
+			state.GetNext()
 		}
 		value = append(value, current)
 	}
 	if !punc(state, '"') {
+		state.Expected("'\"' to end string")
 		return nil, false
 	}
 	return &String{Raw: state.Slice(begin), Value: string(value)}, true
 }
 
-func parseKeywordArgList(state *parser.RuneParserState) ([]*KeywordArg, bool) {
+// Parse the quoted part of a bytes literal, after the prefix.
+func parseBytes(state *parser.RuneParserState, begin parser.RuneStreamPos, prefix string) (*Bytes, bool) {
+	if !punc(state, '"') {
+		return nil, false
+	}
+	textBegin := state.Position()
+	text := []rune{}
+	for !state.IsEndOfStream() && !state.Is('"') {
+		text = append(text, state.Peek())
+		state.GetNext()
+	}
+	end := state.Position()
+	if !punc(state, '"') {
+		state.Expected("'\"' to end bytes")
+		return nil, false
+	}
+	var value []byte
+	var err error
+	var expected string
+	switch prefix {
+	case "x":
+		value, err = hex.DecodeString(string(text))
+		expected = "an even number of hex digits"
+	case "b64":
+		value, err = base64.StdEncoding.DecodeString(string(text))
+		expected = "base64 data"
+	default:
+		panic(prefix)
+	}
+	if err != nil {
+		state.Seek(textBegin)
+		state.Expected(expected)
+		state.Seek(end)
+		return nil, false
+	}
+	return &Bytes{Raw: state.Slice(begin), Value: value}, true
+}
+
+func parseStruct(state *parser.RuneParserState, t *TypeRef) (*Struct, bool) {
+	begin := state.Position()
+	if !punc(state, '{') {
+		state.Expected("'{'")
+		return nil, false
+	}
+	loc := state.Slice(begin).Loc
 	args := []*KeywordArg{}
-	parser.Optional(state, func(state *parser.RuneParserState) bool {
+	dangling := parser.RecoveringCommaSeparated(state, func(state *parser.RuneParserState) (*parser.Comments, bool) {
 		arg, ok := parseKeywordArg(state)
 		if !ok {
-			return false
+			return nil, false
 		}
 		args = append(args, arg)
-		parser.Repeat(state, func(state *parser.RuneParserState) bool {
-			s(state)
-			if !punc(state, ',') {
-				return false
-			}
-			s(state)
-			arg, ok := parseKeywordArg(state)
-			if !ok {
-				return false
-			}
-			args = append(args, arg)
-			return true
-		})
-		// Trailing comma
-		parser.Optional(state, func(state *parser.RuneParserState) bool {
-			s(state)
-			if !punc(state, ',') {
-				return false
-			}
-			return true
-		})
-		return true
+		return &arg.Comments, true
+	}, func(skipped parser.SourceString) {
+		args = append(args, &KeywordArg{Value: &Bad{Raw: skipped}})
 	})
-	return args, true
+	if !punc(state, '}') {
+		state.Expected("'}' to end struct")
+		return nil, false
+	}
+	return &Struct{Type: t, Loc: loc, Args: args, Dangling: dangling}, true
 }
 
-func optionalTypeRef(state *parser.RuneParserState) (*TypeRef, bool) {
-	var name parser.SourceString
-	var ok bool
-	parser.Optional(state, func(state *parser.RuneParserState) bool {
-		name, ok = identifier(state)
-		return ok
-	})
+func parseLabeledStruct(state *parser.RuneParserState, label *Label, inner []*parser.Comment) (*Struct, bool) {
+	var t *TypeRef
+	name, ok := identifier(state)
 	if ok {
-		return &TypeRef{Raw: name}, true
-	} else {
+		t = &TypeRef{Raw: name}
+		inner = append(inner, parser.SkipComments(state)...)
+	}
+	node, ok := parseStruct(state, t)
+	if !ok {
 		return nil, false
 	}
+	node.Label = label
+	node.Leading = inner
+	return node, true
 }
 
-func parseStruct(state *parser.RuneParserState) (*Struct, bool) {
-	t, ok := optionalTypeRef(state)
-	if ok {
-		s(state)
+// Parse an expression that starts with an identifier.
+func parseNamed(state *parser.RuneParserState) (Expr, bool) {
+	begin := state.Position()
+	name, ok := identifier(state)
+	if !ok {
+		return nil, false
+	}
+	if (name.Text == "x" || name.Text == "b64") && state.Is('"') {
+		return parseBytes(state, begin, name.Text)
+	}
+	switch name.Text {
+	case "true":
+		return &Boolean{Loc: name.Loc, Value: true}, true
+	case "false":
+		return &Boolean{Loc: name.Loc, Value: false}, true
+	case "null":
+		return &Null{Loc: name.Loc}, true
 	}
+	end := state.Position()
+	// Comments between the name and the struct lead the struct.
+	inner := parser.SkipComments(state)
+	switch {
+	case punc(state, '='):
+		inner = append(inner, parser.SkipComments(state)...)
+		return parseLabeledStruct(state, &Label{Raw: name}, inner)
+	case state.Is('{'):
+		node, ok := parseStruct(state, &TypeRef{Raw: name})
+		if ok {
+			node.Leading = inner
+		}
+		return node, ok
+	default:
+		// Leave any comments for the caller to attach.
+		state.Recover(end)
+		return &Symbol{Raw: name}, true
+	}
+}
+
+func parseReference(state *parser.RuneParserState) (*Reference, bool) {
 	begin := state.Position()
-	if !punc(state, '{') {
+	if !punc(state, '@') {
 		return nil, false
 	}
-	loc := state.Slice(begin).Loc
-	s(state)
-	args, ok := parseKeywordArgList(state)
+	name, ok := identifier(state)
 	if !ok {
+		state.Expected("label name after '@'")
 		return nil, false
 	}
-	s(state)
-	if !punc(state, '}') {
+	return &Reference{Raw: state.Slice(begin), Name: name}, true
+}
+
+func parseKeyReference(state *parser.RuneParserState) (*KeyReference, bool) {
+	begin := state.Position()
+	if !punc(state, '&') {
+		return nil, false
+	}
+	var key Expr
+	var ok bool
+	switch {
+	case state.Is('"'):
+		key, ok = parseString(state)
+	case state.IsDigit() || state.Is('-') || state.Is('+'):
+		key, ok = parseNumber(state)
+	default:
+		state.Expected("string or number after '&'")
 		return nil, false
 	}
-	return &Struct{Type: t, Loc: loc, Args: args}, true
+	if !ok {
+		return nil, false
+	}
+	return &KeyReference{Raw: state.Slice(begin), Key: key}, true
 }
 
-func parseList(state *parser.RuneParserState) (*List, bool) {
+// Parse a list, or a map if the elements are key: value pairs.
+func parseListOrMap(state *parser.RuneParserState) (Expr, bool) {
 	begin := state.Position()
 	if !punc(state, '[') {
 		return nil, false
 	}
 	loc := state.Slice(begin).Loc
-	s(state)
+
+	empty := state.Position()
+	dangling := parser.SkipComments(state)
+	if punc(state, ':') {
+		dangling = append(dangling, parser.SkipComments(state)...)
+		if !punc(state, ']') {
+			state.Expected("']' to end map")
+			return nil, false
+		}
+		return &Map{Loc: loc, Dangling: dangling}, true
+	}
+	state.Seek(empty)
 
 	args := []Expr{}
-	parser.Optional(state, func(state *parser.RuneParserState) bool {
+	elements := 0
+	entries := []*MapEntry{}
+	// Elements that cannot be parsed become Bad nodes, entries are dropped.
+	dangling = parser.RecoveringCommaSeparated(state, func(state *parser.RuneParserState) (*parser.Comments, bool) {
 		arg, ok := parseExpr(state)
 		if !ok {
-			return false
+			return nil, false
 		}
-		args = append(args, arg)
-		parser.Repeat(state, func(state *parser.RuneParserState) bool {
-			s(state)
-			if !punc(state, ',') {
-				return false
-			}
-			s(state)
-			arg, ok := parseExpr(state)
-			if !ok {
-				return false
+		end := state.Position()
+		inner := parser.SkipComments(state)
+		if !state.Is(':') {
+			// Leave any comments for the caller to attach.
+			state.Seek(end)
+			if len(entries) > 0 {
+				state.Expected("':' after map key")
+				return nil, false
 			}
 			args = append(args, arg)
-			return true
-		})
+			elements += 1
+			return arg.Attached(), true
+		}
+		if elements > 0 {
+			state.Expected("',' between list elements")
+			return nil, false
+		}
+		state.GetNext()
+		inner = append(inner, parser.SkipComments(state)...)
+		value, ok := parseExpr(state)
+		if !ok {
+			return nil, false
+		}
+		entry := &MapEntry{Key: arg, Value: value}
+		entry.Leading = append(entry.Leading, arg.Attached().Leading...)
+		arg.Attached().Leading = nil
+		hoistComments(&entry.Comments, inner, value)
+		entries = append(entries, entry)
+		return &entry.Comments, true
+	}, func(skipped parser.SourceString) {
+		args = append(args, &Bad{Raw: skipped})
+	})
+	if !punc(state, ']') {
+		state.Expected("']'")
+		return nil, false
+	}
+	if len(entries) > 0 {
+		return &Map{Loc: loc, Entries: entries, Dangling: dangling}, true
+	}
+	return &List{Loc: loc, Args: args, Dangling: dangling}, true
+}
+
+func isDecimalDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+func isHexDigit(r rune) bool {
+	return isDecimalDigit(r) || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+}
 
-		// Trailing comma
-		parser.Optional(state, func(state *parser.RuneParserState) bool {
-			s(state)
-			if !punc(state, ',') {
+func isOctalDigit(r rune) bool {
+	return '0' <= r && r <= '7'
+}
+
+func isBinaryDigit(r rune) bool {
+	return r == '0' || r == '1'
+}
+
+// One or more digits, optionally separated by single underscores.
+func digits(state *parser.RuneParserState, isDigit func(rune) bool) bool {
+	if !isDigit(state.Peek()) {
+		state.Expected("digit")
+		return false
+	}
+	state.GetNext()
+	for {
+		if punc(state, '_') {
+			if !isDigit(state.Peek()) {
+				state.Expected("digit after '_'")
 				return false
 			}
+		} else if !isDigit(state.Peek()) {
 			return true
-		})
-		return true
-	})
-	s(state)
-	if !punc(state, ']') {
+		}
+		state.GetNext()
+	}
+}
+
+func parseNumber(state *parser.RuneParserState) (Expr, bool) {
+	begin := state.Position()
+	if !punc(state, '-') {
+		punc(state, '+')
+	}
+	if state.IsLetter() {
+		name, ok := identifier(state)
+		if !ok || name.Text != "inf" {
+			state.Expected("digit or inf")
+			return nil, false
+		}
+		return &Float{Raw: state.Slice(begin)}, true
+	}
+	digitsBegin := state.Position()
+	if punc(state, '0') {
+		var isDigit func(rune) bool
+		switch {
+		case punc(state, 'x') || punc(state, 'X'):
+			isDigit = isHexDigit
+		case punc(state, 'o') || punc(state, 'O'):
+			isDigit = isOctalDigit
+		case punc(state, 'b') || punc(state, 'B'):
+			isDigit = isBinaryDigit
+		}
+		if isDigit != nil {
+			if !digits(state, isDigit) {
+				return nil, false
+			}
+			return &Integer{Raw: state.Slice(begin)}, true
+		}
+		state.Seek(digitsBegin)
+	}
+	if !digits(state, isDecimalDigit) {
 		return nil, false
 	}
-	return &List{Loc: loc, Args: args}, true
+	isFloat := false
+	if punc(state, '.') {
+		if !digits(state, isDecimalDigit) {
+			return nil, false
+		}
+		isFloat = true
+	}
+	if punc(state, 'e') || punc(state, 'E') {
+		if !punc(state, '-') {
+			punc(state, '+')
+		}
+		if !digits(state, isDecimalDigit) {
+			return nil, false
+		}
+		isFloat = true
+	}
+	if isFloat {
+		return &Float{Raw: state.Slice(begin)}, true
+	}
+	return &Integer{Raw: state.Slice(begin)}, true
 }
 
 func parseExpr(state *parser.RuneParserState) (Expr, bool) {
-	begin := state.Position()
 	switch {
-	case state.IsDigit():
-		state.GetNext()
-		for state.IsDigit() {
-			state.GetNext()
-		}
-		return &Integer{Raw: state.Slice(begin)}, true
-	case state.Is('t') || state.Is('f'):
-		// HACK types that start with "t" or "f" will choke.
-		return parseBoolean(state)
+	case state.IsDigit() || state.Is('-') || state.Is('+'):
+		return parseNumber(state)
 	case state.IsLetter() || state.Is('_'):
-		return parseStruct(state)
+		return parseNamed(state)
 	case state.Is('{'):
-		return parseStruct(state)
+		return parseStruct(state, nil)
+	case state.Is('@'):
+		return parseReference(state)
+	case state.Is('&'):
+		return parseKeyReference(state)
 	case state.Is('"'):
 		return parseString(state)
 	case state.Is('['):
-		return parseList(state)
+		return parseListOrMap(state)
 	default:
+		state.Expected("value")
 		return nil, false
 	}
 }
 
-// Parse text into an AST.
-func ParseData(info *parser.SourceInfo, input []byte, status *parser.Status) Expr {
-	state := parser.CreateRuneParser(info, input)
+// Parse a data expression embedded in another language.
+func ParseExpr(state *parser.RuneParserState) (Expr, bool) {
+	return parseExpr(state)
+}
+
+func parseInclude(state *parser.RuneParserState) (*Include, bool) {
+	keyword, ok := identifier(state)
+	if !ok || keyword.Text != "include" {
+		return nil, false
+	}
 	s(state)
+	path, ok := parseString(state)
+	if !ok {
+		return nil, false
+	}
+	return &Include{Loc: keyword.Loc, Path: path}, true
+}
+
+func parseRoot(state *parser.RuneParserState, status *parser.Status) Expr {
+	leading := parser.SkipComments(state)
 	e, ok := parseExpr(state)
 	if ok {
-		s(state)
+		c := e.Attached()
+		c.Leading = append(leading, c.Leading...)
+		c.Trailing = parser.SkipComments(state)
+		if !state.IsEndOfStream() {
+			state.Expected("end of input")
+		}
 	}
 	if !ok || !state.IsEndOfStream() {
-		status.Error(state.Deepest(), "unexpected character")
+		state.Fail()
 	}
+	state.ReportErrors(status)
 	return e
 }
+
+// Parse text into an AST.
+func ParseData(info *parser.SourceInfo, input []byte, status *parser.Status) Expr {
+	state := parser.CreateRuneParser(info, input)
+	return parseRoot(state, status)
+}
+
+// Parse a data file that may include other files.
+func ParseDocument(info *parser.SourceInfo, input []byte, status *parser.Status) *Document {
+	state := parser.CreateRuneParser(info, input)
+	doc := &Document{}
+	for {
+		begin := state.Position()
+		leading := parser.SkipComments(state)
+		include, ok := parseInclude(state)
+		if !ok {
+			state.Recover(begin)
+			break
+		}
+		include.Leading = leading
+		include.Trailing = parser.TrailingComments(state)
+		doc.Includes = append(doc.Includes, include)
+	}
+	doc.Root = parseRoot(state, status)
+	return doc
+}
@@ -8,7 +8,7 @@ import (
 
 func TestParseInteger(t *testing.T) {
 	sources := parser.CreateSourceSet()
-	status := &parser.Status{Sources: sources}
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
 	data := []byte("123")
 	info := sources.Add("t", data)
 	e := ParseData(info, data, status)
@@ -19,7 +19,7 @@ func TestParseInteger(t *testing.T) {
 
 func TestParseConstructor(t *testing.T) {
 	sources := parser.CreateSourceSet()
-	status := &parser.Status{Sources: sources}
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
 	data := []byte("A{\n  foo: [1, 2],\n  bar: B{baz: \"wot\\n\\\"m8t?\\\"\"}\n}")
 	info := sources.Add("t", data)
 	e := ParseData(info, data, status)
@@ -56,3 +56,112 @@ func TestParseConstructor(t *testing.T) {
 		},
 	}, e)
 }
+
+func TestParseComments(t *testing.T) {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte("// head\n[\n  /* one */ 1, // after\n  2,\n  // tail\n]")
+	info := sources.Add("t", data)
+	e := ParseData(info, data, status)
+	assert.False(t, status.ShouldStop())
+	assert.Equal(t, &List{
+		Comments: parser.Comments{
+			Leading: []*parser.Comment{
+				{Raw: parser.SourceString{Loc: info.Location(0, 7), Text: "// head"}},
+			},
+		},
+		Loc: info.Location(8, 9),
+		Args: []Expr{
+			&Integer{
+				Comments: parser.Comments{
+					Leading: []*parser.Comment{
+						{Raw: parser.SourceString{Loc: info.Location(12, 21), Text: "/* one */"}, Newline: true},
+					},
+					Trailing: []*parser.Comment{
+						{Raw: parser.SourceString{Loc: info.Location(25, 33), Text: "// after"}},
+					},
+				},
+				Raw: parser.SourceString{Loc: info.Location(22, 23), Text: "1"},
+			},
+			&Integer{Raw: parser.SourceString{Loc: info.Location(36, 37), Text: "2"}},
+		},
+		Dangling: []*parser.Comment{
+			{Raw: parser.SourceString{Loc: info.Location(41, 48), Text: "// tail"}, Newline: true},
+		},
+	}, e)
+}
+
+func TestParseUnterminatedComment(t *testing.T) {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte("{a: 1 /* oops}")
+	info := sources.Add("t", data)
+	ParseData(info, data, status)
+	assert.True(t, status.ShouldStop())
+}
+
+func TestParseRecovers(t *testing.T) {
+	sources := parser.CreateSourceSet()
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(sources, sink)
+	data := []byte("Node {\n  name \"a\",\n  next: @,\n  children: [1, %, {a: 1 b: 2}],\n  c: 1.,\n}")
+	info := sources.Add("t", data)
+	e := ParseData(info, data, status)
+	assert.True(t, status.ShouldStop())
+	assert.Equal(t, []string{
+		"expected ':' after field name",
+		"expected label name after '@'",
+		"expected value",
+		"expected ','",
+		"expected digit",
+	}, sink.Messages())
+	lines := []int{}
+	for _, d := range sink.Diagnostics {
+		lines = append(lines, d.Span.Begin.Line)
+	}
+	assert.Equal(t, []int{2, 3, 4, 4, 5}, lines)
+
+	// The parts that could be parsed are kept, and the rest are bad nodes.
+	s, ok := e.(*Struct)
+	assert.True(t, ok)
+	assert.Len(t, s.Args, 4)
+	bad := []string{}
+	for _, i := range []int{0, 1, 3} {
+		assert.Equal(t, "", s.Args[i].Name.Text)
+		bad = append(bad, s.Args[i].Value.(*Bad).Raw.Text)
+	}
+	assert.Equal(t, []string{`name "a"`, "next: @", "c: 1."}, bad)
+	assert.Equal(t, "children", s.Args[2].Name.Text)
+	l, ok := s.Args[2].Value.(*List)
+	assert.True(t, ok)
+	assert.Len(t, l.Args, 3)
+	assert.IsType(t, &Integer{}, l.Args[0])
+	assert.Equal(t, &Bad{Raw: parser.SourceString{Loc: info.Location(46, 47), Text: "%"}}, l.Args[1])
+	assert.IsType(t, &Struct{}, l.Args[2])
+}
+
+func TestParseExpected(t *testing.T) {
+	for _, c := range []struct {
+		text     string
+		messages []string
+	}{
+		{"[1, 2: 3]", []string{"expected ',' between list elements"}},
+		{"[1: 2, 3]", []string{"expected ':' after map key"}},
+		{"{a: 1", []string{"expected '}' to end struct"}},
+		{"{a: [1, 2}", []string{"expected ']'", "expected '}' to end struct"}},
+		{"Node {} junk", []string{"expected end of input"}},
+		{"{a: x\"0\"}", []string{"expected an even number of hex digits"}},
+		{"\"abc", []string{"expected '\"' to end string"}},
+		{"", []string{"expected value"}},
+		{"&slime", []string{"expected string or number after '&'"}},
+		{"-nan", []string{"expected digit or inf"}},
+	} {
+		sources := parser.CreateSourceSet()
+		sink := &parser.MemorySink{}
+		status := parser.CreateStatus(sources, sink)
+		data := []byte(c.text)
+		info := sources.Add("t", data)
+		ParseData(info, data, status)
+		assert.Equal(t, c.messages, sink.Messages(), c.text)
+	}
+}
@@ -0,0 +1,54 @@
+package human
+
+import (
+	"github.com/ncbray/rommy/parser"
+)
+
+type fieldKey struct {
+	object interface{}
+	name   string
+}
+
+// Locations records where structs, and the fields set on them, were defined in
+// the source, so later passes can report problems with the data.  A nil
+// Locations knows nothing.
+type Locations struct {
+	structs map[interface{}]parser.Location
+	fields  map[fieldKey]parser.Location
+}
+
+func CreateLocations() *Locations {
+	return &Locations{
+		structs: map[interface{}]parser.Location{},
+		fields:  map[fieldKey]parser.Location{},
+	}
+}
+
+func (l *Locations) SetStruct(o interface{}, loc parser.Location) {
+	l.structs[o] = loc
+}
+
+func (l *Locations) SetField(o interface{}, name string, loc parser.Location) {
+	l.fields[fieldKey{object: o, name: name}] = loc
+}
+
+// Where a struct was defined, or the zero Location if unknown.
+func (l *Locations) Struct(o interface{}) parser.Location {
+	if l == nil {
+		return parser.Location{}
+	}
+	return l.structs[o]
+}
+
+// Where a field was set, or where its struct was defined if the field was not
+// set explicitly.
+func (l *Locations) Field(o interface{}, name string) parser.Location {
+	if l == nil {
+		return parser.Location{}
+	}
+	loc, ok := l.fields[fieldKey{object: o, name: name}]
+	if !ok {
+		return l.structs[o]
+	}
+	return loc
+}
@@ -0,0 +1,122 @@
+package human
+
+import (
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+type loadState int
+
+const (
+	loading loadState = iota
+	loaded
+)
+
+type projectLoader struct {
+	status *parser.Status
+	state  map[string]loadState
+	// The files currently being loaded, used to describe include cycles.
+	stack []string
+	// Documents ordered so that every file comes after the files it includes.
+	documents []*Document
+}
+
+func (l *projectLoader) load(file string, data []byte) bool {
+	l.state[file] = loading
+	l.stack = append(l.stack, file)
+
+	info := l.status.Sources.Add(file, data)
+	doc := ParseDocument(info, data, l.status)
+	all_ok := !l.status.ShouldStop()
+
+	for _, include := range doc.Includes {
+		path := include.Path.Value
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		path = filepath.Clean(path)
+
+		state, ok := l.state[path]
+		if ok {
+			if state == loading {
+				cycle := []string{}
+				for i := len(l.stack) - 1; i >= 0; i-- {
+					cycle = append([]string{l.stack[i]}, cycle...)
+					if l.stack[i] == path {
+						break
+					}
+				}
+				cycle = append(cycle, path)
+				l.status.Error(include.Path.Raw.Loc, "include cycle: "+strings.Join(cycle, " -> "))
+				all_ok = false
+			}
+			continue
+		}
+		included, err := ioutil.ReadFile(path)
+		if err != nil {
+			l.status.Error(include.Path.Raw.Loc, "cannot read included file: "+err.Error())
+			all_ok = false
+			continue
+		}
+		all_ok = l.load(path, included) && all_ok
+	}
+
+	l.stack = l.stack[:len(l.stack)-1]
+	l.state[file] = loaded
+	l.documents = append(l.documents, doc)
+	return all_ok
+}
+
+// Parse a data file and every file it includes, and convert them into runtime
+// structures in a single region.  Included files are resolved relative to the
+// file that includes them.  Labels defined in any file can be referenced from
+// every other file.  Returns the root struct of the first file.
+func ParseProject(file string, data []byte, region runtime.Region, status *parser.Status) (runtime.Struct, bool) {
+	return loadProject(file, data, region, nil, status)
+}
+
+// Like ParseProject, but also records where each struct was defined.
+func ParseProjectWithLocations(file string, data []byte, region runtime.Region, status *parser.Status) (runtime.Struct, *Locations, bool) {
+	locations := CreateLocations()
+	result, ok := loadProject(file, data, region, locations, status)
+	return result, locations, ok
+}
+
+func loadProject(file string, data []byte, region runtime.Region, locations *Locations, status *parser.Status) (runtime.Struct, bool) {
+	l := &projectLoader{status: status, state: map[string]loadState{}}
+	if !l.load(filepath.Clean(file), data) {
+		return nil, false
+	}
+
+	c := createDataContext(region, status)
+	c.locations = locations
+	all_ok := true
+	for _, doc := range l.documents {
+		all_ok = c.collectLabels(doc.Root) && all_ok
+	}
+	if !all_ok {
+		return nil, false
+	}
+
+	// The main file is loaded last.
+	last := len(l.documents) - 1
+	for _, doc := range l.documents[:last] {
+		all_ok = c.handleIncluded(doc.Root) && all_ok
+	}
+	result, ok := c.toStruct(l.documents[last].Root, nil)
+	ok = c.checkKeys() && ok && all_ok
+	if r, indexed := region.(keyIndexer); indexed && ok {
+		// Two structs without a key are reported with locations by Validate.
+		r.Reindex()
+	}
+	return result, ok
+}
+
+// Generated regions with keyed structs index them for lookups.  The fields are
+// set directly, so the index is rebuilt once the data is loaded.
+type keyIndexer interface {
+	Reindex() error
+}
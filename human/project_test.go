@@ -0,0 +1,127 @@
+package human
+
+import (
+	"github.com/ncbray/rommy/internal/fixture"
+	"github.com/ncbray/rommy/parser"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProject(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "rommy_project")
+	assert.Nil(t, err)
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(text), 0644))
+	}
+	return dir
+}
+
+func parseProject(t *testing.T, dir string, file string) (*fixture.FixtureRegion, interface{}, bool) {
+	path := filepath.Join(dir, file)
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	region := fixture.CreateFixtureRegion()
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+	result, ok := ParseProject(path, data, region, status)
+	return region, result, ok
+}
+
+func TestIncludes(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"main.rommy": `include "parts/a.rommy"
+include "parts/b.rommy"
+
+Node {children: [@a, @b]}`,
+		"parts/a.rommy": `include "common.rommy"
+a = Node {name: "a", next: @common}`,
+		"parts/b.rommy": `include "common.rommy"
+[b = Node {name: "b", next: @common}, Node {name: "unlabeled"}]`,
+		"parts/common.rommy": `common = Node {name: "common"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	region, result, ok := parseProject(t, dir, "main.rommy")
+	assert.True(t, ok)
+	assert.Equal(t, 5, len(region.NodePool))
+	root := result.(*fixture.Node)
+	assert.Equal(t, "a", root.Children[0].Name)
+	assert.Equal(t, "b", root.Children[1].Name)
+	assert.Equal(t, "common", root.Children[0].Next.Name)
+	assert.True(t, root.Children[0].Next == root.Children[1].Next)
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"cycle.rommy":   `include "other.rommy" Node {}`,
+		"other.rommy":   `include "cycle.rommy" Node {}`,
+		"missing.rommy": `include "nowhere.rommy" Node {}`,
+		"bad.rommy":     `include "broken.rommy" Node {}`,
+		"broken.rommy":  `Node {next: @undefined}`,
+		"scalar.rommy":  `include "number.rommy" Node {}`,
+		"number.rommy":  `[Node {}, 3]`,
+		"root.rommy":    `"x"`,
+	})
+	defer os.RemoveAll(dir)
+
+	for _, file := range []string{"cycle.rommy", "missing.rommy", "bad.rommy", "scalar.rommy", "root.rommy"} {
+		_, _, ok := parseProject(t, dir, file)
+		assert.False(t, ok, file)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		"main.rommy": `include "things.rommy"
+
+Spell {name: "Zap", effect: Heal {amount: 1}}`,
+		"things.rommy": `[
+  Creature {name: "slime"},
+  Weapon {name: "club", hp: 5},
+]`,
+		"bad.rommy": `include "bad_things.rommy"
+
+Spell {name: "fire-ball", effect: Heal {amount: 1}}`,
+		"bad_things.rommy": `[
+  Creature {name: "slime"},
+  Creature {name: ""},
+  Weapon {hp: 5000, weight: -1},
+]`,
+	})
+	defer os.RemoveAll(dir)
+
+	load := func(file string, status *parser.Status) (*fixture.FixtureRegion, *Locations) {
+		path := filepath.Join(dir, file)
+		data, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		region := fixture.CreateFixtureRegion()
+		_, locations, ok := ParseProjectWithLocations(path, data, region, status)
+		assert.True(t, ok)
+		return region, locations
+	}
+
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+	region, locations := load("main.rommy", status)
+	assert.True(t, Validate(region, locations, status))
+	assert.Nil(t, region.Validate())
+
+	status = parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+	region, locations = load("bad.rommy", status)
+	sink := &parser.MemorySink{}
+	assert.False(t, Validate(region, locations, parser.CreateStatus(status.Sources, sink)))
+	assert.Equal(t, []string{
+		"Creature.name has 0 characters, fewer than the minimum length 1",
+		"Weapon.durability is 5000, greater than the maximum 1000",
+		"Weapon.weight is -1, less than the minimum 0",
+		`Spell.name is "fire-ball", which does not match the pattern "[A-Za-z ]+"`,
+	}, sink.Messages())
+	assert.Equal(t, parser.Position{Line: 3, Column: 18}, sink.Diagnostics[0].Span.Begin)
+	// Fields set by an alias are reported where the alias was used.
+	assert.Equal(t, parser.Position{Line: 4, Column: 14}, sink.Diagnostics[1].Span.Begin)
+	assert.Equal(t, parser.Position{Line: 3, Column: 13}, sink.Diagnostics[3].Span.Begin)
+	assert.Error(t, region.Validate())
+}
@@ -5,47 +5,319 @@ import (
 	"fmt"
 	"github.com/ncbray/rommy/parser"
 	"github.com/ncbray/rommy/runtime"
+	"math"
+	"math/big"
+	"os"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
-func resolveType(region runtime.Region, node Expr, expected runtime.TypeSchema, status *parser.Status) (runtime.TypeSchema, bool) {
-	var loc parser.Location
+// A labeled struct and the object allocated for it.
+type label struct {
+	node   *Struct
+	schema *runtime.StructSchema
+	value  reflect.Value
+}
+
+// A struct of a type with a key field, identified by its key.
+type keyName struct {
+	schema *runtime.StructSchema
+	key    interface{}
+}
+
+// A struct referred to by key, allocated when it is first referenced or
+// defined.
+type keyed struct {
+	name  keyName
+	value reflect.Value
+	// The struct literal that set the key, or nil if it has only been
+	// referenced so far.
+	node *Struct
+	// The first reference, where an undefined key is reported.
+	reference parser.Location
+}
+
+type dataContext struct {
+	region runtime.Region
+	// Structs are DynamicStructs, rather than generated types.
+	dynamic bool
+	status  *parser.Status
+	labels  map[string]*label
+	keys    map[keyName]*keyed
+	// Keys in the order they were first used, so errors are reported in order.
+	key_order []*keyed
+	// Optional, where each struct was defined.
+	locations *Locations
+}
+
+func createDataContext(region runtime.Region, status *parser.Status) *dataContext {
+	_, dynamic := region.(*runtime.DynamicRegion)
+	return &dataContext{region: region, dynamic: dynamic, status: status, labels: map[string]*label{}, keys: map[keyName]*keyed{}}
+}
+
+// The Go type that holds values of a type in the region.
+func (c *dataContext) reflectionType(t runtime.TypeSchema) reflect.Type {
+	if c.dynamic {
+		return runtime.DynamicType(t)
+	}
+	return runtime.ReflectionType(t)
+}
+
+// Find the label definitions so references can be resolved before the labeled
+// struct is reached.
+func (c *dataContext) collectLabels(node Expr) bool {
+	all_ok := true
+	switch node := node.(type) {
+	case *Struct:
+		if node.Label != nil {
+			name := node.Label.Raw
+			previous, ok := c.labels[name.Text]
+			if ok {
+				c.status.Error(name.Loc, fmt.Sprintf("label %#v is already defined", name.Text), parser.Related{Loc: previous.node.Label.Raw.Loc, Message: "previous definition"})
+				all_ok = false
+			} else {
+				c.labels[name.Text] = &label{node: node}
+			}
+		}
+		for _, arg := range node.Args {
+			all_ok = c.collectLabels(arg.Value) && all_ok
+		}
+	case *List:
+		for _, arg := range node.Args {
+			all_ok = c.collectLabels(arg) && all_ok
+		}
+	case *Map:
+		for _, entry := range node.Entries {
+			all_ok = c.collectLabels(entry.Value) && all_ok
+		}
+	}
+	return all_ok
+}
+
+func (c *dataContext) allocate(t *runtime.StructSchema) reflect.Value {
+	inst := c.region.Allocate(t.Name)
+	if inst == nil {
+		panic(inst)
+	}
+	return reflect.ValueOf(inst)
+}
+
+func (c *dataContext) handleReference(node *Reference, expected runtime.TypeSchema) (reflect.Value, bool) {
+	l, ok := c.labels[node.Name.Text]
+	if !ok {
+		c.status.Error(node.Raw.Loc, fmt.Sprintf("undefined label %#v", node.Name.Text))
+		return badValue, false
+	}
+	if l.schema == nil {
+		// Forward reference, allocate the object now and fill it in later.
+		if l.node.Type != nil {
+			rs := c.region.Schema()
+			l.schema, ok = rs.StructLUT[l.node.Type.Raw.Text]
+			if !ok {
+				// The error will be reported at the definition.
+				return badValue, false
+			}
+		} else {
+			l.schema, ok = expected.(*runtime.StructSchema)
+			if !ok {
+				c.status.Error(node.Raw.Loc, fmt.Sprintf("cannot determine type of %s", node.Raw.Text))
+				return badValue, false
+			}
+		}
+		l.value = c.allocate(l.schema)
+	}
+	if expected != nil && !expected.CanHold(l.schema) {
+		c.status.Error(node.Raw.Loc, fmt.Sprintf("expected type %s, but got type %s", expected.CanonicalName(), l.schema.CanonicalName()))
+		return badValue, false
+	}
+	return l.value, true
+}
+
+func (c *dataContext) handleKeyReference(node *KeyReference, expected runtime.TypeSchema) (reflect.Value, bool) {
+	t, ok := expected.(*runtime.StructSchema)
+	if !ok {
+		if expected == nil {
+			c.status.Error(node.Raw.Loc, fmt.Sprintf("cannot determine type of %s", node.Raw.Text))
+		} else {
+			c.status.Error(node.Raw.Loc, fmt.Sprintf("cannot refer to type %s by key, expected a struct", expected.CanonicalName()))
+		}
+		return badValue, false
+	}
+	if t.Key == nil {
+		c.status.Error(node.Raw.Loc, fmt.Sprintf("cannot refer to type %s by key, it does not have a key field", t.CanonicalName()))
+		return badValue, false
+	}
+	key, ok := ConstantValue(node.Key, t.Key.Type, c.status)
+	if !ok {
+		return badValue, false
+	}
+	name := keyName{schema: t, key: key}
+	k, ok := c.keys[name]
+	if !ok {
+		// Forward reference, allocate the object now and fill it in later.
+		k = &keyed{name: name, value: c.allocate(t), reference: node.Raw.Loc}
+		c.keys[name] = k
+		c.key_order = append(c.key_order, k)
+	}
+	return k.value, true
+}
+
+// The key a struct literal sets, if it is a literal.  Problems with the value
+// are reported when the field is handled.
+func (c *dataContext) structKey(node *Struct, t *runtime.StructSchema) (interface{}, bool) {
+	if t.Key == nil {
+		return nil, false
+	}
+	for _, arg := range node.Args {
+		if t.FieldLUT[arg.Name.Text] != t.Key {
+			continue
+		}
+		quiet := parser.CreateStatus(c.status.Sources, &parser.MemorySink{})
+		return ConstantValue(arg.Value, t.Key.Type, quiet)
+	}
+	return nil, false
+}
+
+// Get the object for a struct literal, allocating it if needed.  A labeled or
+// keyed struct may have been allocated by an earlier reference.
+func (c *dataContext) structValue(node *Struct, t *runtime.StructSchema) (reflect.Value, bool) {
+	var value reflect.Value
+	var l *label
+	if node.Label != nil {
+		l = c.labels[node.Label.Raw.Text]
+		if l.node != node {
+			l = nil
+		} else if l.schema != nil {
+			if l.schema != t {
+				c.status.Error(node.Label.Raw.Loc, fmt.Sprintf("%s is referenced as type %s, but defined as type %s", node.Label.Raw.Text, l.schema.CanonicalName(), t.CanonicalName()))
+				return badValue, false
+			}
+			value = l.value
+		}
+	}
+	if key, ok := c.structKey(node, t); ok {
+		name := keyName{schema: t, key: key}
+		k, ok := c.keys[name]
+		switch {
+		case !ok:
+			if !value.IsValid() {
+				value = c.allocate(t)
+			}
+			k = &keyed{name: name, value: value, node: node}
+			c.keys[name] = k
+			c.key_order = append(c.key_order, k)
+		case k.node != nil:
+			c.status.Error(Location(node), fmt.Sprintf("%s with key %#v is already defined", t.Name, key), parser.Related{Loc: Location(k.node), Message: "previous definition"})
+			return badValue, false
+		case value.IsValid():
+			k.node = node
+			c.status.Error(node.Label.Raw.Loc, fmt.Sprintf("%s is referenced both by label and by key before it is defined", node.Label.Raw.Text), parser.Related{Loc: k.reference, Message: "referenced by key"})
+			return badValue, false
+		default:
+			k.node = node
+			value = k.value
+		}
+	}
+	if !value.IsValid() {
+		value = c.allocate(t)
+	}
+	if l != nil && l.schema == nil {
+		l.schema = t
+		l.value = value
+	}
+	return value, true
+}
+
+// Check every struct referenced by key was defined.
+func (c *dataContext) checkKeys() bool {
+	all_ok := true
+	for _, k := range c.key_order {
+		if k.node == nil {
+			c.status.Error(k.reference, fmt.Sprintf("no %s has key %#v", k.name.schema.Name, k.name.key))
+			all_ok = false
+		}
+	}
+	return all_ok
+}
+
+// The location to report problems with a node.
+func Location(node Expr) parser.Location {
+	switch node := node.(type) {
+	case *String:
+		return node.Raw.Loc
+	case *Bytes:
+		return node.Raw.Loc
+	case *Boolean:
+		return node.Loc
+	case *Null:
+		return node.Loc
+	case *Integer:
+		return node.Raw.Loc
+	case *Float:
+		return node.Raw.Loc
+	case *Struct:
+		if node.Type != nil {
+			return node.Type.Raw.Loc
+		}
+		return node.Loc
+	case *List:
+		return node.Loc
+	case *Map:
+		return node.Loc
+	case *Symbol:
+		return node.Raw.Loc
+	case *Reference:
+		return node.Raw.Loc
+	case *KeyReference:
+		return node.Raw.Loc
+	case *Bad:
+		return node.Raw.Loc
+	default:
+		panic(node)
+	}
+}
+
+func (c *dataContext) resolveType(node Expr, expected runtime.TypeSchema) (runtime.TypeSchema, bool) {
+	loc := Location(node)
 	var actual runtime.TypeSchema
 	var ok bool
 
 	switch node := node.(type) {
 	case *String:
-		loc = node.Raw.Loc
 		actual = &runtime.StringSchema{}
+	case *Bytes:
+		actual = &runtime.BytesSchema{}
 	case *Boolean:
-		loc = node.Loc
 		actual = &runtime.BooleanSchema{}
 	case *Integer:
+		switch e := expected.(type) {
+		case *runtime.IntegerSchema:
+			actual = e
+		case *runtime.FloatSchema:
+			// Integer literals may initialize floats.
+			actual = e
+		default:
+			actual = &runtime.IntegerSchema{Bits: 64}
+		}
+	case *Float:
 		var bits uint8 = 64
-		unsigned := false
-		e, ok := expected.(*runtime.IntegerSchema)
+		e, ok := expected.(*runtime.FloatSchema)
 		if ok {
 			bits = e.Bits
-			unsigned = e.Unsigned
 		}
-		loc = node.Raw.Loc
-		actual = &runtime.IntegerSchema{Bits: bits, Unsigned: unsigned}
+		actual = &runtime.FloatSchema{Bits: bits}
 	case *Struct:
 		if node.Type != nil {
 			type_name := node.Type.Raw
-			loc = type_name.Loc
-			rs := region.Schema()
+			rs := c.region.Schema()
 			actual, ok = rs.StructLUT[type_name.Text]
 			if !ok {
-				status.Error(type_name.Loc, fmt.Sprintf("cannot resolve type %#v", type_name.Text))
+				c.status.Error(type_name.Loc, fmt.Sprintf("cannot resolve type %#v", type_name.Text))
 				return nil, false
 			}
-		} else {
-			loc = node.Loc
 		}
-	case *List:
-		loc = node.Loc
+	case *List, *Map, *Symbol, *Null:
 	default:
 		panic(node)
 	}
@@ -53,7 +325,7 @@ func resolveType(region runtime.Region, node Expr, expected runtime.TypeSchema,
 	if actual != nil {
 		if expected != nil {
 			if !expected.CanHold(actual) {
-				status.Error(loc, fmt.Sprintf("expected type %s, but got type %s", expected.CanonicalName(), actual.CanonicalName()))
+				c.status.Error(loc, fmt.Sprintf("expected type %s, but got type %s", expected.CanonicalName(), actual.CanonicalName()))
 				return nil, false
 			}
 		}
@@ -61,7 +333,7 @@ func resolveType(region runtime.Region, node Expr, expected runtime.TypeSchema,
 		if expected != nil {
 			actual = expected
 		} else {
-			status.Error(loc, "cannot determine type")
+			c.status.Error(loc, "cannot determine type")
 			return nil, false
 		}
 	}
@@ -71,22 +343,75 @@ func resolveType(region runtime.Region, node Expr, expected runtime.TypeSchema,
 
 var badValue = reflect.ValueOf(nil)
 
-func reflectionType(t runtime.TypeSchema) reflect.Type {
+// Split an integer literal into its sign, digits, and base.
+func integerLiteral(text string) (bool, string, int) {
+	negative := false
+	switch text[0] {
+	case '-':
+		negative = true
+		text = text[1:]
+	case '+':
+		text = text[1:]
+	}
+	base := 10
+	if len(text) >= 2 && text[0] == '0' {
+		switch text[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			text = text[2:]
+		}
+	}
+	return negative, strings.Replace(text, "_", "", -1), base
+}
+
+// Decimal integers cannot start with a zero.  Older versions of the format
+// read them as octal, so rather than silently changing their value, the fix
+// spells out the octal prefix.
+func checkLeadingZero(value parser.SourceString, status *parser.Status) bool {
+	negative, digits, base := integerLiteral(value.Text)
+	if base != 10 || len(digits) < 2 || digits[0] != '0' {
+		return true
+	}
+	octal := "0o" + strings.TrimPrefix(value.Text[strings.IndexByte(value.Text, '0')+1:], "_")
+	if negative {
+		octal = "-" + octal
+	}
+	status.ErrorWithFix(value.Loc, fmt.Sprintf("%s has a leading zero, write %s for an octal number or drop the zeros for a decimal number", value.Text, octal), parser.Fix{Loc: value.Loc, Text: octal})
+	return false
+}
+
+func typeRange(t runtime.TypeSchema) (string, string) {
 	switch t := t.(type) {
-	case *runtime.StructSchema:
-		return reflect.TypeOf(t.GoType)
-	case *runtime.ListSchema:
-		return reflect.SliceOf(reflectionType(t.Element))
-	case *runtime.StringSchema:
-		return reflect.TypeOf("")
-	case *runtime.BooleanSchema:
-		return reflect.TypeOf(false)
+	case *runtime.IntegerSchema:
+		if t.Unsigned {
+			return "0", strconv.FormatUint(math.MaxUint64>>(64-t.Bits), 10)
+		} else {
+			max := int64(math.MaxInt64 >> (64 - t.Bits))
+			return strconv.FormatInt(-max-1, 10), strconv.FormatInt(max, 10)
+		}
+	case *runtime.FloatSchema:
+		max := math.MaxFloat64
+		if t.Bits == 32 {
+			max = math.MaxFloat32
+		}
+		return strconv.FormatFloat(-max, 'g', -1, int(t.Bits)), strconv.FormatFloat(max, 'g', -1, int(t.Bits))
 	default:
 		panic(t)
 	}
 }
 
-func handleIntParseError(value parser.SourceString, err error, t *runtime.IntegerSchema, status *parser.Status) {
+func outOfRange(value parser.SourceString, t runtime.TypeSchema, status *parser.Status) {
+	min, max := typeRange(t)
+	status.Error(value.Loc, fmt.Sprintf("%s out of range for an %s, expected %s to %s", value.Text, t.CanonicalName(), min, max))
+}
+
+func handleNumberParseError(value parser.SourceString, err error, t runtime.TypeSchema, status *parser.Status) {
 	nerr, ok := err.(*strconv.NumError)
 	if !ok {
 		panic(nerr)
@@ -94,11 +419,86 @@ func handleIntParseError(value parser.SourceString, err error, t *runtime.Intege
 	if nerr.Err != strconv.ErrRange {
 		panic(err)
 	}
-	status.Error(value.Loc, fmt.Sprintf("%s out of range for an %s", value.Text, t.CanonicalName()))
+	outOfRange(value, t, status)
+}
+
+func handleInteger(node *Integer, t *runtime.IntegerSchema, status *parser.Status) (reflect.Value, bool) {
+	negative, digits, base := integerLiteral(node.Raw.Text)
+	if t.Unsigned {
+		value, err := strconv.ParseUint(digits, base, int(t.Bits))
+		if err != nil {
+			handleNumberParseError(node.Raw, err, t, status)
+			return badValue, false
+		}
+		if negative && value != 0 {
+			outOfRange(node.Raw, t, status)
+			return badValue, false
+		}
+		return reflect.ValueOf(value).Convert(runtime.ReflectionType(t)), true
+	} else {
+		if negative {
+			digits = "-" + digits
+		}
+		value, err := strconv.ParseInt(digits, base, int(t.Bits))
+		if err != nil {
+			handleNumberParseError(node.Raw, err, t, status)
+			return badValue, false
+		}
+		return reflect.ValueOf(value).Convert(runtime.ReflectionType(t)), true
+	}
+}
+
+// Convert an integer or float literal into a float.
+func handleFloat(value parser.SourceString, integer bool, t *runtime.FloatSchema, status *parser.Status) (reflect.Value, bool) {
+	var f float64
+	if integer {
+		negative, digits, base := integerLiteral(value.Text)
+		i, ok := new(big.Int).SetString(digits, base)
+		if !ok {
+			panic(value.Text)
+		}
+		if negative {
+			i.Neg(i)
+		}
+		bf := new(big.Float).SetInt(i)
+		if t.Bits == 32 {
+			f32, _ := bf.Float32()
+			f = float64(f32)
+		} else {
+			f, _ = bf.Float64()
+		}
+		if math.IsInf(f, 0) {
+			outOfRange(value, t, status)
+			return badValue, false
+		}
+	} else {
+		var err error
+		f, err = strconv.ParseFloat(value.Text, int(t.Bits))
+		if err != nil {
+			handleNumberParseError(value, err, t, status)
+			return badValue, false
+		}
+	}
+	return reflect.ValueOf(f).Convert(runtime.ReflectionType(t)), true
 }
 
-func handleData(region runtime.Region, node Expr, expected runtime.TypeSchema, status *parser.Status) (reflect.Value, bool) {
-	actual, ok := resolveType(region, node, expected, status)
+func (c *dataContext) handleData(node Expr, expected runtime.TypeSchema) (reflect.Value, bool) {
+	if t, ok := expected.(*runtime.OptionalSchema); ok {
+		if _, ok := node.(*Null); ok {
+			return reflect.Zero(c.reflectionType(t)), true
+		}
+		expected = t.Element
+	}
+	switch node := node.(type) {
+	case *Reference:
+		return c.handleReference(node, expected)
+	case *KeyReference:
+		return c.handleKeyReference(node, expected)
+	case *Bad:
+		// The syntax error has already been reported.
+		return badValue, false
+	}
+	actual, ok := c.resolveType(node, expected)
 	if !ok {
 		return badValue, false
 	}
@@ -107,91 +507,95 @@ func handleData(region runtime.Region, node Expr, expected runtime.TypeSchema, s
 	case *String:
 		_, ok := actual.(*runtime.StringSchema)
 		if !ok {
-			status.Error(node.Raw.Loc, fmt.Sprintf("attempted to instantiate type %s as a string", actual.CanonicalName()))
+			c.status.Error(node.Raw.Loc, fmt.Sprintf("attempted to instantiate type %s as a string", actual.CanonicalName()))
+			return badValue, false
+		}
+		return reflect.ValueOf(node.Value), true
+	case *Bytes:
+		_, ok := actual.(*runtime.BytesSchema)
+		if !ok {
+			c.status.Error(node.Raw.Loc, fmt.Sprintf("attempted to instantiate type %s as bytes", actual.CanonicalName()))
 			return badValue, false
 		}
 		return reflect.ValueOf(node.Value), true
 	case *Boolean:
 		_, ok := actual.(*runtime.BooleanSchema)
 		if !ok {
-			status.Error(node.Loc, fmt.Sprintf("attempted to instantiate type %s as a bool", actual.CanonicalName()))
+			c.status.Error(node.Loc, fmt.Sprintf("attempted to instantiate type %s as a bool", actual.CanonicalName()))
 			return badValue, false
 		}
 		return reflect.ValueOf(node.Value), true
 	case *Integer:
-		t, ok := actual.(*runtime.IntegerSchema)
-		if !ok {
-			status.Error(node.Raw.Loc, fmt.Sprintf("attempted to instantiate type %s as an int", actual.CanonicalName()))
+		if !checkLeadingZero(node.Raw, c.status) {
 			return badValue, false
 		}
-		if t.Unsigned {
-			value, err := strconv.ParseUint(node.Raw.Text, 0, int(t.Bits))
-			if err != nil {
-				handleIntParseError(node.Raw, err, t, status)
-				return badValue, false
-			}
-			switch t.Bits {
-			case 8:
-				return reflect.ValueOf(uint8(value)), true
-			case 16:
-				return reflect.ValueOf(uint16(value)), true
-			case 32:
-				return reflect.ValueOf(uint32(value)), true
-			case 64:
-				return reflect.ValueOf(uint64(value)), true
-			default:
-				panic(t.CanonicalName())
-			}
-		} else {
-			value, err := strconv.ParseInt(node.Raw.Text, 0, int(t.Bits))
-			if err != nil {
-				handleIntParseError(node.Raw, err, t, status)
-				return badValue, false
-			}
-			switch t.Bits {
-			case 8:
-				return reflect.ValueOf(int8(value)), true
-			case 16:
-				return reflect.ValueOf(int16(value)), true
-			case 32:
-				return reflect.ValueOf(int32(value)), true
-			case 64:
-				return reflect.ValueOf(int64(value)), true
-			default:
-				panic(t.CanonicalName())
-			}
+		switch t := actual.(type) {
+		case *runtime.IntegerSchema:
+			return handleInteger(node, t, c.status)
+		case *runtime.FloatSchema:
+			return handleFloat(node.Raw, true, t, c.status)
+		default:
+			c.status.Error(node.Raw.Loc, fmt.Sprintf("attempted to instantiate type %s as an int", actual.CanonicalName()))
+			return badValue, false
+		}
+	case *Float:
+		t, ok := actual.(*runtime.FloatSchema)
+		if !ok {
+			c.status.Error(node.Raw.Loc, fmt.Sprintf("attempted to instantiate type %s as a float", actual.CanonicalName()))
+			return badValue, false
 		}
+		return handleFloat(node.Raw, false, t, c.status)
 	case *Struct:
+		if u, ok := actual.(*runtime.UnionSchema); ok {
+			c.status.Error(node.Loc, fmt.Sprintf("cannot determine which arm of %s to instantiate, name the type of the struct", u.CanonicalName()))
+			return badValue, false
+		}
 		t, ok := actual.(*runtime.StructSchema)
 		if !ok {
-			status.Error(node.Loc, fmt.Sprintf("attempted to instantiate type %s as a struct", actual.CanonicalName()))
+			c.status.Error(node.Loc, fmt.Sprintf("attempted to instantiate type %s as a struct", actual.CanonicalName()))
 			return badValue, false
 		}
-		inst := region.Allocate(t.Name)
-		if inst == nil {
-			panic(inst)
+		rv, ok := c.structValue(node, t)
+		if !ok {
+			return badValue, false
+		}
+		if c.locations != nil {
+			c.locations.SetStruct(rv.Interface(), Location(node))
+			for _, arg := range node.Args {
+				name := arg.Name.Text
+				if f, ok := t.FieldLUT[name]; ok {
+					name = f.Name
+				}
+				c.locations.SetField(rv.Interface(), name, Location(arg.Value))
+			}
 		}
-		rv := reflect.ValueOf(inst)
 
 		all_ok := true
 		defined := make([]bool, len(t.Fields))
 		for _, arg := range node.Args {
 			f, ok := t.FieldLUT[arg.Name.Text]
 			if ok {
+				if arg.Name.Text != f.Name {
+					c.status.WarningWithFix(arg.Name.Loc, fmt.Sprintf("%#v is an old name for %s.%s", arg.Name.Text, t.Name, f.Name), parser.Fix{Loc: arg.Name.Loc, Text: f.Name})
+				}
+				if f.Deprecated {
+					c.status.Warning(arg.Name.Loc, fmt.Sprintf("%s.%s is deprecated", t.Name, f.Name))
+				}
 				if defined[f.ID] {
-					status.Error(arg.Name.Loc, fmt.Sprintf("attempted to re-define %#v", arg.Name.Text))
+					c.status.Error(arg.Name.Loc, fmt.Sprintf("attempted to re-define %#v", arg.Name.Text))
+					all_ok = false
 				} else {
 					defined[f.ID] = true
 				}
-				fv, ok := handleData(region, arg.Value, f.Type, status)
+				fv, ok := c.handleData(arg.Value, f.Type)
 				if ok {
-					rf := rv.Elem().FieldByName(f.GoName())
+					rf := runtime.StructField(rv, f)
 					rf.Set(fv)
 				} else {
 					all_ok = false
 				}
 			} else {
-				status.Error(arg.Name.Loc, fmt.Sprintf("type %s does not have field %#v", t.CanonicalName(), arg.Name.Text))
+				c.status.Error(arg.Name.Loc, fmt.Sprintf("type %s does not have field %#v", t.CanonicalName(), arg.Name.Text))
 				all_ok = false
 			}
 		}
@@ -202,16 +606,26 @@ func handleData(region runtime.Region, node Expr, expected runtime.TypeSchema, s
 			return badValue, false
 		}
 	case *List:
-		t, ok := expected.(*runtime.ListSchema)
-		if !ok {
-			status.Error(node.Loc, fmt.Sprintf("attempted to instantiate type %s as a list", expected.CanonicalName()))
+		var element runtime.TypeSchema
+		var rv reflect.Value
+		switch t := expected.(type) {
+		case *runtime.ListSchema:
+			element = t.Element
+			rv = reflect.MakeSlice(c.reflectionType(t), len(node.Args), len(node.Args))
+		case *runtime.ArraySchema:
+			if len(node.Args) != t.Length {
+				c.status.Error(node.Loc, fmt.Sprintf("expected %d elements for type %s, but got %d", t.Length, t.CanonicalName(), len(node.Args)))
+				return badValue, false
+			}
+			element = t.Element
+			rv = reflect.New(c.reflectionType(t)).Elem()
+		default:
+			c.status.Error(node.Loc, fmt.Sprintf("attempted to instantiate type %s as a list", expected.CanonicalName()))
 			return badValue, false
 		}
-		rt := reflectionType(t)
-		rv := reflect.MakeSlice(rt, len(node.Args), len(node.Args))
 		all_ok := true
 		for i, arg := range node.Args {
-			fv, ok := handleData(region, arg, t.Element, status)
+			fv, ok := c.handleData(arg, element)
 			if ok {
 				rf := rv.Index(i)
 				rf.Set(fv)
@@ -224,19 +638,70 @@ func handleData(region runtime.Region, node Expr, expected runtime.TypeSchema, s
 		} else {
 			return badValue, false
 		}
+	case *Map:
+		t, ok := expected.(*runtime.MapSchema)
+		if !ok {
+			c.status.Error(node.Loc, fmt.Sprintf("attempted to instantiate type %s as a map", expected.CanonicalName()))
+			return badValue, false
+		}
+		rv := reflect.MakeMap(c.reflectionType(t))
+		all_ok := true
+		for _, entry := range node.Entries {
+			kv, ok := c.handleData(entry.Key, t.Key)
+			if !ok {
+				all_ok = false
+				continue
+			}
+			if rv.MapIndex(kv).IsValid() {
+				c.status.Error(Location(entry.Key), "duplicate key")
+				all_ok = false
+				continue
+			}
+			vv, ok := c.handleData(entry.Value, t.Value)
+			if ok {
+				rv.SetMapIndex(kv, vv)
+			} else {
+				all_ok = false
+			}
+		}
+		if all_ok {
+			return rv, true
+		} else {
+			return badValue, false
+		}
+	case *Null:
+		c.status.Error(node.Loc, fmt.Sprintf("type %s is not optional, cannot be null", actual.CanonicalName()))
+		return badValue, false
+	case *Symbol:
+		// Unsigned inf and nan are names, so they can also be enum values or
+		// labels, and only mean a float where one is expected.
+		if t, ok := actual.(*runtime.FloatSchema); ok && (node.Raw.Text == "inf" || node.Raw.Text == "nan") {
+			return handleFloat(node.Raw, false, t, c.status)
+		}
+		t, ok := actual.(*runtime.EnumSchema)
+		if !ok {
+			c.status.Error(node.Raw.Loc, fmt.Sprintf("attempted to instantiate type %s as an enum", actual.CanonicalName()))
+			return badValue, false
+		}
+		index, ok := t.ValueLUT[node.Raw.Text]
+		if !ok {
+			c.status.Error(node.Raw.Loc, fmt.Sprintf("enum %s does not have value %#v", t.CanonicalName(), node.Raw.Text))
+			return badValue, false
+		}
+		return reflect.ValueOf(uint32(index)).Convert(c.reflectionType(t)), true
 	default:
 		panic(node)
 	}
 }
 
-// Convert an AST to a runtime structure.
-func DataToStruct(region runtime.Region, node Expr, expected runtime.TypeSchema, status *parser.Status) (runtime.Struct, bool) {
-	rv, ok := handleData(region, node, expected, status)
+func (c *dataContext) toStruct(node Expr, expected runtime.TypeSchema) (runtime.Struct, bool) {
+	rv, ok := c.handleData(node, expected)
 	if ok {
 		general := rv.Interface()
 		specific, ok := general.(runtime.Struct)
 		if !ok {
-			panic(general)
+			c.status.Error(Location(node), "root must be a struct")
+			return nil, false
 		}
 		return specific, true
 	} else {
@@ -244,14 +709,49 @@ func DataToStruct(region runtime.Region, node Expr, expected runtime.TypeSchema,
 	}
 }
 
-// Simple interface for parsing a single data file.
-func ParseFile(file string, data []byte, region runtime.Region) (runtime.Struct, bool) {
-	sources := parser.CreateSourceSet()
-	status := &parser.Status{Sources: sources}
-	info := sources.Add(file, data)
-	e := ParseData(info, data, status)
-	if status.ShouldStop() {
+// The root of an included file.  A list of typed structs is allowed, so a
+// single file can define many objects.
+func (c *dataContext) handleIncluded(node Expr) bool {
+	list, ok := node.(*List)
+	if !ok {
+		_, ok := c.toStruct(node, nil)
+		return ok
+	}
+	all_ok := true
+	for _, arg := range list.Args {
+		_, ok := c.toStruct(arg, nil)
+		all_ok = ok && all_ok
+	}
+	return all_ok
+}
+
+// Convert an AST to a runtime structure.
+func DataToStruct(region runtime.Region, node Expr, expected runtime.TypeSchema, status *parser.Status) (runtime.Struct, bool) {
+	c := createDataContext(region, status)
+	if !c.collectLabels(node) {
 		return nil, false
 	}
-	return DataToStruct(region, e, nil, status)
+	result, ok := c.toStruct(node, expected)
+	return result, c.checkKeys() && ok
+}
+
+// Check a populated region against the constraints in its schema.  Violations
+// are reported where the fields were set, if the locations are known.
+func Validate(region runtime.Region, locations *Locations, status *parser.Status) bool {
+	violations := runtime.FindViolations(region)
+	for _, v := range violations {
+		related := []parser.Related{}
+		if v.Other != nil {
+			related = append(related, parser.Related{Loc: locations.Field(v.Other, v.Field.Name), Message: "the same value"})
+		}
+		status.Error(locations.Field(v.Struct, v.Field.Name), v.Message, related...)
+	}
+	return len(violations) == 0
+}
+
+// Simple interface for parsing a data file and the files it includes.
+func ParseFile(file string, data []byte, region runtime.Region) (runtime.Struct, bool) {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.TextSink{Out: os.Stderr})
+	return ParseProject(file, data, region, status)
 }
@@ -0,0 +1,613 @@
+package human
+
+import (
+	"bytes"
+	"github.com/ncbray/rommy/internal/fixture"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func parseFixture(t *testing.T, text string) (*fixture.FixtureRegion, interface{}, bool) {
+	region := fixture.CreateFixtureRegion()
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+	result, ok := ParseProject("t", []byte(text), region, status)
+	return region, result, ok
+}
+
+func TestNumericLiterals(t *testing.T) {
+	_, result, ok := parseFixture(t, `Numbers {
+  i8: -128,
+  u8: 0xF_F,
+  i64: -0x8000_0000_0000_0000,
+  u64: 0b1010,
+  f32: 1.5e3,
+  f64: -2_000,
+  ints: [0o17, +3, 0, -0],
+  floats: [1, -0.25, 6.02e+23, 07.5],
+}`)
+	assert.True(t, ok)
+	assert.Equal(t, &fixture.Numbers{
+		I8:     -128,
+		U8:     255,
+		I64:    -0x8000000000000000,
+		U64:    10,
+		F32:    1500,
+		F64:    -2000,
+		Ints:   []int32{15, 3, 0, 0},
+		Floats: []float64{1, -0.25, 6.02e+23, 7.5},
+	}, result)
+}
+
+func TestLeadingZero(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		fix  string
+	}{
+		{"Numbers {i8: 017}", "0o17"},
+		{"Numbers {i8: -0_17}", "-0o17"},
+		{"Numbers {f32: +00}", "0o0"},
+	} {
+		sink := &parser.MemorySink{}
+		status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+		region := fixture.CreateFixtureRegion()
+		_, ok := ParseProject("t", []byte(c.text), region, status)
+		assert.False(t, ok, c.text)
+		assert.Equal(t, 1, len(sink.Diagnostics), c.text)
+		assert.Equal(t, c.fix, sink.Diagnostics[0].Fixes[0].NewText, c.text)
+	}
+}
+
+func TestSpecialFloats(t *testing.T) {
+	_, result, ok := parseFixture(t, `Numbers {f32: -inf, floats: [inf, +inf, nan]}`)
+	assert.True(t, ok)
+	numbers := result.(*fixture.Numbers)
+	assert.True(t, math.IsInf(float64(numbers.F32), -1))
+	assert.True(t, math.IsInf(numbers.Floats[0], 1))
+	assert.True(t, math.IsInf(numbers.Floats[1], 1))
+	assert.True(t, math.IsNaN(numbers.Floats[2]))
+
+	var out bytes.Buffer
+	runtime.DumpText(numbers, &out)
+	assert.Equal(t, `Numbers {
+  f32: -inf,
+  floats: [
+    inf,
+    inf,
+    nan,
+  ],
+}
+`, out.String())
+	_, _, ok = parseFixture(t, out.String())
+	assert.True(t, ok)
+
+	_, _, ok = parseFixture(t, `Numbers {i64: inf}`)
+	assert.False(t, ok)
+}
+
+func TestSpecialFloatNamesAsLabels(t *testing.T) {
+	_, result, ok := parseFixture(t, `nan = Node {name: "nan", next: inf = Node {next: @nan}}`)
+	assert.True(t, ok)
+	root := result.(*fixture.Node)
+	assert.True(t, root.Next.Next == root)
+}
+
+func TestNumericOutOfRange(t *testing.T) {
+	for _, text := range []string{
+		"Numbers {i8: 128}",
+		"Numbers {u8: -1}",
+		"Numbers {u64: 0x1_0000_0000_0000_0000}",
+		"Numbers {f32: 1e39}",
+		"Numbers {f32: 0x1_0000_0000_0000_0000_0000_0000_0000_0000_0}",
+		"Numbers {f64: 1e309}",
+	} {
+		_, _, ok := parseFixture(t, text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestNumericTypeMismatch(t *testing.T) {
+	_, _, ok := parseFixture(t, "Numbers {i64: 1.0}")
+	assert.False(t, ok)
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	for _, text := range []string{"1__0", "-_1", "1_", "0x", "1.", ".5", "1e", "0b102", "--1"} {
+		sources := parser.CreateSourceSet()
+		status := parser.CreateStatus(sources, &parser.MemorySink{})
+		data := []byte(text)
+		info := sources.Add("t", data)
+		ParseData(info, data, status)
+		assert.True(t, status.ShouldStop(), text)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	region, result, ok := parseFixture(t, `root = Node {
+  name: "root",
+  children: [
+    @b,
+    a = {name: "a", next: @b},
+    b = Node {name: "b", next: @a},
+    {name: "c", next: @root},
+  ],
+}`)
+	assert.True(t, ok)
+	assert.Equal(t, 4, len(region.NodePool))
+	root := result.(*fixture.Node)
+	a := root.Children[1]
+	b := root.Children[0]
+	assert.Equal(t, "a", a.Name)
+	assert.Equal(t, "b", b.Name)
+	assert.True(t, b == root.Children[2])
+	assert.True(t, a.Next == b)
+	assert.True(t, b.Next == a)
+	assert.True(t, root.Children[3].Next == root)
+}
+
+func TestLabelErrors(t *testing.T) {
+	for _, text := range []string{
+		"Node {next: @missing}",
+		"Node {children: [a = {}, a = {}]}",
+		"Node {next: @n, children: [n = Numbers {}]}",
+		"@a",
+	} {
+		_, _, ok := parseFixture(t, text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestDuplicateLabel(t *testing.T) {
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+	_, ok := ParseProject("t", []byte("Node {children: [a = {}, a = {}]}"), fixture.CreateFixtureRegion(), status)
+	assert.False(t, ok)
+	assert.Equal(t, []string{`label "a" is already defined`}, sink.Messages())
+	d := sink.Diagnostics[0]
+	assert.Equal(t, 25, d.Span.Begin.Column)
+	assert.Equal(t, 17, d.Related[0].Span.Begin.Column)
+}
+
+func TestDumpLabels(t *testing.T) {
+	_, result, ok := parseFixture(t, "Node {children: [a = {name: \"a\", next: @a}, @a, {name: \"b\"}]}")
+	assert.True(t, ok)
+	var out bytes.Buffer
+	runtime.DumpText(result.(*fixture.Node), &out)
+	assert.Equal(t, `Node {
+  children: [
+    node0 = {
+      name: "a",
+      next: @node0,
+    },
+    @node0,
+    {
+      name: "b",
+    },
+  ],
+}
+`, out.String())
+	_, reparsed, ok := parseFixture(t, out.String())
+	assert.True(t, ok)
+	children := reparsed.(*fixture.Node).Children
+	assert.True(t, children[0] == children[1])
+	assert.True(t, children[0].Next == children[0])
+}
+
+func TestEnums(t *testing.T) {
+	region, result, ok := parseFixture(t, `Creature {
+  name: "Squirtle",
+  element: water, // Trailing.
+  weaknesses: [earth_quake, fire],
+}`)
+	assert.True(t, ok)
+	assert.Equal(t, &fixture.Creature{
+		Name:       "Squirtle",
+		Element:    fixture.ElementWater,
+		Weaknesses: []fixture.Element{fixture.ElementEarthQuake, fixture.ElementFire},
+	}, result)
+
+	var out bytes.Buffer
+	runtime.DumpText(result.(*fixture.Creature), &out)
+	assert.Equal(t, `Creature {
+  name: "Squirtle",
+  element: water,
+  weaknesses: [
+    earth_quake,
+    fire,
+  ],
+}
+`, out.String())
+
+	data, err := region.MarshalBinary()
+	assert.Nil(t, err)
+	decoded := fixture.CreateFixtureRegion()
+	assert.Nil(t, decoded.UnmarshalBinary(data))
+	var redumped bytes.Buffer
+	runtime.DumpText(decoded.CreaturePool[0], &redumped)
+	assert.Equal(t, out.String(), redumped.String())
+}
+
+func TestEnumErrors(t *testing.T) {
+	for _, text := range []string{
+		"Creature {element: wind}",
+		"Creature {element: 1}",
+		"Creature {name: fire}",
+		"Node {next: fire}",
+	} {
+		_, _, ok := parseFixture(t, text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestMaps(t *testing.T) {
+	region, result, ok := parseFixture(t, `Creature {
+  stats: ["speed": 3, "attack": -1],
+  resistances: [fire: 0.5, water: 2],
+}`)
+	assert.True(t, ok)
+	assert.Equal(t, &fixture.Creature{
+		Stats:       map[string]int32{"speed": 3, "attack": -1},
+		Resistances: map[fixture.Element]float32{fixture.ElementFire: 0.5, fixture.ElementWater: 2},
+	}, result)
+
+	var out bytes.Buffer
+	runtime.DumpText(result.(*fixture.Creature), &out)
+	assert.Equal(t, `Creature {
+  stats: [
+    "attack": -1,
+    "speed": 3,
+  ],
+  resistances: [
+    fire: 0.5,
+    water: 2,
+  ],
+}
+`, out.String())
+
+	data, err := region.MarshalBinary()
+	assert.Nil(t, err)
+	decoded := fixture.CreateFixtureRegion()
+	assert.Nil(t, decoded.UnmarshalBinary(data))
+	var redumped bytes.Buffer
+	runtime.DumpText(decoded.CreaturePool[0], &redumped)
+	assert.Equal(t, out.String(), redumped.String())
+
+	// The encoding does not depend on map iteration order.
+	again, err := decoded.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, data, again)
+}
+
+func TestMapReferences(t *testing.T) {
+	_, result, ok := parseFixture(t, `Node {named: ["a": a = {name: "a"}, "b": @a, "c": {named: [:]}]}`)
+	assert.True(t, ok)
+	named := result.(*fixture.Node).Named
+	assert.True(t, named["a"] == named["b"])
+	assert.Equal(t, 0, len(named["c"].Named))
+}
+
+func TestMapErrors(t *testing.T) {
+	for _, text := range []string{
+		`Creature {stats: ["speed": 1, "speed": 2]}`,
+		`Creature {stats: ["speed": 1, 2]}`,
+		`Creature {stats: [1, "speed": 2]}`,
+		`Creature {stats: [1: 2]}`,
+		`Creature {resistances: [wind: 1]}`,
+		`Creature {weaknesses: [fire: 1]}`,
+		`Creature {stats: [fire]}`,
+	} {
+		_, _, ok := parseFixture(t, text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestOptionalReferences(t *testing.T) {
+	region, result, ok := parseFixture(t, `Node {
+  name: "root",
+  next: null,
+  children: [{name: "a", next: @b}, b = {name: "b"}],
+}`)
+	assert.True(t, ok)
+	root := result.(*fixture.Node)
+	assert.Nil(t, root.Next)
+	assert.True(t, root.Children[0].Next == root.Children[1])
+
+	data, err := region.MarshalBinary()
+	assert.Nil(t, err)
+	decoded := fixture.CreateFixtureRegion()
+	assert.Nil(t, decoded.UnmarshalBinary(data))
+	var out, redumped bytes.Buffer
+	runtime.DumpText(root, &out)
+	runtime.DumpText(decoded.NodePool[root.PoolIndex], &redumped)
+	assert.Equal(t, out.String(), redumped.String())
+}
+
+func TestRootMustBeStruct(t *testing.T) {
+	for _, text := range []string{`0`, `"x"`, `-1.5`, `true`, `fire`} {
+		region := fixture.CreateFixtureRegion()
+		sink := &parser.MemorySink{}
+		status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+		_, ok := ParseProject("t", []byte(text), region, status)
+		assert.False(t, ok, text)
+		assert.NotEmpty(t, sink.Messages(), text)
+	}
+	region := fixture.CreateFixtureRegion()
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+	ParseProject("t", []byte(`-1`), region, status)
+	assert.Equal(t, []string{"root must be a struct"}, sink.Messages())
+}
+
+func TestRequiredReferences(t *testing.T) {
+	region, _, ok := parseFixture(t, `Edge {to: {name: "a"}}`)
+	assert.True(t, ok)
+	_, err := region.MarshalBinary()
+	assert.EqualError(t, err, "Edge.from is required but is nil")
+
+	for _, text := range []string{
+		`Edge {from: null}`,
+		`Node {children: [null]}`,
+		`Numbers {i8: null}`,
+		`null`,
+	} {
+		_, _, ok := parseFixture(t, text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	_, result, ok := parseFixture(t, `Weapon {durability: 0, sharp: false, price: 20}`)
+	assert.True(t, ok)
+	assert.Equal(t, &fixture.Weapon{
+		Name:       "sword",
+		Durability: 0,
+		Weight:     1.5,
+		Sharp:      false,
+		Element:    fixture.ElementFire,
+		Price:      20,
+	}, result)
+
+	var out bytes.Buffer
+	runtime.DumpText(result.(*fixture.Weapon), &out)
+	assert.Equal(t, `Weapon {
+  durability: 0,
+  sharp: false,
+  price: 20,
+}
+`, out.String())
+}
+
+func TestAliasesAndDeprecated(t *testing.T) {
+	region := fixture.CreateFixtureRegion()
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+	result, ok := ParseProject("t", []byte(`Weapon {hp: 5, price: 20}`), region, status)
+	assert.True(t, ok)
+	assert.Equal(t, int32(5), result.(*fixture.Weapon).Durability)
+	assert.Equal(t, []string{
+		`"hp" is an old name for Weapon.durability`,
+		"Weapon.price is deprecated",
+	}, sink.Messages())
+	fix := sink.Diagnostics[0].Fixes[0]
+	assert.Equal(t, "durability", fix.NewText)
+	assert.Equal(t, parser.Position{Line: 1, Column: 8}, fix.Span.Begin)
+	assert.Equal(t, parser.Position{Line: 1, Column: 10}, fix.Span.End)
+
+	sink = &parser.MemorySink{}
+	status = parser.CreateStatus(parser.CreateSourceSet(), sink)
+	_, ok = ParseProject("t", []byte(`Weapon {hp: 5, durability: 6}`), region, status)
+	assert.False(t, ok)
+	assert.True(t, status.ShouldStop())
+	assert.Equal(t, `attempted to re-define "durability"`, sink.Messages()[1])
+}
+
+func TestUnions(t *testing.T) {
+	region, result, ok := parseFixture(t, `Spell {
+  name: "zap",
+  effect: Damage {amount: 5, element: water},
+  combo: [h = Heal {amount: 2}, @h, Damage {amount: 1}],
+}`)
+	assert.True(t, ok)
+	spell := result.(*fixture.Spell)
+	assert.Equal(t, &fixture.Damage{Amount: 5, Element: fixture.ElementWater}, spell.Effect)
+	assert.Nil(t, spell.Bonus)
+	assert.Equal(t, 3, len(spell.Combo))
+	assert.True(t, spell.Combo[0] == spell.Combo[1])
+	assert.Equal(t, &fixture.Heal{Amount: 2}, spell.Combo[0])
+
+	var out bytes.Buffer
+	runtime.DumpText(spell, &out)
+	assert.Equal(t, `Spell {
+  name: "zap",
+  effect: Damage {
+    amount: 5,
+    element: water,
+  },
+  combo: [
+    heal0 = Heal {
+      amount: 2,
+    },
+    @heal0,
+    Damage {
+      amount: 1,
+    },
+  ],
+}
+`, out.String())
+
+	data, err := region.MarshalBinary()
+	assert.Nil(t, err)
+	decoded := fixture.CreateFixtureRegion()
+	assert.Nil(t, decoded.UnmarshalBinary(data))
+	var redumped bytes.Buffer
+	runtime.DumpText(decoded.SpellPool[0], &redumped)
+	assert.Equal(t, out.String(), redumped.String())
+}
+
+func TestUnionErrors(t *testing.T) {
+	region, _, ok := parseFixture(t, `Spell {bonus: Heal {}}`)
+	assert.True(t, ok)
+	_, err := region.MarshalBinary()
+	assert.EqualError(t, err, "Spell.effect is required but is nil")
+
+	for _, text := range []string{
+		`Spell {effect: {amount: 1}}`,
+		`Spell {effect: Node {}}`,
+		`Spell {effect: null}`,
+		`Spell {combo: [null]}`,
+		`Spell {effect: n = Node {}, bonus: @n}`,
+	} {
+		_, _, ok := parseFixture(t, text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestArraysAndBytes(t *testing.T) {
+	region, result, ok := parseFixture(t, `Tileset {
+  palette: [1, 2, 3, 0xFFFF],
+  mask: x"00ff10",
+  corners: [null, {name: "a"}],
+}`)
+	assert.True(t, ok)
+	tiles := result.(*fixture.Tileset)
+	assert.Equal(t, [4]uint16{1, 2, 3, 0xFFFF}, tiles.Palette)
+	assert.Equal(t, []byte{0x00, 0xff, 0x10}, tiles.Mask)
+	assert.Nil(t, tiles.Corners[0])
+	assert.Equal(t, "a", tiles.Corners[1].Name)
+
+	var out bytes.Buffer
+	runtime.DumpText(tiles, &out)
+	assert.Equal(t, `Tileset {
+  palette: [
+    1,
+    2,
+    3,
+    65535,
+  ],
+  mask: x"00ff10",
+  corners: [
+    null,
+    {
+      name: "a",
+    },
+  ],
+}
+`, out.String())
+
+	data, err := region.MarshalBinary()
+	assert.Nil(t, err)
+	decoded := fixture.CreateFixtureRegion()
+	assert.Nil(t, decoded.UnmarshalBinary(data))
+	var redumped bytes.Buffer
+	runtime.DumpText(decoded.TilesetPool[0], &redumped)
+	assert.Equal(t, out.String(), redumped.String())
+
+	_, result, ok = parseFixture(t, `Tileset {mask: b64"AP8Q"}`)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0x00, 0xff, 0x10}, result.(*fixture.Tileset).Mask)
+}
+
+func TestArrayAndBytesErrors(t *testing.T) {
+	for _, text := range []string{
+		`Tileset {palette: [1, 2, 3]}`,
+		`Tileset {palette: [1, 2, 3, 4, 5]}`,
+		`Tileset {palette: [1, 2, 3, 65536]}`,
+		`Tileset {mask: x"0"}`,
+		`Tileset {mask: x"zz"}`,
+		`Tileset {mask: b64"A"}`,
+		`Tileset {mask: "00ff"}`,
+		`Tileset {name: x"00"}`,
+		`Tileset {mask: [0, 1]}`,
+	} {
+		_, _, ok := parseFixture(t, text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestDynamicRegion(t *testing.T) {
+	text := `Spell {
+  name: "storm",
+  effect: damage0 = Damage {
+    amount: 3,
+    element: water,
+  },
+  combo: [
+    @damage0,
+    Heal {
+      amount: 1,
+    },
+  ],
+}
+`
+	region := runtime.CreateDynamicRegion(fixture.CreateFixtureRegion().Schema())
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+	result, ok := ParseProject("t", []byte(text), region, status)
+	assert.True(t, ok)
+	spell, ok := result.(*runtime.DynamicStruct)
+	assert.True(t, ok)
+	assert.Equal(t, "storm", spell.Get(spell.Schema().FieldLUT["name"]))
+
+	var dynamic bytes.Buffer
+	runtime.DumpText(spell, &dynamic)
+	assert.Equal(t, text, dynamic.String())
+	assert.Equal(t, 1, len(region.Pool(region.Schema().StructLUT["Damage"])))
+}
+
+func TestKeyReferences(t *testing.T) {
+	region, result, ok := parseFixture(t, `Encounter {
+  boss: &"dragon",
+  creatures: [&"slime", Creature {name: "slime"}, Creature {name: "dragon", element: fire}],
+}`)
+	assert.True(t, ok)
+	encounter := result.(*fixture.Encounter)
+	assert.Equal(t, 2, len(region.CreaturePool))
+	assert.True(t, encounter.Creatures[0] == encounter.Creatures[1])
+	assert.True(t, encounter.Boss == encounter.Creatures[2])
+	assert.Equal(t, fixture.ElementFire, encounter.Boss.Element)
+
+	dragon, ok := region.Creature("dragon")
+	assert.True(t, ok)
+	assert.True(t, dragon == encounter.Boss)
+	_, ok = region.Creature("bat")
+	assert.False(t, ok)
+	bat := region.AllocateCreature()
+	assert.NoError(t, region.SetCreatureKey(bat, "bat"))
+	found, ok := region.Creature("bat")
+	assert.True(t, ok)
+	assert.True(t, found == bat)
+
+	encoded, err := region.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := fixture.CreateFixtureRegion()
+	assert.NoError(t, decoded.UnmarshalBinary(encoded))
+	found, ok = decoded.Creature("slime")
+	assert.True(t, ok)
+	assert.True(t, found == decoded.CreaturePool[encounter.Creatures[0].PoolIndex])
+}
+
+func TestKeyReferenceErrors(t *testing.T) {
+	for _, c := range []struct {
+		text    string
+		message string
+	}{
+		{`Encounter {boss: &"dragon"}`, `no Creature has key "dragon"`},
+		{`Encounter {creatures: [Creature {name: "a"}, Creature {name: "a"}]}`, `Creature with key "a" is already defined`},
+		{`Encounter {boss: &1}`, "expected type string, but got type int64"},
+		{`Edge {from: &"a"}`, "cannot refer to type Node by key, it does not have a key field"},
+		{`Spell {effect: &"a"}`, "cannot refer to type Effect by key, expected a struct"},
+		{`Encounter {boss: &"a", creatures: [a = Creature {name: "a"}, @a]}`, ""},
+		{`Encounter {boss: @a, creatures: [&"a", a = Creature {name: "a"}]}`, "a is referenced both by label and by key before it is defined"},
+	} {
+		sink := &parser.MemorySink{}
+		status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+		_, ok := ParseProject("t", []byte(c.text), fixture.CreateFixtureRegion(), status)
+		if c.message == "" {
+			assert.True(t, ok, c.text)
+			continue
+		}
+		assert.False(t, ok, c.text)
+		assert.Equal(t, []string{c.message}, sink.Messages(), c.text)
+	}
+}
@@ -1,26 +1,38 @@
 package human
 
 import (
+	"bytes"
 	"github.com/ncbray/compilerutil/writer"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
 	"io"
+	"strconv"
+	"unicode/utf8"
 )
 
+func hasComments(c *parser.Comments) bool {
+	return len(c.Leading) > 0 || len(c.Trailing) > 0
+}
+
 func isSimple(expr Expr) bool {
+	if hasComments(expr.Attached()) {
+		return false
+	}
 	switch expr := expr.(type) {
-	case *String, *Integer:
+	case *String, *Bytes, *Integer, *Float, *Boolean, *Reference, *KeyReference, *Symbol, *Null, *Bad:
 		return true
 	case *Struct:
-		if len(expr.Args) >= 6 {
+		if len(expr.Args) >= 6 || len(expr.Dangling) > 0 {
 			return false
 		}
 		for _, arg := range expr.Args {
-			if !isSimple(arg.Value) {
+			if hasComments(&arg.Comments) || !isSimple(arg.Value) {
 				return false
 			}
 		}
 		return true
 	case *List:
-		if len(expr.Args) > 1 {
+		if len(expr.Args) > 1 || len(expr.Dangling) > 0 {
 			return false
 		}
 		for _, arg := range expr.Args {
@@ -29,33 +41,201 @@ func isSimple(expr Expr) bool {
 			}
 		}
 		return true
+	case *Map:
+		if len(expr.Entries) > 1 || len(expr.Dangling) > 0 {
+			return false
+		}
+		for _, entry := range expr.Entries {
+			if hasComments(&entry.Comments) || !isSimple(entry.Key) || !isSimple(entry.Value) {
+				return false
+			}
+		}
+		return true
 	default:
 		panic(expr)
 	}
 }
 
-func isDefault(expr Expr) bool {
-	switch expr := expr.(type) {
-	case *String:
-		return expr.Value == ""
-	case *Integer:
-		// HACK
-		return expr.Raw.Text == "0"
-	case *Struct:
+// Style controls how data is laid out.
+type Style struct {
+	// The text for each level of indentation.
+	Indent string
+	// Expressions that would not fit on a line this wide are split over
+	// several lines.  Zero means lines may be any length.
+	Width int
+}
+
+var DefaultStyle = Style{Indent: "  ", Width: 80}
+
+// Tracks the column the next rune will be written to.
+type columnWriter struct {
+	w      io.Writer
+	column int
+}
+
+func (c *columnWriter) Write(p []byte) (int, error) {
+	if i := bytes.LastIndexByte(p, '\n'); i >= 0 {
+		c.column = utf8.RuneCount(p[i+1:])
+	} else {
+		c.column += utf8.RuneCount(p)
+	}
+	return c.w.Write(p)
+}
+
+// Writes data, using the schema, if known, to omit fields that are set to their
+// default values.
+type dataWriter struct {
+	region  *runtime.RegionSchema
+	style   Style
+	columns *columnWriter
+	depth   int
+	// Write every simple expression on one line, regardless of width.
+	flat bool
+	out  *writer.TabbedWriter
+}
+
+func createDataWriter(region *runtime.RegionSchema, style Style, w io.Writer) *dataWriter {
+	columns := &columnWriter{w: w}
+	return &dataWriter{
+		region:  region,
+		style:   style,
+		columns: columns,
+		out:     writer.MakeTabbedWriter(style.Indent, columns),
+	}
+}
+
+func (w *dataWriter) indent() {
+	w.depth += 1
+	w.out.Indent()
+}
+
+func (w *dataWriter) dedent() {
+	w.depth -= 1
+	w.out.Dedent()
+}
+
+// The column the next expression will start at.  Indentation is written
+// lazily, so it is accounted for at the start of a line.
+func (w *dataWriter) column() int {
+	if w.columns.column == 0 {
+		return w.depth * utf8.RuneCountInString(w.style.Indent)
+	}
+	return w.columns.column
+}
+
+// Should the expression be written on one line?
+func (w *dataWriter) fits(expr Expr, expected runtime.TypeSchema) bool {
+	if !isSimple(expr) {
 		return false
-	default:
-		panic(expr)
 	}
+	if w.flat || w.style.Width <= 0 {
+		return true
+	}
+	var b bytes.Buffer
+	flat := createDataWriter(w.region, w.style, &b)
+	flat.flat = true
+	flat.writeExpr(expr, expected)
+	// Leave room for a separator.
+	return w.column()+utf8.RuneCount(b.Bytes())+1 <= w.style.Width
+}
+
+func (w *dataWriter) structType(expr *Struct, expected runtime.TypeSchema) *runtime.StructSchema {
+	if w.region == nil {
+		return nil
+	}
+	if expr.Type != nil {
+		return w.region.StructLUT[expr.Type.Raw.Text]
+	}
+	if o, ok := expected.(*runtime.OptionalSchema); ok {
+		expected = o.Element
+	}
+	t, _ := expected.(*runtime.StructSchema)
+	return t
+}
+
+func (w *dataWriter) fieldType(t *runtime.StructSchema, arg *KeywordArg) runtime.TypeSchema {
+	if t == nil {
+		return nil
+	}
+	f, ok := t.FieldLUT[arg.Name.Text]
+	if !ok {
+		return nil
+	}
+	return f.Type
 }
 
-func writeExpr(expr Expr, out *writer.TabbedWriter) {
+func (w *dataWriter) isDefault(arg *KeywordArg, t *runtime.StructSchema) bool {
+	if t == nil {
+		return false
+	}
+	f, ok := t.FieldLUT[arg.Name.Text]
+	if !ok {
+		return false
+	}
+	switch value := arg.Value.(type) {
+	case *Null:
+		_, ok := f.Type.(*runtime.OptionalSchema)
+		return ok
+	case *List:
+		_, ok := f.Type.(*runtime.ListSchema)
+		return ok && len(value.Args) == 0 && len(value.Dangling) == 0
+	case *Bytes:
+		return len(value.Value) == 0
+	case *Map:
+		return len(value.Entries) == 0 && len(value.Dangling) == 0
+	}
+	expected := defaultValue(f)
+	if expected == nil {
+		return false
+	}
+	// Errors are not reported, a bad value is simply not a default.
+	actual, ok := ConstantValue(arg.Value, f.Type, &parser.Status{})
+	return ok && actual == expected
+}
+
+// Write a field, or the text of a field that could not be parsed.
+func (w *dataWriter) writeKeywordArg(arg *KeywordArg, t *runtime.StructSchema) {
+	if bad, ok := arg.Value.(*Bad); ok {
+		w.out.WriteString(bad.Raw.Text)
+		return
+	}
+	w.out.WriteString(arg.Name.Text)
+	w.out.WriteString(": ")
+	w.writeExpr(arg.Value, w.fieldType(t, arg))
+}
+
+func (w *dataWriter) writeExpr(expr Expr, expected runtime.TypeSchema) {
+	out := w.out
 	switch expr := expr.(type) {
 	case *String:
 		out.WriteString(expr.Raw.Text)
+	case *Bytes:
+		out.WriteString(expr.Raw.Text)
+	case *Bad:
+		out.WriteString(expr.Raw.Text)
 	case *Integer:
 		out.WriteString(expr.Raw.Text)
+	case *Float:
+		out.WriteString(expr.Raw.Text)
+	case *Boolean:
+		out.WriteString(strconv.FormatBool(expr.Value))
+	case *Reference:
+		out.WriteString(expr.Raw.Text)
+	case *KeyReference:
+		out.WriteString(expr.Raw.Text)
+	case *Symbol:
+		out.WriteString(expr.Raw.Text)
+	case *Null:
+		out.WriteString("null")
 	case *List:
-		one_line := isSimple(expr)
+		one_line := w.fits(expr, expected)
+		var element runtime.TypeSchema
+		switch t := expected.(type) {
+		case *runtime.ListSchema:
+			element = t.Element
+		case *runtime.ArraySchema:
+			element = t.Element
+		}
 
 		//if expr.Type != "" {
 		//	out.WriteString(expr.Type)
@@ -64,25 +244,69 @@ func writeExpr(expr Expr, out *writer.TabbedWriter) {
 		out.WriteString("[")
 		if !one_line {
 			out.EndOfLine()
-			out.Indent()
+			w.indent()
 		}
 		for i, arg := range expr.Args {
-			writeExpr(arg, out)
+			if !one_line {
+				parser.WriteLeadingComments(arg.Attached().Leading, out)
+			}
+			w.writeExpr(arg, element)
 			if one_line {
 				if i < len(expr.Args)-1 {
 					out.WriteString(", ")
 				}
 			} else {
 				out.WriteString(",")
-				out.EndOfLine()
+				parser.EndLineWithComments(arg.Attached().Trailing, out)
+			}
+		}
+		if !one_line {
+			parser.WriteLeadingComments(expr.Dangling, out)
+			w.dedent()
+		}
+		out.WriteString("]")
+	case *Map:
+		if len(expr.Entries) == 0 && len(expr.Dangling) == 0 {
+			out.WriteString("[:]")
+			return
+		}
+		one_line := w.fits(expr, expected)
+		var key, value runtime.TypeSchema
+		if t, ok := expected.(*runtime.MapSchema); ok {
+			key, value = t.Key, t.Value
+		}
+		out.WriteString("[")
+		if !one_line {
+			out.EndOfLine()
+			w.indent()
+		}
+		for _, entry := range expr.Entries {
+			if !one_line {
+				parser.WriteLeadingComments(entry.Leading, out)
+			}
+			w.writeExpr(entry.Key, key)
+			out.WriteString(": ")
+			w.writeExpr(entry.Value, value)
+			if !one_line {
+				out.WriteString(",")
+				parser.EndLineWithComments(entry.Trailing, out)
 			}
 		}
 		if !one_line {
-			out.Dedent()
+			if len(expr.Entries) == 0 {
+				out.WriteLine(":")
+			}
+			parser.WriteLeadingComments(expr.Dangling, out)
+			w.dedent()
 		}
 		out.WriteString("]")
 	case *Struct:
-		one_line := isSimple(expr)
+		one_line := w.fits(expr, expected)
+		t := w.structType(expr, expected)
+		if expr.Label != nil {
+			out.WriteString(expr.Label.Raw.Text)
+			out.WriteString(" = ")
+		}
 		if expr.Type != nil {
 			out.WriteString(expr.Type.Raw.Text)
 			out.WriteString(" ")
@@ -91,27 +315,25 @@ func writeExpr(expr Expr, out *writer.TabbedWriter) {
 
 		if one_line {
 			for i, arg := range expr.Args {
-				out.WriteString(arg.Name.Text)
-				out.WriteString(": ")
-				writeExpr(arg.Value, out)
+				w.writeKeywordArg(arg, t)
 				if i < len(expr.Args)-1 {
 					out.WriteString(", ")
 				}
 			}
 		} else {
 			out.EndOfLine()
-			out.Indent()
+			w.indent()
 			for _, arg := range expr.Args {
-				if isDefault(arg.Value) {
+				if w.isDefault(arg, t) && !hasComments(&arg.Comments) {
 					continue
 				}
-				out.WriteString(arg.Name.Text)
-				out.WriteString(": ")
-				writeExpr(arg.Value, out)
+				parser.WriteLeadingComments(arg.Leading, out)
+				w.writeKeywordArg(arg, t)
 				out.WriteString(",")
-				out.EndOfLine()
+				parser.EndLineWithComments(arg.Trailing, out)
 			}
-			out.Dedent()
+			parser.WriteLeadingComments(expr.Dangling, out)
+			w.dedent()
 		}
 		out.WriteString("}")
 	default:
@@ -119,9 +341,42 @@ func writeExpr(expr Expr, out *writer.TabbedWriter) {
 	}
 }
 
+func (w *dataWriter) writeRoot(expr Expr) {
+	c := expr.Attached()
+	parser.WriteLeadingComments(c.Leading, w.out)
+	w.writeExpr(expr, nil)
+	parser.EndLineWithComments(c.Trailing, w.out)
+}
+
 // Convert an AST back to text.
 func WriteData(expr Expr, w io.Writer) {
-	out := writer.MakeTabbedWriter("  ", w)
-	writeExpr(expr, out)
-	out.EndOfLine()
+	createDataWriter(nil, DefaultStyle, w).writeRoot(expr)
+}
+
+// Convert an AST back to text, omitting fields that are set to the default
+// value declared in the schema.
+func WriteDataWithSchema(expr Expr, region *runtime.RegionSchema, w io.Writer) {
+	createDataWriter(region, DefaultStyle, w).writeRoot(expr)
+}
+
+// Convert a document back to text.
+func WriteDocument(doc *Document, w io.Writer) {
+	FormatDocument(doc, DefaultStyle, w)
+}
+
+// Convert a document back to text in the given style.  Every value and comment
+// is kept, so formatting the output again does not change it.
+func FormatDocument(doc *Document, style Style, w io.Writer) {
+	dw := createDataWriter(nil, style, w)
+	out := dw.out
+	for _, include := range doc.Includes {
+		parser.WriteLeadingComments(include.Leading, out)
+		out.WriteString("include ")
+		out.WriteString(include.Path.Raw.Text)
+		parser.EndLineWithComments(include.Trailing, out)
+	}
+	if len(doc.Includes) > 0 {
+		out.EndOfLine()
+	}
+	dw.writeRoot(doc.Root)
 }
@@ -0,0 +1,199 @@
+package human
+
+import (
+	"bytes"
+	"github.com/ncbray/rommy/internal/fixture"
+	"github.com/ncbray/rommy/parser"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func roundTrip(t *testing.T, text string) string {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte(text)
+	info := sources.Add("t", data)
+	e := ParseData(info, data, status)
+	assert.False(t, status.ShouldStop())
+	var out bytes.Buffer
+	WriteData(e, &out)
+	return out.String()
+}
+
+func TestWriteComments(t *testing.T) {
+	text := `// Level data.
+Level {
+  // The name shown in menus.
+  name: "Cave", // Keep it short.
+  /* Spawn order. */
+  spawns: [
+    1, // First wave.
+    2,
+    // More waves go here.
+  ],
+  // Nothing else yet.
+}
+/* end */
+`
+	assert.Equal(t, text, roundTrip(t, text))
+}
+
+func TestWriteCommentsNormalize(t *testing.T) {
+	text := "{a: 1, /* x */ b: 2 // y\n}"
+	expected := "{\n  a: 1,\n  /* x */\n  b: 2, // y\n}\n"
+	actual := roundTrip(t, text)
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, expected, roundTrip(t, actual))
+}
+
+func TestWriteCommentsInsideValues(t *testing.T) {
+	for _, c := range []struct {
+		text     string
+		expected string
+	}{
+		{"{a /* x */: 1}", "{\n  /* x */\n  a: 1,\n}\n"},
+		{"{a: // x\n  1}", "{\n  // x\n  a: 1,\n}\n"},
+		{"{a: n = /* x */ Node /* y */ {}}", "{\n  /* x */\n  /* y */\n  a: n = Node {},\n}\n"},
+		{"[Node /* x */ {}]", "[\n  /* x */\n  Node {\n  },\n]\n"},
+		{"[\"k\" /* x */: /* y */ 1]", "[\n  /* x */\n  /* y */\n  \"k\": 1,\n]\n"},
+		{"/* x */ Node /* y */ {}", "/* x */\n/* y */\nNode {\n}\n"},
+	} {
+		actual := roundTrip(t, c.text)
+		assert.Equal(t, c.expected, actual, c.text)
+		assert.Equal(t, c.expected, roundTrip(t, actual), c.text)
+	}
+}
+
+func TestWriteLabels(t *testing.T) {
+	text := "Node {next: n = Node {name: \"x\", next: @n}}\n"
+	assert.Equal(t, text, roundTrip(t, text))
+}
+
+func TestWriteDocument(t *testing.T) {
+	text := "// Shared data.\ninclude \"a.rommy\" // First.\ninclude \"b.rommy\"\n\nNode {next: @a}\n"
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte(text)
+	info := sources.Add("t", data)
+	doc := ParseDocument(info, data, status)
+	assert.False(t, status.ShouldStop())
+	assert.Equal(t, 2, len(doc.Includes))
+	var out bytes.Buffer
+	WriteDocument(doc, &out)
+	assert.Equal(t, text, out.String())
+}
+
+func TestWriteMaps(t *testing.T) {
+	assert.Equal(t, "Creature {stats: [\"speed\": 3], resistances: [:]}\n", roundTrip(t, "Creature {stats: [\"speed\" : 3], resistances: [ : ]}"))
+	text := `[
+  // Leading.
+  fire: 0.5, // Trailing.
+  water: 2,
+]
+`
+	assert.Equal(t, text, roundTrip(t, text))
+}
+
+func TestWriteDefaults(t *testing.T) {
+	text := `Weapon {
+  name: "sword",
+  durability: 1_00,
+  weight: 1,
+  sharp: false,
+  element: water,
+  price: 0,
+}
+`
+	assert.Equal(t, text, roundTrip(t, text))
+
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte(text)
+	info := sources.Add("t", data)
+	e := ParseData(info, data, status)
+	assert.False(t, status.ShouldStop())
+	var out bytes.Buffer
+	WriteDataWithSchema(e, fixture.CreateFixtureRegion().Schema(), &out)
+	assert.Equal(t, `Weapon {
+  weight: 1,
+  sharp: false,
+  element: water,
+}
+`, out.String())
+}
+
+func TestWriteBytes(t *testing.T) {
+	text := "Tileset {mask: x\"00FF\", palette: [1]}\n"
+	assert.Equal(t, text, roundTrip(t, text))
+	assert.Equal(t, "[\n  b64\"AP8=\",\n  x\"\",\n]\n", roundTrip(t, "[b64\"AP8=\",x\"\"]"))
+}
+
+func format(t *testing.T, text string, style Style) string {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte(text)
+	info := sources.Add("t", data)
+	doc := ParseDocument(info, data, status)
+	assert.False(t, status.ShouldStop())
+	var out bytes.Buffer
+	FormatDocument(doc, style, &out)
+	return out.String()
+}
+
+func TestWriteEveryNode(t *testing.T) {
+	text := `Spell {
+  name: "a\n",
+  done: true,
+  cost: 0x1F,
+  rate: -1.5e3,
+  mask: x"00",
+  element: fire,
+  bonus: null,
+  self: s = Spell {next: @s},
+  owner: &"slime",
+  count: &-3,
+  stats: ["hp": 3],
+  tags: [:],
+  list: [1],
+}
+`
+	assert.Equal(t, text, format(t, text, DefaultStyle))
+}
+
+func TestWriteBad(t *testing.T) {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte("Node {name \"a\", children: [%]}")
+	info := sources.Add("t", data)
+	doc := ParseDocument(info, data, status)
+	assert.True(t, status.ShouldStop())
+	var out bytes.Buffer
+	FormatDocument(doc, DefaultStyle, &out)
+	// The text that could not be parsed is kept.
+	assert.Equal(t, "Node {name \"a\", children: [%]}\n", out.String())
+}
+
+func TestFormatStyle(t *testing.T) {
+	text := "Node {name: \"alpha\", next: Node {name: \"beta\"}}\n"
+	assert.Equal(t, text, format(t, text, DefaultStyle))
+	assert.Equal(t, text, format(t, text, Style{Indent: "\t"}))
+
+	narrow := Style{Indent: "\t", Width: 30}
+	expected := "Node {\n\tname: \"alpha\",\n\tnext: Node {name: \"beta\"},\n}\n"
+	assert.Equal(t, expected, format(t, text, narrow))
+	assert.Equal(t, expected, format(t, expected, narrow))
+	// The indentation counts towards the width.
+	assert.Equal(t, "Node {\n\tnext: Node {\n\t\tname: \"beta\",\n\t},\n}\n", format(t, "Node {next: Node {name: \"beta\"}}", Style{Indent: "\t", Width: 24}))
+}
+
+func TestFormatIsIdempotent(t *testing.T) {
+	for _, file := range []string{"../internal/fixture/fixture.rommy", "../schema/schema.rommy"} {
+		data, err := ioutil.ReadFile(file)
+		assert.NoError(t, err)
+		for _, style := range []Style{DefaultStyle, {Indent: "\t", Width: 20}, {Indent: " "}} {
+			once := format(t, string(data), style)
+			assert.Equal(t, once, format(t, once, style), file)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package fixture contains a generated region used by tests.
+package fixture
+
+//go:generate rommyc fixture.rommy --go_out .
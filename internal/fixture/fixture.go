@@ -0,0 +1,1374 @@
+package fixture
+
+/* Generated with rommyc, do not edit by hand. */
+
+import (
+	"github.com/ncbray/rommy/runtime"
+)
+
+type Element uint32
+
+const (
+	ElementNone Element = iota
+	ElementFire
+	ElementWater
+	ElementEarthQuake
+)
+
+var elementSchema = &runtime.EnumSchema{Name: "Element", Values: []string{"none", "fire", "water", "earth_quake"}, GoType: Element(0)}
+
+type Numbers struct {
+	PoolIndex int
+	I8        int8
+	U8        uint8
+	I64       int64
+	U64       uint64
+	F32       float32
+	F64       float64
+	Ints      []int32
+	Floats    []float64
+}
+
+func (s *Numbers) Schema() *runtime.StructSchema {
+	return numbersSchema
+}
+
+var numbersSchema = &runtime.StructSchema{Name: "Numbers", GoType: (*Numbers)(nil)}
+
+type Node struct {
+	PoolIndex int
+	Name      string
+	Next      *Node
+	Children  []*Node
+	Named     map[string]*Node
+}
+
+func (s *Node) Schema() *runtime.StructSchema {
+	return nodeSchema
+}
+
+var nodeSchema = &runtime.StructSchema{Name: "Node", GoType: (*Node)(nil)}
+
+type Edge struct {
+	PoolIndex int
+	From      *Node
+	To        *Node
+}
+
+func (s *Edge) Schema() *runtime.StructSchema {
+	return edgeSchema
+}
+
+var edgeSchema = &runtime.StructSchema{Name: "Edge", GoType: (*Edge)(nil)}
+
+type Creature struct {
+	PoolIndex   int
+	Name        string
+	Element     Element
+	Weaknesses  []Element
+	Stats       map[string]int32
+	Resistances map[Element]float32
+}
+
+func (s *Creature) Schema() *runtime.StructSchema {
+	return creatureSchema
+}
+
+var creatureSchema = &runtime.StructSchema{Name: "Creature", GoType: (*Creature)(nil)}
+
+// Weapon has a default for every field.
+type Weapon struct {
+	PoolIndex  int
+	Name       string
+	Durability int32
+	Weight     float32
+	Sharp      bool
+	Element    Element
+	// Price in gold.
+	//
+	// Deprecated: the schema marks price as deprecated, do not set it in new data.
+	Price uint16
+}
+
+func (s *Weapon) Schema() *runtime.StructSchema {
+	return weaponSchema
+}
+
+var weaponSchema = &runtime.StructSchema{Name: "Weapon", GoType: (*Weapon)(nil)}
+
+type Heal struct {
+	PoolIndex int
+	Amount    int32
+}
+
+func (s *Heal) Schema() *runtime.StructSchema {
+	return healSchema
+}
+
+var healSchema = &runtime.StructSchema{Name: "Heal", GoType: (*Heal)(nil)}
+
+type Damage struct {
+	PoolIndex int
+	Amount    int32
+	Element   Element
+}
+
+func (s *Damage) Schema() *runtime.StructSchema {
+	return damageSchema
+}
+
+var damageSchema = &runtime.StructSchema{Name: "Damage", GoType: (*Damage)(nil)}
+
+type Spell struct {
+	PoolIndex int
+	Name      string
+	Effect    Effect
+	Bonus     Effect
+	Combo     []Effect
+}
+
+func (s *Spell) Schema() *runtime.StructSchema {
+	return spellSchema
+}
+
+var spellSchema = &runtime.StructSchema{Name: "Spell", GoType: (*Spell)(nil)}
+
+type Tileset struct {
+	PoolIndex int
+	Name      string
+	Palette   [4]uint16
+	Mask      []byte
+	Corners   [2]*Node
+}
+
+func (s *Tileset) Schema() *runtime.StructSchema {
+	return tilesetSchema
+}
+
+var tilesetSchema = &runtime.StructSchema{Name: "Tileset", GoType: (*Tileset)(nil)}
+
+type Encounter struct {
+	PoolIndex int
+	Creatures []*Creature
+	// The creature that must be defeated last,
+	// if there is one.
+	Boss *Creature
+}
+
+func (s *Encounter) Schema() *runtime.StructSchema {
+	return encounterSchema
+}
+
+var encounterSchema = &runtime.StructSchema{Name: "Encounter", GoType: (*Encounter)(nil)}
+
+type Effect interface {
+	Schema() *runtime.StructSchema
+	isEffect()
+}
+
+func (s *Heal) isEffect() {
+}
+
+func (s *Damage) isEffect() {
+}
+
+var effectSchema = &runtime.UnionSchema{Name: "Effect", Arms: []*runtime.StructSchema{healSchema, damageSchema}, GoType: (*Effect)(nil)}
+
+// Fixture exercises every kind of type the generators support.
+type FixtureRegion struct {
+	NumbersPool   []*Numbers
+	NodePool      []*Node
+	EdgePool      []*Edge
+	CreaturePool  []*Creature
+	WeaponPool    []*Weapon
+	HealPool      []*Heal
+	DamagePool    []*Damage
+	SpellPool     []*Spell
+	TilesetPool   []*Tileset
+	EncounterPool []*Encounter
+	creatureKeys  map[string]*Creature
+}
+
+func CreateFixtureRegion() *FixtureRegion {
+	return &FixtureRegion{}
+}
+
+var fixtureRegionSchema = &runtime.RegionSchema{Name: "Fixture", GoType: (*FixtureRegion)(nil)}
+
+func (r *FixtureRegion) Schema() *runtime.RegionSchema {
+	return fixtureRegionSchema
+}
+
+func (r *FixtureRegion) AllocateNumbers() *Numbers {
+	o := &Numbers{}
+	o.PoolIndex = len(r.NumbersPool)
+	r.NumbersPool = append(r.NumbersPool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateNode() *Node {
+	o := &Node{}
+	o.PoolIndex = len(r.NodePool)
+	r.NodePool = append(r.NodePool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateEdge() *Edge {
+	o := &Edge{}
+	o.PoolIndex = len(r.EdgePool)
+	r.EdgePool = append(r.EdgePool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateCreature() *Creature {
+	o := &Creature{}
+	o.PoolIndex = len(r.CreaturePool)
+	r.CreaturePool = append(r.CreaturePool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateWeapon() *Weapon {
+	o := &Weapon{}
+	o.Name = "sword"
+	o.Durability = 100
+	o.Weight = 1.5
+	o.Sharp = true
+	o.Element = ElementFire
+	o.PoolIndex = len(r.WeaponPool)
+	r.WeaponPool = append(r.WeaponPool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateHeal() *Heal {
+	o := &Heal{}
+	o.PoolIndex = len(r.HealPool)
+	r.HealPool = append(r.HealPool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateDamage() *Damage {
+	o := &Damage{}
+	o.PoolIndex = len(r.DamagePool)
+	r.DamagePool = append(r.DamagePool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateSpell() *Spell {
+	o := &Spell{}
+	o.PoolIndex = len(r.SpellPool)
+	r.SpellPool = append(r.SpellPool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateTileset() *Tileset {
+	o := &Tileset{}
+	o.PoolIndex = len(r.TilesetPool)
+	r.TilesetPool = append(r.TilesetPool, o)
+	return o
+}
+
+func (r *FixtureRegion) AllocateEncounter() *Encounter {
+	o := &Encounter{}
+	o.PoolIndex = len(r.EncounterPool)
+	r.EncounterPool = append(r.EncounterPool, o)
+	return o
+}
+
+func (r *FixtureRegion) Allocate(name string) interface{} {
+	switch name {
+	case "Numbers":
+		return r.AllocateNumbers()
+	case "Node":
+		return r.AllocateNode()
+	case "Edge":
+		return r.AllocateEdge()
+	case "Creature":
+		return r.AllocateCreature()
+	case "Weapon":
+		return r.AllocateWeapon()
+	case "Heal":
+		return r.AllocateHeal()
+	case "Damage":
+		return r.AllocateDamage()
+	case "Spell":
+		return r.AllocateSpell()
+	case "Tileset":
+		return r.AllocateTileset()
+	case "Encounter":
+		return r.AllocateEncounter()
+	}
+	return nil
+}
+
+func (r *FixtureRegion) MarshalBinary() ([]byte, error) {
+	s := runtime.MakeSerializer()
+	var err error
+	runtime.WriteHeader(s, r.Schema())
+	err = s.WriteCount(len(r.NumbersPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.NodePool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.EdgePool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.CreaturePool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.WeaponPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.HealPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.DamagePool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.SpellPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.TilesetPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.EncounterPool))
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range r.NumbersPool {
+		s.WriteInt8(o.I8)
+		s.WriteUint8(o.U8)
+		s.WriteInt64(o.I64)
+		s.WriteUint64(o.U64)
+		s.WriteFloat32(o.F32)
+		s.WriteFloat64(o.F64)
+		err = s.WriteCount(len(o.Ints))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Ints {
+			s.WriteInt32(o0)
+		}
+		err = s.WriteCount(len(o.Floats))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Floats {
+			s.WriteFloat64(o0)
+		}
+	}
+	for _, o := range r.NodePool {
+		s.WriteString(o.Name)
+		if o.Next == nil {
+			err = s.WriteOptionalIndex(runtime.NoIndex, len(r.NodePool))
+		} else {
+			err = s.WriteOptionalIndex(o.Next.PoolIndex, len(r.NodePool))
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = s.WriteCount(len(o.Children))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Children {
+			if o0 == nil {
+				return nil, runtime.NilReference("Node.children")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.NodePool))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = s.WriteCount(len(o.Named))
+		if err != nil {
+			return nil, err
+		}
+		for _, k0 := range runtime.SortedKeys(o.Named).([]string) {
+			s.WriteString(k0)
+			if o.Named[k0] == nil {
+				return nil, runtime.NilReference("Node.named")
+			}
+			err = s.WriteIndex(o.Named[k0].PoolIndex, len(r.NodePool))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, o := range r.EdgePool {
+		if o.From == nil {
+			return nil, runtime.NilReference("Edge.from")
+		}
+		err = s.WriteIndex(o.From.PoolIndex, len(r.NodePool))
+		if err != nil {
+			return nil, err
+		}
+		if o.To == nil {
+			err = s.WriteOptionalIndex(runtime.NoIndex, len(r.NodePool))
+		} else {
+			err = s.WriteOptionalIndex(o.To.PoolIndex, len(r.NodePool))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, o := range r.CreaturePool {
+		s.WriteString(o.Name)
+		err = s.WriteIndex(int(o.Element), 4)
+		if err != nil {
+			return nil, err
+		}
+		err = s.WriteCount(len(o.Weaknesses))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Weaknesses {
+			err = s.WriteIndex(int(o0), 4)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = s.WriteCount(len(o.Stats))
+		if err != nil {
+			return nil, err
+		}
+		for _, k0 := range runtime.SortedKeys(o.Stats).([]string) {
+			s.WriteString(k0)
+			s.WriteInt32(o.Stats[k0])
+		}
+		err = s.WriteCount(len(o.Resistances))
+		if err != nil {
+			return nil, err
+		}
+		for _, k0 := range runtime.SortedKeys(o.Resistances).([]Element) {
+			err = s.WriteIndex(int(k0), 4)
+			if err != nil {
+				return nil, err
+			}
+			s.WriteFloat32(o.Resistances[k0])
+		}
+	}
+	for _, o := range r.WeaponPool {
+		s.WriteString(o.Name)
+		s.WriteInt32(o.Durability)
+		s.WriteFloat32(o.Weight)
+		s.WriteBool(o.Sharp)
+		err = s.WriteIndex(int(o.Element), 4)
+		if err != nil {
+			return nil, err
+		}
+		s.WriteUint16(o.Price)
+	}
+	for _, o := range r.HealPool {
+		s.WriteInt32(o.Amount)
+	}
+	for _, o := range r.DamagePool {
+		s.WriteInt32(o.Amount)
+		err = s.WriteIndex(int(o.Element), 4)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, o := range r.SpellPool {
+		s.WriteString(o.Name)
+		switch u0 := o.Effect.(type) {
+		case *Heal:
+			err = s.WriteIndex(0, 2)
+			if err != nil {
+				return nil, err
+			}
+			err = s.WriteIndex(u0.PoolIndex, len(r.HealPool))
+		case *Damage:
+			err = s.WriteIndex(1, 2)
+			if err != nil {
+				return nil, err
+			}
+			err = s.WriteIndex(u0.PoolIndex, len(r.DamagePool))
+		default:
+			return nil, runtime.NilReference("Spell.effect")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch u0 := o.Bonus.(type) {
+		case *Heal:
+			err = s.WriteOptionalIndex(0, 2)
+			if err != nil {
+				return nil, err
+			}
+			err = s.WriteIndex(u0.PoolIndex, len(r.HealPool))
+		case *Damage:
+			err = s.WriteOptionalIndex(1, 2)
+			if err != nil {
+				return nil, err
+			}
+			err = s.WriteIndex(u0.PoolIndex, len(r.DamagePool))
+		case nil:
+			err = s.WriteOptionalIndex(runtime.NoIndex, 2)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = s.WriteCount(len(o.Combo))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Combo {
+			switch u1 := o0.(type) {
+			case *Heal:
+				err = s.WriteIndex(0, 2)
+				if err != nil {
+					return nil, err
+				}
+				err = s.WriteIndex(u1.PoolIndex, len(r.HealPool))
+			case *Damage:
+				err = s.WriteIndex(1, 2)
+				if err != nil {
+					return nil, err
+				}
+				err = s.WriteIndex(u1.PoolIndex, len(r.DamagePool))
+			default:
+				return nil, runtime.NilReference("Spell.combo")
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, o := range r.TilesetPool {
+		s.WriteString(o.Name)
+		for _, o0 := range o.Palette {
+			s.WriteUint16(o0)
+		}
+		s.WriteBytes(o.Mask)
+		for _, o0 := range o.Corners {
+			if o0 == nil {
+				err = s.WriteOptionalIndex(runtime.NoIndex, len(r.NodePool))
+			} else {
+				err = s.WriteOptionalIndex(o0.PoolIndex, len(r.NodePool))
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, o := range r.EncounterPool {
+		err = s.WriteCount(len(o.Creatures))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Creatures {
+			if o0 == nil {
+				return nil, runtime.NilReference("Encounter.creatures")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.CreaturePool))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if o.Boss == nil {
+			err = s.WriteOptionalIndex(runtime.NoIndex, len(r.CreaturePool))
+		} else {
+			err = s.WriteOptionalIndex(o.Boss.PoolIndex, len(r.CreaturePool))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.Data(), nil
+}
+
+func (r *FixtureRegion) UnmarshalBinary(data []byte) error {
+	d := runtime.MakeDeserializer(data)
+	var index int
+	var err error
+	err = runtime.CheckHeader(d, r.Schema())
+	if err != nil {
+		return err
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateNumbers()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateNode()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateEdge()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateCreature()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateWeapon()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateHeal()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateDamage()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateSpell()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateTileset()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateEncounter()
+	}
+	for _, o := range r.NumbersPool {
+		o.I8, err = d.ReadInt8()
+		if err != nil {
+			return err
+		}
+		o.U8, err = d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		o.I64, err = d.ReadInt64()
+		if err != nil {
+			return err
+		}
+		o.U64, err = d.ReadUint64()
+		if err != nil {
+			return err
+		}
+		o.F32, err = d.ReadFloat32()
+		if err != nil {
+			return err
+		}
+		o.F64, err = d.ReadFloat64()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Ints = make([]int32, index)
+		for i0, _ := range o.Ints {
+			o.Ints[i0], err = d.ReadInt32()
+			if err != nil {
+				return err
+			}
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Floats = make([]float64, index)
+		for i0, _ := range o.Floats {
+			o.Floats[i0], err = d.ReadFloat64()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, o := range r.NodePool {
+		o.Name, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadOptionalIndex(len(r.NodePool))
+		if err != nil {
+			return err
+		}
+		if index != runtime.NoIndex {
+			o.Next = r.NodePool[index]
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Children = make([]*Node, index)
+		for i0, _ := range o.Children {
+			index, err = d.ReadIndex(len(r.NodePool))
+			if err != nil {
+				return err
+			}
+			o.Children[i0] = r.NodePool[index]
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Named = make(map[string]*Node, index)
+		for i0, n0 := 0, index; i0 < n0; i0++ {
+			var k0 string
+			var v0 *Node
+			k0, err = d.ReadString()
+			if err != nil {
+				return err
+			}
+			index, err = d.ReadIndex(len(r.NodePool))
+			if err != nil {
+				return err
+			}
+			v0 = r.NodePool[index]
+			if _, ok := o.Named[k0]; ok {
+				return runtime.ErrDuplicateKey
+			}
+			o.Named[k0] = v0
+		}
+	}
+	for _, o := range r.EdgePool {
+		index, err = d.ReadIndex(len(r.NodePool))
+		if err != nil {
+			return err
+		}
+		o.From = r.NodePool[index]
+		index, err = d.ReadOptionalIndex(len(r.NodePool))
+		if err != nil {
+			return err
+		}
+		if index != runtime.NoIndex {
+			o.To = r.NodePool[index]
+		}
+	}
+	for _, o := range r.CreaturePool {
+		o.Name, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadIndex(4)
+		if err != nil {
+			return err
+		}
+		o.Element = Element(index)
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Weaknesses = make([]Element, index)
+		for i0, _ := range o.Weaknesses {
+			index, err = d.ReadIndex(4)
+			if err != nil {
+				return err
+			}
+			o.Weaknesses[i0] = Element(index)
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Stats = make(map[string]int32, index)
+		for i0, n0 := 0, index; i0 < n0; i0++ {
+			var k0 string
+			var v0 int32
+			k0, err = d.ReadString()
+			if err != nil {
+				return err
+			}
+			v0, err = d.ReadInt32()
+			if err != nil {
+				return err
+			}
+			if _, ok := o.Stats[k0]; ok {
+				return runtime.ErrDuplicateKey
+			}
+			o.Stats[k0] = v0
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Resistances = make(map[Element]float32, index)
+		for i0, n0 := 0, index; i0 < n0; i0++ {
+			var k0 Element
+			var v0 float32
+			index, err = d.ReadIndex(4)
+			if err != nil {
+				return err
+			}
+			k0 = Element(index)
+			v0, err = d.ReadFloat32()
+			if err != nil {
+				return err
+			}
+			if _, ok := o.Resistances[k0]; ok {
+				return runtime.ErrDuplicateKey
+			}
+			o.Resistances[k0] = v0
+		}
+	}
+	for _, o := range r.WeaponPool {
+		o.Name, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.Durability, err = d.ReadInt32()
+		if err != nil {
+			return err
+		}
+		o.Weight, err = d.ReadFloat32()
+		if err != nil {
+			return err
+		}
+		o.Sharp, err = d.ReadBool()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadIndex(4)
+		if err != nil {
+			return err
+		}
+		o.Element = Element(index)
+		o.Price, err = d.ReadUint16()
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.HealPool {
+		o.Amount, err = d.ReadInt32()
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.DamagePool {
+		o.Amount, err = d.ReadInt32()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadIndex(4)
+		if err != nil {
+			return err
+		}
+		o.Element = Element(index)
+	}
+	for _, o := range r.SpellPool {
+		o.Name, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadIndex(2)
+		if err != nil {
+			return err
+		}
+		switch index {
+		case 0:
+			index, err = d.ReadIndex(len(r.HealPool))
+			if err != nil {
+				return err
+			}
+			o.Effect = r.HealPool[index]
+		case 1:
+			index, err = d.ReadIndex(len(r.DamagePool))
+			if err != nil {
+				return err
+			}
+			o.Effect = r.DamagePool[index]
+		}
+		index, err = d.ReadOptionalIndex(2)
+		if err != nil {
+			return err
+		}
+		switch index {
+		case 0:
+			index, err = d.ReadIndex(len(r.HealPool))
+			if err != nil {
+				return err
+			}
+			o.Bonus = r.HealPool[index]
+		case 1:
+			index, err = d.ReadIndex(len(r.DamagePool))
+			if err != nil {
+				return err
+			}
+			o.Bonus = r.DamagePool[index]
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Combo = make([]Effect, index)
+		for i0, _ := range o.Combo {
+			index, err = d.ReadIndex(2)
+			if err != nil {
+				return err
+			}
+			switch index {
+			case 0:
+				index, err = d.ReadIndex(len(r.HealPool))
+				if err != nil {
+					return err
+				}
+				o.Combo[i0] = r.HealPool[index]
+			case 1:
+				index, err = d.ReadIndex(len(r.DamagePool))
+				if err != nil {
+					return err
+				}
+				o.Combo[i0] = r.DamagePool[index]
+			}
+		}
+	}
+	for _, o := range r.TilesetPool {
+		o.Name, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		for i0, _ := range o.Palette {
+			o.Palette[i0], err = d.ReadUint16()
+			if err != nil {
+				return err
+			}
+		}
+		o.Mask, err = d.ReadBytes()
+		if err != nil {
+			return err
+		}
+		for i0, _ := range o.Corners {
+			index, err = d.ReadOptionalIndex(len(r.NodePool))
+			if err != nil {
+				return err
+			}
+			if index != runtime.NoIndex {
+				o.Corners[i0] = r.NodePool[index]
+			}
+		}
+	}
+	for _, o := range r.EncounterPool {
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Creatures = make([]*Creature, index)
+		for i0, _ := range o.Creatures {
+			index, err = d.ReadIndex(len(r.CreaturePool))
+			if err != nil {
+				return err
+			}
+			o.Creatures[i0] = r.CreaturePool[index]
+		}
+		index, err = d.ReadOptionalIndex(len(r.CreaturePool))
+		if err != nil {
+			return err
+		}
+		if index != runtime.NoIndex {
+			o.Boss = r.CreaturePool[index]
+		}
+	}
+	err = r.indexCreature()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type FixtureCloner struct {
+	src          *FixtureRegion
+	dst          *FixtureRegion
+	numbersMap   []*Numbers
+	nodeMap      []*Node
+	edgeMap      []*Edge
+	creatureMap  []*Creature
+	weaponMap    []*Weapon
+	healMap      []*Heal
+	damageMap    []*Damage
+	spellMap     []*Spell
+	tilesetMap   []*Tileset
+	encounterMap []*Encounter
+}
+
+func CreateFixtureCloner(src *FixtureRegion, dst *FixtureRegion) *FixtureCloner {
+	c := &FixtureCloner{
+		src:          src,
+		dst:          dst,
+		numbersMap:   make([]*Numbers, len(src.NumbersPool)),
+		nodeMap:      make([]*Node, len(src.NodePool)),
+		edgeMap:      make([]*Edge, len(src.EdgePool)),
+		creatureMap:  make([]*Creature, len(src.CreaturePool)),
+		weaponMap:    make([]*Weapon, len(src.WeaponPool)),
+		healMap:      make([]*Heal, len(src.HealPool)),
+		damageMap:    make([]*Damage, len(src.DamagePool)),
+		spellMap:     make([]*Spell, len(src.SpellPool)),
+		tilesetMap:   make([]*Tileset, len(src.TilesetPool)),
+		encounterMap: make([]*Encounter, len(src.EncounterPool)),
+	}
+	return c
+}
+
+func (c *FixtureCloner) CloneNumbers(src *Numbers) *Numbers {
+	dst := c.numbersMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateNumbers()
+	c.numbersMap[src.PoolIndex] = dst
+	dst.I8 = src.I8
+	dst.U8 = src.U8
+	dst.I64 = src.I64
+	dst.U64 = src.U64
+	dst.F32 = src.F32
+	dst.F64 = src.F64
+	dst.Ints = make([]int32, len(src.Ints))
+	for i0, _ := range src.Ints {
+		dst.Ints[i0] = src.Ints[i0]
+	}
+	dst.Floats = make([]float64, len(src.Floats))
+	for i0, _ := range src.Floats {
+		dst.Floats[i0] = src.Floats[i0]
+	}
+	return dst
+}
+
+func (c *FixtureCloner) CloneNode(src *Node) *Node {
+	dst := c.nodeMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateNode()
+	c.nodeMap[src.PoolIndex] = dst
+	dst.Name = src.Name
+	if src.Next != nil {
+		dst.Next = c.CloneNode(src.Next)
+	}
+	dst.Children = make([]*Node, len(src.Children))
+	for i0, _ := range src.Children {
+		dst.Children[i0] = c.CloneNode(src.Children[i0])
+	}
+	dst.Named = make(map[string]*Node, len(src.Named))
+	for k0, _ := range src.Named {
+		dst.Named[k0] = c.CloneNode(src.Named[k0])
+	}
+	return dst
+}
+
+func (c *FixtureCloner) CloneEdge(src *Edge) *Edge {
+	dst := c.edgeMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateEdge()
+	c.edgeMap[src.PoolIndex] = dst
+	dst.From = c.CloneNode(src.From)
+	if src.To != nil {
+		dst.To = c.CloneNode(src.To)
+	}
+	return dst
+}
+
+func (c *FixtureCloner) CloneCreature(src *Creature) *Creature {
+	dst := c.creatureMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateCreature()
+	c.creatureMap[src.PoolIndex] = dst
+	dst.Name = src.Name
+	dst.Element = src.Element
+	dst.Weaknesses = make([]Element, len(src.Weaknesses))
+	for i0, _ := range src.Weaknesses {
+		dst.Weaknesses[i0] = src.Weaknesses[i0]
+	}
+	dst.Stats = make(map[string]int32, len(src.Stats))
+	for k0, _ := range src.Stats {
+		dst.Stats[k0] = src.Stats[k0]
+	}
+	dst.Resistances = make(map[Element]float32, len(src.Resistances))
+	for k0, _ := range src.Resistances {
+		dst.Resistances[k0] = src.Resistances[k0]
+	}
+	return dst
+}
+
+func (c *FixtureCloner) CloneWeapon(src *Weapon) *Weapon {
+	dst := c.weaponMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateWeapon()
+	c.weaponMap[src.PoolIndex] = dst
+	dst.Name = src.Name
+	dst.Durability = src.Durability
+	dst.Weight = src.Weight
+	dst.Sharp = src.Sharp
+	dst.Element = src.Element
+	dst.Price = src.Price
+	return dst
+}
+
+func (c *FixtureCloner) CloneHeal(src *Heal) *Heal {
+	dst := c.healMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateHeal()
+	c.healMap[src.PoolIndex] = dst
+	dst.Amount = src.Amount
+	return dst
+}
+
+func (c *FixtureCloner) CloneDamage(src *Damage) *Damage {
+	dst := c.damageMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateDamage()
+	c.damageMap[src.PoolIndex] = dst
+	dst.Amount = src.Amount
+	dst.Element = src.Element
+	return dst
+}
+
+func (c *FixtureCloner) CloneSpell(src *Spell) *Spell {
+	dst := c.spellMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateSpell()
+	c.spellMap[src.PoolIndex] = dst
+	dst.Name = src.Name
+	switch u0 := src.Effect.(type) {
+	case *Heal:
+		dst.Effect = c.CloneHeal(u0)
+	case *Damage:
+		dst.Effect = c.CloneDamage(u0)
+	}
+	if src.Bonus != nil {
+		switch u0 := src.Bonus.(type) {
+		case *Heal:
+			dst.Bonus = c.CloneHeal(u0)
+		case *Damage:
+			dst.Bonus = c.CloneDamage(u0)
+		}
+	}
+	dst.Combo = make([]Effect, len(src.Combo))
+	for i0, _ := range src.Combo {
+		switch u1 := src.Combo[i0].(type) {
+		case *Heal:
+			dst.Combo[i0] = c.CloneHeal(u1)
+		case *Damage:
+			dst.Combo[i0] = c.CloneDamage(u1)
+		}
+	}
+	return dst
+}
+
+func (c *FixtureCloner) CloneTileset(src *Tileset) *Tileset {
+	dst := c.tilesetMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateTileset()
+	c.tilesetMap[src.PoolIndex] = dst
+	dst.Name = src.Name
+	for i0, _ := range src.Palette {
+		dst.Palette[i0] = src.Palette[i0]
+	}
+	dst.Mask = append([]byte(nil), src.Mask...)
+	for i0, _ := range src.Corners {
+		if src.Corners[i0] != nil {
+			dst.Corners[i0] = c.CloneNode(src.Corners[i0])
+		}
+	}
+	return dst
+}
+
+func (c *FixtureCloner) CloneEncounter(src *Encounter) *Encounter {
+	dst := c.encounterMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateEncounter()
+	c.encounterMap[src.PoolIndex] = dst
+	dst.Creatures = make([]*Creature, len(src.Creatures))
+	for i0, _ := range src.Creatures {
+		dst.Creatures[i0] = c.CloneCreature(src.Creatures[i0])
+	}
+	if src.Boss != nil {
+		dst.Boss = c.CloneCreature(src.Boss)
+	}
+	return dst
+}
+
+// Index the Creature pool by key.  Two structs with the same key are an error.
+func (r *FixtureRegion) indexCreature() error {
+	r.creatureKeys = make(map[string]*Creature, len(r.CreaturePool))
+	for _, o := range r.CreaturePool {
+		if _, ok := r.creatureKeys[o.Name]; ok {
+			return runtime.ErrDuplicateKey
+		}
+		r.creatureKeys[o.Name] = o
+	}
+	return nil
+}
+
+// Give a Creature a key, so it can be found with Creature.  Fails if another
+// Creature already has the key.
+func (r *FixtureRegion) SetCreatureKey(o *Creature, key string) error {
+	if other, ok := r.creatureKeys[key]; ok && other != o {
+		return runtime.ErrDuplicateKey
+	}
+	if r.creatureKeys == nil {
+		r.creatureKeys = map[string]*Creature{}
+	}
+	if r.creatureKeys[o.Name] == o {
+		delete(r.creatureKeys, o.Name)
+	}
+	o.Name = key
+	r.creatureKeys[key] = o
+	return nil
+}
+
+// Find the Creature with a key.
+func (r *FixtureRegion) Creature(key string) (*Creature, bool) {
+	o, ok := r.creatureKeys[key]
+	return o, ok
+}
+
+// Rebuild the indexes used to find structs by key, after keys were assigned
+// without the key setters.  Two structs with the same key are an error.
+func (r *FixtureRegion) Reindex() error {
+	var err error
+	err = r.indexCreature()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Check the region against the constraints in its schema.
+func (r *FixtureRegion) Validate() error {
+	return runtime.Validate(r)
+}
+
+func init() {
+
+	numbersSchema.Fields = []*runtime.FieldSchema{
+		{Name: "i8", Type: &runtime.IntegerSchema{Bits: 8, Unsigned: false}},
+		{Name: "u8", Type: &runtime.IntegerSchema{Bits: 8, Unsigned: true}},
+		{Name: "i64", Type: &runtime.IntegerSchema{Bits: 64, Unsigned: false}},
+		{Name: "u64", Type: &runtime.IntegerSchema{Bits: 64, Unsigned: true}},
+		{Name: "f32", Type: &runtime.FloatSchema{Bits: 32}},
+		{Name: "f64", Type: &runtime.FloatSchema{Bits: 64}},
+		{Name: "ints", Type: (&runtime.IntegerSchema{Bits: 32, Unsigned: false}).List()},
+		{Name: "floats", Type: (&runtime.FloatSchema{Bits: 64}).List()},
+	}
+
+	nodeSchema.Fields = []*runtime.FieldSchema{
+		{Name: "name", Type: &runtime.StringSchema{}},
+		{Name: "next", Type: &runtime.OptionalSchema{Element: nodeSchema}},
+		{Name: "children", Type: (nodeSchema).List()},
+		{Name: "named", Type: &runtime.MapSchema{Key: &runtime.StringSchema{}, Value: nodeSchema}},
+	}
+
+	edgeSchema.Fields = []*runtime.FieldSchema{
+		{Name: "from", Type: nodeSchema},
+		{Name: "to", Type: &runtime.OptionalSchema{Element: nodeSchema}},
+	}
+
+	creatureSchema.Fields = []*runtime.FieldSchema{
+		{Name: "name", Type: &runtime.StringSchema{}, Constraints: &runtime.Constraints{MinLength: 1, MaxLength: 32}, Key: true},
+		{Name: "element", Type: elementSchema},
+		{Name: "weaknesses", Type: (elementSchema).List()},
+		{Name: "stats", Type: &runtime.MapSchema{Key: &runtime.StringSchema{}, Value: &runtime.IntegerSchema{Bits: 32, Unsigned: false}}},
+		{Name: "resistances", Type: &runtime.MapSchema{Key: elementSchema, Value: &runtime.FloatSchema{Bits: 32}}},
+	}
+
+	weaponSchema.Fields = []*runtime.FieldSchema{
+		{Name: "name", Type: &runtime.StringSchema{}, Default: "sword"},
+		{Name: "durability", Type: &runtime.IntegerSchema{Bits: 32, Unsigned: false}, Default: int64(100), Aliases: []string{"hp"}, Constraints: &runtime.Constraints{Min: int64(0), Max: int64(1000)}},
+		{Name: "weight", Type: &runtime.FloatSchema{Bits: 32}, Default: float64(1.5), Constraints: &runtime.Constraints{Min: float64(0)}},
+		{Name: "sharp", Type: &runtime.BooleanSchema{}, Default: true},
+		{Name: "element", Type: elementSchema, Default: 1},
+		{Name: "price", Type: &runtime.IntegerSchema{Bits: 16, Unsigned: true}, Deprecated: true},
+	}
+
+	healSchema.Fields = []*runtime.FieldSchema{
+		{Name: "amount", Type: &runtime.IntegerSchema{Bits: 32, Unsigned: false}},
+	}
+
+	damageSchema.Fields = []*runtime.FieldSchema{
+		{Name: "amount", Type: &runtime.IntegerSchema{Bits: 32, Unsigned: false}},
+		{Name: "element", Type: elementSchema},
+	}
+
+	spellSchema.Fields = []*runtime.FieldSchema{
+		{Name: "name", Type: &runtime.StringSchema{}, Constraints: &runtime.Constraints{Pattern: "[A-Za-z ]+"}},
+		{Name: "effect", Type: effectSchema},
+		{Name: "bonus", Type: &runtime.OptionalSchema{Element: effectSchema}},
+		{Name: "combo", Type: (effectSchema).List()},
+	}
+
+	tilesetSchema.Fields = []*runtime.FieldSchema{
+		{Name: "name", Type: &runtime.StringSchema{}},
+		{Name: "palette", Type: &runtime.ArraySchema{Element: &runtime.IntegerSchema{Bits: 16, Unsigned: true}, Length: 4}},
+		{Name: "mask", Type: &runtime.BytesSchema{}},
+		{Name: "corners", Type: &runtime.ArraySchema{Element: &runtime.OptionalSchema{Element: nodeSchema}, Length: 2}},
+	}
+
+	encounterSchema.Fields = []*runtime.FieldSchema{
+		{Name: "creatures", Type: (creatureSchema).List()},
+		{Name: "boss", Type: &runtime.OptionalSchema{Element: creatureSchema}},
+	}
+
+	fixtureRegionSchema.Structs = []*runtime.StructSchema{
+		numbersSchema,
+		nodeSchema,
+		edgeSchema,
+		creatureSchema,
+		weaponSchema,
+		healSchema,
+		damageSchema,
+		spellSchema,
+		tilesetSchema,
+		encounterSchema,
+	}
+	fixtureRegionSchema.Unions = []*runtime.UnionSchema{
+		effectSchema,
+	}
+	fixtureRegionSchema.Enums = []*runtime.EnumSchema{
+		elementSchema,
+	}
+	fixtureRegionSchema.Init()
+}
@@ -0,0 +1,94 @@
+package fixture
+
+import (
+	"testing"
+
+	"github.com/ncbray/rommy/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLookup(t *testing.T) {
+	r := CreateFixtureRegion()
+	_, ok := r.Creature("slime")
+	assert.False(t, ok)
+
+	slime := r.AllocateCreature()
+	assert.NoError(t, r.SetCreatureKey(slime, "slime"))
+	bat := r.AllocateCreature()
+	assert.NoError(t, r.SetCreatureKey(bat, "bat"))
+	assert.Equal(t, "bat", bat.Name)
+	o, ok := r.Creature("slime")
+	assert.True(t, ok)
+	assert.Equal(t, slime, o)
+	o, ok = r.Creature("bat")
+	assert.True(t, ok)
+	assert.Equal(t, bat, o)
+
+	// A changed key is found under its new value, and not the old one.
+	assert.NoError(t, r.SetCreatureKey(slime, "ooze"))
+	_, ok = r.Creature("slime")
+	assert.False(t, ok)
+	o, ok = r.Creature("ooze")
+	assert.True(t, ok)
+	assert.Equal(t, slime, o)
+
+	// A key cannot be taken from another struct.
+	again := r.AllocateCreature()
+	assert.Equal(t, runtime.ErrDuplicateKey, r.SetCreatureKey(again, "bat"))
+	assert.Equal(t, "", again.Name)
+	o, _ = r.Creature("bat")
+	assert.Equal(t, bat, o)
+
+	// Setting the key a struct already has is not a conflict.
+	assert.NoError(t, r.SetCreatureKey(bat, "bat"))
+
+	// An abandoned key is free.
+	assert.NoError(t, r.SetCreatureKey(bat, "vampire"))
+	assert.NoError(t, r.SetCreatureKey(again, "bat"))
+	o, ok = r.Creature("bat")
+	assert.True(t, ok)
+	assert.Equal(t, again, o)
+}
+
+func TestReindex(t *testing.T) {
+	r := CreateFixtureRegion()
+	slime := r.AllocateCreature()
+	slime.Name = "slime"
+	// Keys assigned directly are not found until the region is reindexed.
+	_, ok := r.Creature("slime")
+	assert.False(t, ok)
+	assert.NoError(t, r.Reindex())
+	o, ok := r.Creature("slime")
+	assert.True(t, ok)
+	assert.Equal(t, slime, o)
+
+	r.AllocateCreature().Name = "slime"
+	assert.Equal(t, runtime.ErrDuplicateKey, r.Reindex())
+}
+
+func TestKeyLookupAfterUnmarshal(t *testing.T) {
+	r := CreateFixtureRegion()
+	r.AllocateCreature().Name = "slime"
+	r.AllocateCreature().Name = "bat"
+	data, err := r.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := CreateFixtureRegion()
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	o, ok := decoded.Creature("bat")
+	assert.True(t, ok)
+	assert.Equal(t, decoded.CreaturePool[1], o)
+	_, ok = decoded.Creature("wolf")
+	assert.False(t, ok)
+}
+
+func TestUnmarshalDuplicateKeys(t *testing.T) {
+	r := CreateFixtureRegion()
+	r.AllocateCreature().Name = "bat"
+	r.AllocateCreature().Name = "bat"
+	data, err := r.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := CreateFixtureRegion()
+	assert.Equal(t, runtime.ErrDuplicateKey, decoded.UnmarshalBinary(data))
+}
@@ -0,0 +1,355 @@
+// Package migrate rewrites data files after their schema changes.
+//
+// A migration is a data file holding a Migration struct.  It names structs and
+// fields as they are in the old schema, which is used to find the type of each
+// struct in the data.  Files are rewritten at the level of the syntax tree, so
+// comments are kept.
+package migrate
+
+import (
+	"fmt"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"math/big"
+)
+
+//go:generate rommyc spec.rommy --go_out .
+
+type addition struct {
+	name  string
+	value string
+}
+
+type replacement struct {
+	from human.Expr
+	to   string
+}
+
+// Migrator applies a migration to data written in a region.
+type Migrator struct {
+	region   *runtime.RegionSchema
+	structs  map[*runtime.StructSchema]string
+	fields   map[*runtime.FieldSchema]string
+	removed  map[*runtime.FieldSchema]bool
+	added    map[*runtime.StructSchema][]*addition
+	replaced map[*runtime.FieldSchema][]*replacement
+}
+
+// Parse a literal from the migration.  The text is not a source file, so
+// rather than reporting errors, the first message is returned.
+func parseLiteral(text string) (human.Expr, string) {
+	sources := parser.CreateSourceSet()
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(sources, sink)
+	data := []byte(text)
+	info := sources.Add("literal", data)
+	node := human.ParseData(info, data, status)
+	if status.ShouldStop() {
+		if len(sink.Diagnostics) == 0 {
+			return nil, "not a valid literal"
+		}
+		return nil, sink.Diagnostics[0].Message
+	}
+	return node, ""
+}
+
+// A description of a number that is the same however the number is written,
+// such as 10, 0xA, 1_0, or 10.0.
+func numberKey(text string) string {
+	f, _, err := big.ParseFloat(text, 0, 128, big.ToNearestEven)
+	if err != nil {
+		// Such as nan, which is only equal to the same text.
+		return "number " + text
+	}
+	return "number " + f.Text('g', -1)
+}
+
+// A description of a scalar literal that is the same however the literal is
+// written, or false if the expression is not a scalar literal.
+func literalKey(node human.Expr) (string, bool) {
+	switch node := node.(type) {
+	case *human.String:
+		return "string " + node.Value, true
+	case *human.Bytes:
+		return "bytes " + string(node.Value), true
+	case *human.Integer:
+		return numberKey(node.Raw.Text), true
+	case *human.Float:
+		return numberKey(node.Raw.Text), true
+	case *human.Boolean:
+		return fmt.Sprintf("bool %v", node.Value), true
+	case *human.Symbol:
+		return "symbol " + node.Raw.Text, true
+	case *human.Null:
+		return "null", true
+	default:
+		return "", false
+	}
+}
+
+type builder struct {
+	m         *Migrator
+	locations *human.Locations
+	status    *parser.Status
+}
+
+func (b *builder) lookupStruct(o interface{}, name string) (*runtime.StructSchema, bool) {
+	s, ok := b.m.region.StructLUT[name]
+	if !ok {
+		b.status.Error(b.locations.Field(o, "struct"), fmt.Sprintf("region %s does not have a struct named %#v", b.m.region.Name, name))
+	}
+	return s, ok
+}
+
+func (b *builder) lookupField(o interface{}, struct_name string, attr string, name string) (*runtime.FieldSchema, bool) {
+	s, ok := b.lookupStruct(o, struct_name)
+	if !ok {
+		return nil, false
+	}
+	f, ok := s.FieldLUT[name]
+	if !ok {
+		b.status.Error(b.locations.Field(o, attr), fmt.Sprintf("%s does not have a field named %#v", s.Name, name))
+	}
+	return f, ok
+}
+
+func (b *builder) checkLiteral(o interface{}, attr string, text string) (human.Expr, bool) {
+	node, problem := parseLiteral(text)
+	if problem != "" {
+		b.status.Error(b.locations.Field(o, attr), fmt.Sprintf("invalid literal %s, %s", text, problem))
+		return nil, false
+	}
+	return node, true
+}
+
+// Check a migration against the old schema of the data.  Problems are reported
+// at the locations in the migration, if known.
+func Prepare(migration *Migration, locations *human.Locations, region *runtime.RegionSchema, status *parser.Status) (*Migrator, bool) {
+	m := &Migrator{
+		region:   region,
+		structs:  map[*runtime.StructSchema]string{},
+		fields:   map[*runtime.FieldSchema]string{},
+		removed:  map[*runtime.FieldSchema]bool{},
+		added:    map[*runtime.StructSchema][]*addition{},
+		replaced: map[*runtime.FieldSchema][]*replacement{},
+	}
+	b := &builder{m: m, locations: locations, status: status}
+	all_ok := true
+	for _, r := range migration.RenameStruct {
+		s, ok := region.StructLUT[r.From]
+		if !ok {
+			status.Error(locations.Field(r, "from"), fmt.Sprintf("region %s does not have a struct named %#v", region.Name, r.From))
+			all_ok = false
+			continue
+		}
+		if _, ok := m.structs[s]; ok {
+			status.Error(locations.Field(r, "from"), fmt.Sprintf("%s is already renamed", s.Name))
+			all_ok = false
+			continue
+		}
+		m.structs[s] = r.To
+	}
+	for _, r := range migration.RenameField {
+		f, ok := b.lookupField(r, r.Struct, "from", r.From)
+		if !ok {
+			all_ok = false
+			continue
+		}
+		if _, ok := m.fields[f]; ok {
+			status.Error(locations.Field(r, "from"), fmt.Sprintf("%s.%s is already renamed", r.Struct, f.Name))
+			all_ok = false
+			continue
+		}
+		m.fields[f] = r.To
+	}
+	for _, r := range migration.RemoveField {
+		f, ok := b.lookupField(r, r.Struct, "field", r.Field)
+		if !ok {
+			all_ok = false
+			continue
+		}
+		m.removed[f] = true
+	}
+	for _, a := range migration.AddField {
+		s, ok := b.lookupStruct(a, a.Struct)
+		if !ok {
+			all_ok = false
+			continue
+		}
+		if _, ok := b.checkLiteral(a, "value", a.Value); !ok {
+			all_ok = false
+			continue
+		}
+		m.added[s] = append(m.added[s], &addition{name: a.Field, value: a.Value})
+	}
+	for _, r := range migration.ReplaceValue {
+		f, ok := b.lookupField(r, r.Struct, "field", r.Field)
+		if !ok {
+			all_ok = false
+			continue
+		}
+		from, ok := b.checkLiteral(r, "from", r.From)
+		if !ok {
+			all_ok = false
+			continue
+		}
+		if _, ok := literalKey(from); !ok {
+			status.Error(locations.Field(r, "from"), fmt.Sprintf("cannot replace %s, expected a scalar literal", r.From))
+			all_ok = false
+			continue
+		}
+		if _, ok := b.checkLiteral(r, "to", r.To); !ok {
+			all_ok = false
+			continue
+		}
+		m.replaced[f] = append(m.replaced[f], &replacement{from: from, to: r.To})
+	}
+	if !all_ok {
+		return nil, false
+	}
+	return m, true
+}
+
+// Load a migration file and check it against the old schema of the data.
+func LoadMigration(file string, data []byte, region *runtime.RegionSchema, status *parser.Status) (*Migrator, bool) {
+	generic_result, locations, ok := human.ParseProjectWithLocations(file, data, CreateSpecRegion(), status)
+	if !ok {
+		return nil, false
+	}
+	result, ok := generic_result.(*Migration)
+	if !ok {
+		status.Error(locations.Struct(generic_result), "expected a Migration struct")
+		return nil, false
+	}
+	return Prepare(result, locations, region, status)
+}
+
+type rewriter struct {
+	m     *Migrator
+	edits int
+}
+
+// Parse a literal that was checked by Prepare.
+func (r *rewriter) literal(text string) human.Expr {
+	node, problem := parseLiteral(text)
+	if problem != "" {
+		panic(problem)
+	}
+	return node
+}
+
+// Replace matching literals in a field's value, including the elements of
+// lists and the values of maps.
+func (r *rewriter) replace(node human.Expr, replacements []*replacement) human.Expr {
+	switch node := node.(type) {
+	case *human.List:
+		for i, arg := range node.Args {
+			node.Args[i] = r.replace(arg, replacements)
+		}
+		return node
+	case *human.Map:
+		for _, entry := range node.Entries {
+			entry.Value = r.replace(entry.Value, replacements)
+		}
+		return node
+	}
+	key, ok := literalKey(node)
+	if !ok {
+		return node
+	}
+	for _, rep := range replacements {
+		if from, _ := literalKey(rep.from); from == key {
+			replaced := r.literal(rep.to)
+			*replaced.Attached() = *node.Attached()
+			r.edits += 1
+			return replaced
+		}
+	}
+	return node
+}
+
+func (r *rewriter) rewriteStruct(node *human.Struct, expected runtime.TypeSchema) {
+	var t *runtime.StructSchema
+	if node.Type != nil {
+		t = r.m.region.StructLUT[node.Type.Raw.Text]
+	} else {
+		t, _ = expected.(*runtime.StructSchema)
+	}
+	if t == nil {
+		// The type is unknown, so the data is invalid and left alone.
+		return
+	}
+	args := []*human.KeywordArg{}
+	present := map[string]bool{}
+	for _, arg := range node.Args {
+		f, ok := t.FieldLUT[arg.Name.Text]
+		if ok {
+			if r.m.removed[f] {
+				r.edits += 1
+				continue
+			}
+			r.rewrite(arg.Value, f.Type)
+			if replacements, ok := r.m.replaced[f]; ok {
+				arg.Value = r.replace(arg.Value, replacements)
+			}
+			if name, ok := r.m.fields[f]; ok {
+				arg.Name.Text = name
+				r.edits += 1
+			}
+		}
+		present[arg.Name.Text] = true
+		args = append(args, arg)
+	}
+	for _, a := range r.m.added[t] {
+		if !present[a.name] {
+			args = append(args, &human.KeywordArg{
+				Name:  parser.SourceString{Text: a.name},
+				Value: r.literal(a.value),
+			})
+			r.edits += 1
+		}
+	}
+	node.Args = args
+	if name, ok := r.m.structs[t]; ok && node.Type != nil {
+		node.Type.Raw.Text = name
+		r.edits += 1
+	}
+}
+
+func (r *rewriter) rewrite(node human.Expr, expected runtime.TypeSchema) {
+	if o, ok := expected.(*runtime.OptionalSchema); ok {
+		expected = o.Element
+	}
+	switch node := node.(type) {
+	case *human.Struct:
+		r.rewriteStruct(node, expected)
+	case *human.List:
+		var element runtime.TypeSchema
+		switch t := expected.(type) {
+		case *runtime.ListSchema:
+			element = t.Element
+		case *runtime.ArraySchema:
+			element = t.Element
+		}
+		for _, arg := range node.Args {
+			r.rewrite(arg, element)
+		}
+	case *human.Map:
+		var value runtime.TypeSchema
+		if t, ok := expected.(*runtime.MapSchema); ok {
+			value = t.Value
+		}
+		for _, entry := range node.Entries {
+			r.rewrite(entry.Value, value)
+		}
+	}
+}
+
+// Apply the migration to a document, returning the number of edits made.
+// Structs whose type cannot be determined are left alone.
+func (m *Migrator) Rewrite(doc *human.Document) int {
+	r := &rewriter{m: m}
+	r.rewrite(doc.Root, nil)
+	return r.edits
+}
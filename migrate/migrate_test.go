@@ -0,0 +1,162 @@
+package migrate
+
+import (
+	"bytes"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"github.com/ncbray/rommy/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const gameSchema = `region Game {
+  enum Kind { sword, axe, potion }
+
+  struct Item {
+    name: string;
+    kind: Kind;
+    hp: int32;
+    tags: []string;
+    legacy: bool;
+  }
+
+  struct Shop {
+    owner: string;
+    items: []Item;
+    featured: ?Item;
+  }
+}
+`
+
+func loadGame(t *testing.T) *runtime.RegionSchema {
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+	regions, ok := schema.LoadSchema("game.rschema", []byte(gameSchema), status)
+	assert.True(t, ok)
+	return regions[0]
+}
+
+func loadMigration(t *testing.T, text string) (*Migrator, []string) {
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+	m, _ := LoadMigration("migration.rommy", []byte(text), loadGame(t), status)
+	return m, sink.Messages()
+}
+
+func migrate(t *testing.T, m *Migrator, text string) (string, int) {
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+	data := []byte(text)
+	info := status.Sources.Add("data.rommy", data)
+	doc := human.ParseDocument(info, data, status)
+	assert.False(t, status.ShouldStop())
+	edits := m.Rewrite(doc)
+	var out bytes.Buffer
+	human.WriteDocument(doc, &out)
+	return out.String(), edits
+}
+
+func TestMigrate(t *testing.T) {
+	m, messages := loadMigration(t, `Migration {
+  rename_struct: [{from: "Shop", to: "Store"}],
+  rename_field: [{struct: "Item", from: "hp", to: "max_health"}],
+  add_field: [{struct: "Item", field: "weight", value: "1.5"}],
+  remove_field: [{struct: "Item", field: "legacy"}],
+  replace_value: [
+    {struct: "Item", field: "kind", from: "axe", to: "sword"},
+    {struct: "Item", field: "tags", from: "\"old\"", to: "\"vintage\""},
+  ],
+}`)
+	assert.Equal(t, []string{}, messages)
+
+	text, edits := migrate(t, m, `// The only shop.
+Shop {
+  owner: "Bob",
+  items: [
+    {
+      name: "Axe",
+      kind: axe, // Sharp.
+      hp: 10,
+      tags: ["old"],
+      legacy: true,
+    },
+    {name: "Tonic", kind: potion, weight: 0.5},
+  ],
+  featured: Item {name: "Hammer", kind: axe},
+}
+`)
+	assert.Equal(t, `// The only shop.
+Store {
+  owner: "Bob",
+  items: [
+    {
+      name: "Axe",
+      kind: sword, // Sharp.
+      max_health: 10,
+      tags: ["vintage"],
+      weight: 1.5,
+    },
+    {name: "Tonic", kind: potion, weight: 0.5},
+  ],
+  featured: Item {name: "Hammer", kind: sword, weight: 1.5},
+}
+`, text)
+	assert.Equal(t, 8, edits)
+
+	text, edits = migrate(t, m, `Shop {owner: "Eve"}`)
+	assert.Equal(t, "Store {owner: \"Eve\"}\n", text)
+	assert.Equal(t, 1, edits)
+
+	// Untyped structs cannot be migrated.
+	_, edits = migrate(t, m, `[{name: "Axe", kind: axe}]`)
+	assert.Equal(t, 0, edits)
+}
+
+func TestReplaceNumbers(t *testing.T) {
+	m, messages := loadMigration(t, `Migration {
+  replace_value: [{struct: "Item", field: "hp", from: "10", to: "12"}],
+}`)
+	assert.Equal(t, []string{}, messages)
+	text, edits := migrate(t, m, `Shop {
+  items: [
+    {hp: 0xA},
+    {hp: 1_0},
+    {hp: +10},
+    {hp: 10.0},
+    {hp: 1e1},
+    {hp: 11},
+  ],
+}
+`)
+	assert.Equal(t, `Shop {
+  items: [
+    {hp: 12},
+    {hp: 12},
+    {hp: 12},
+    {hp: 12},
+    {hp: 12},
+    {hp: 11},
+  ],
+}
+`, text)
+	assert.Equal(t, 5, edits)
+}
+
+func TestMigrationErrors(t *testing.T) {
+	_, messages := loadMigration(t, `Migration {
+  rename_struct: [{from: "Inn", to: "Hotel"}],
+  rename_field: [{struct: "Item", from: "mp", to: "mana"}],
+  add_field: [{struct: "Item", field: "weight", value: "1.5.2"}],
+  remove_field: [{struct: "Cart", field: "wheels"}],
+  replace_value: [{struct: "Item", field: "tags", from: "[]", to: "x"}],
+}`)
+	assert.Equal(t, []string{
+		"region Game does not have a struct named \"Inn\"",
+		"Item does not have a field named \"mp\"",
+		"region Game does not have a struct named \"Cart\"",
+		"invalid literal 1.5.2, expected end of input",
+		"cannot replace [], expected a scalar literal",
+	}, messages)
+
+	_, messages = loadMigration(t, `RenameStruct {from: "Shop", to: "Store"}`)
+	assert.Equal(t, []string{"expected a Migration struct"}, messages)
+}
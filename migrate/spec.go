@@ -0,0 +1,642 @@
+package migrate
+
+/* Generated with rommyc, do not edit by hand. */
+
+import (
+	"github.com/ncbray/rommy/runtime"
+)
+
+type RenameStruct struct {
+	PoolIndex int
+	From      string
+	To        string
+}
+
+func (s *RenameStruct) Schema() *runtime.StructSchema {
+	return renameStructSchema
+}
+
+var renameStructSchema = &runtime.StructSchema{Name: "RenameStruct", GoType: (*RenameStruct)(nil)}
+
+type RenameField struct {
+	PoolIndex int
+	Struct    string
+	From      string
+	To        string
+}
+
+func (s *RenameField) Schema() *runtime.StructSchema {
+	return renameFieldSchema
+}
+
+var renameFieldSchema = &runtime.StructSchema{Name: "RenameField", GoType: (*RenameField)(nil)}
+
+type AddField struct {
+	PoolIndex int
+	Struct    string
+	Field     string
+	Value     string
+}
+
+func (s *AddField) Schema() *runtime.StructSchema {
+	return addFieldSchema
+}
+
+var addFieldSchema = &runtime.StructSchema{Name: "AddField", GoType: (*AddField)(nil)}
+
+type RemoveField struct {
+	PoolIndex int
+	Struct    string
+	Field     string
+}
+
+func (s *RemoveField) Schema() *runtime.StructSchema {
+	return removeFieldSchema
+}
+
+var removeFieldSchema = &runtime.StructSchema{Name: "RemoveField", GoType: (*RemoveField)(nil)}
+
+type ReplaceValue struct {
+	PoolIndex int
+	Struct    string
+	Field     string
+	From      string
+	To        string
+}
+
+func (s *ReplaceValue) Schema() *runtime.StructSchema {
+	return replaceValueSchema
+}
+
+var replaceValueSchema = &runtime.StructSchema{Name: "ReplaceValue", GoType: (*ReplaceValue)(nil)}
+
+type Migration struct {
+	PoolIndex    int
+	RenameStruct []*RenameStruct
+	RenameField  []*RenameField
+	AddField     []*AddField
+	RemoveField  []*RemoveField
+	ReplaceValue []*ReplaceValue
+}
+
+func (s *Migration) Schema() *runtime.StructSchema {
+	return migrationSchema
+}
+
+var migrationSchema = &runtime.StructSchema{Name: "Migration", GoType: (*Migration)(nil)}
+
+type SpecRegion struct {
+	RenameStructPool []*RenameStruct
+	RenameFieldPool  []*RenameField
+	AddFieldPool     []*AddField
+	RemoveFieldPool  []*RemoveField
+	ReplaceValuePool []*ReplaceValue
+	MigrationPool    []*Migration
+}
+
+func CreateSpecRegion() *SpecRegion {
+	return &SpecRegion{}
+}
+
+var specRegionSchema = &runtime.RegionSchema{Name: "Spec", GoType: (*SpecRegion)(nil)}
+
+func (r *SpecRegion) Schema() *runtime.RegionSchema {
+	return specRegionSchema
+}
+
+func (r *SpecRegion) AllocateRenameStruct() *RenameStruct {
+	o := &RenameStruct{}
+	o.PoolIndex = len(r.RenameStructPool)
+	r.RenameStructPool = append(r.RenameStructPool, o)
+	return o
+}
+
+func (r *SpecRegion) AllocateRenameField() *RenameField {
+	o := &RenameField{}
+	o.PoolIndex = len(r.RenameFieldPool)
+	r.RenameFieldPool = append(r.RenameFieldPool, o)
+	return o
+}
+
+func (r *SpecRegion) AllocateAddField() *AddField {
+	o := &AddField{}
+	o.PoolIndex = len(r.AddFieldPool)
+	r.AddFieldPool = append(r.AddFieldPool, o)
+	return o
+}
+
+func (r *SpecRegion) AllocateRemoveField() *RemoveField {
+	o := &RemoveField{}
+	o.PoolIndex = len(r.RemoveFieldPool)
+	r.RemoveFieldPool = append(r.RemoveFieldPool, o)
+	return o
+}
+
+func (r *SpecRegion) AllocateReplaceValue() *ReplaceValue {
+	o := &ReplaceValue{}
+	o.PoolIndex = len(r.ReplaceValuePool)
+	r.ReplaceValuePool = append(r.ReplaceValuePool, o)
+	return o
+}
+
+func (r *SpecRegion) AllocateMigration() *Migration {
+	o := &Migration{}
+	o.PoolIndex = len(r.MigrationPool)
+	r.MigrationPool = append(r.MigrationPool, o)
+	return o
+}
+
+func (r *SpecRegion) Allocate(name string) interface{} {
+	switch name {
+	case "RenameStruct":
+		return r.AllocateRenameStruct()
+	case "RenameField":
+		return r.AllocateRenameField()
+	case "AddField":
+		return r.AllocateAddField()
+	case "RemoveField":
+		return r.AllocateRemoveField()
+	case "ReplaceValue":
+		return r.AllocateReplaceValue()
+	case "Migration":
+		return r.AllocateMigration()
+	}
+	return nil
+}
+
+func (r *SpecRegion) MarshalBinary() ([]byte, error) {
+	s := runtime.MakeSerializer()
+	var err error
+	runtime.WriteHeader(s, r.Schema())
+	err = s.WriteCount(len(r.RenameStructPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.RenameFieldPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.AddFieldPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.RemoveFieldPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.ReplaceValuePool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.MigrationPool))
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range r.RenameStructPool {
+		s.WriteString(o.From)
+		s.WriteString(o.To)
+	}
+	for _, o := range r.RenameFieldPool {
+		s.WriteString(o.Struct)
+		s.WriteString(o.From)
+		s.WriteString(o.To)
+	}
+	for _, o := range r.AddFieldPool {
+		s.WriteString(o.Struct)
+		s.WriteString(o.Field)
+		s.WriteString(o.Value)
+	}
+	for _, o := range r.RemoveFieldPool {
+		s.WriteString(o.Struct)
+		s.WriteString(o.Field)
+	}
+	for _, o := range r.ReplaceValuePool {
+		s.WriteString(o.Struct)
+		s.WriteString(o.Field)
+		s.WriteString(o.From)
+		s.WriteString(o.To)
+	}
+	for _, o := range r.MigrationPool {
+		err = s.WriteCount(len(o.RenameStruct))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.RenameStruct {
+			if o0 == nil {
+				return nil, runtime.NilReference("Migration.rename_struct")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.RenameStructPool))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = s.WriteCount(len(o.RenameField))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.RenameField {
+			if o0 == nil {
+				return nil, runtime.NilReference("Migration.rename_field")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.RenameFieldPool))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = s.WriteCount(len(o.AddField))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.AddField {
+			if o0 == nil {
+				return nil, runtime.NilReference("Migration.add_field")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.AddFieldPool))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = s.WriteCount(len(o.RemoveField))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.RemoveField {
+			if o0 == nil {
+				return nil, runtime.NilReference("Migration.remove_field")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.RemoveFieldPool))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = s.WriteCount(len(o.ReplaceValue))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.ReplaceValue {
+			if o0 == nil {
+				return nil, runtime.NilReference("Migration.replace_value")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.ReplaceValuePool))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.Data(), nil
+}
+
+func (r *SpecRegion) UnmarshalBinary(data []byte) error {
+	d := runtime.MakeDeserializer(data)
+	var index int
+	var err error
+	err = runtime.CheckHeader(d, r.Schema())
+	if err != nil {
+		return err
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateRenameStruct()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateRenameField()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateAddField()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateRemoveField()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateReplaceValue()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateMigration()
+	}
+	for _, o := range r.RenameStructPool {
+		o.From, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.To, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.RenameFieldPool {
+		o.Struct, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.From, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.To, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.AddFieldPool {
+		o.Struct, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.Field, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.Value, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.RemoveFieldPool {
+		o.Struct, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.Field, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.ReplaceValuePool {
+		o.Struct, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.Field, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.From, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.To, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.MigrationPool {
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.RenameStruct = make([]*RenameStruct, index)
+		for i0, _ := range o.RenameStruct {
+			index, err = d.ReadIndex(len(r.RenameStructPool))
+			if err != nil {
+				return err
+			}
+			o.RenameStruct[i0] = r.RenameStructPool[index]
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.RenameField = make([]*RenameField, index)
+		for i0, _ := range o.RenameField {
+			index, err = d.ReadIndex(len(r.RenameFieldPool))
+			if err != nil {
+				return err
+			}
+			o.RenameField[i0] = r.RenameFieldPool[index]
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.AddField = make([]*AddField, index)
+		for i0, _ := range o.AddField {
+			index, err = d.ReadIndex(len(r.AddFieldPool))
+			if err != nil {
+				return err
+			}
+			o.AddField[i0] = r.AddFieldPool[index]
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.RemoveField = make([]*RemoveField, index)
+		for i0, _ := range o.RemoveField {
+			index, err = d.ReadIndex(len(r.RemoveFieldPool))
+			if err != nil {
+				return err
+			}
+			o.RemoveField[i0] = r.RemoveFieldPool[index]
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.ReplaceValue = make([]*ReplaceValue, index)
+		for i0, _ := range o.ReplaceValue {
+			index, err = d.ReadIndex(len(r.ReplaceValuePool))
+			if err != nil {
+				return err
+			}
+			o.ReplaceValue[i0] = r.ReplaceValuePool[index]
+		}
+	}
+	return nil
+}
+
+type SpecCloner struct {
+	src             *SpecRegion
+	dst             *SpecRegion
+	renameStructMap []*RenameStruct
+	renameFieldMap  []*RenameField
+	addFieldMap     []*AddField
+	removeFieldMap  []*RemoveField
+	replaceValueMap []*ReplaceValue
+	migrationMap    []*Migration
+}
+
+func CreateSpecCloner(src *SpecRegion, dst *SpecRegion) *SpecCloner {
+	c := &SpecCloner{
+		src:             src,
+		dst:             dst,
+		renameStructMap: make([]*RenameStruct, len(src.RenameStructPool)),
+		renameFieldMap:  make([]*RenameField, len(src.RenameFieldPool)),
+		addFieldMap:     make([]*AddField, len(src.AddFieldPool)),
+		removeFieldMap:  make([]*RemoveField, len(src.RemoveFieldPool)),
+		replaceValueMap: make([]*ReplaceValue, len(src.ReplaceValuePool)),
+		migrationMap:    make([]*Migration, len(src.MigrationPool)),
+	}
+	return c
+}
+
+func (c *SpecCloner) CloneRenameStruct(src *RenameStruct) *RenameStruct {
+	dst := c.renameStructMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateRenameStruct()
+	c.renameStructMap[src.PoolIndex] = dst
+	dst.From = src.From
+	dst.To = src.To
+	return dst
+}
+
+func (c *SpecCloner) CloneRenameField(src *RenameField) *RenameField {
+	dst := c.renameFieldMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateRenameField()
+	c.renameFieldMap[src.PoolIndex] = dst
+	dst.Struct = src.Struct
+	dst.From = src.From
+	dst.To = src.To
+	return dst
+}
+
+func (c *SpecCloner) CloneAddField(src *AddField) *AddField {
+	dst := c.addFieldMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateAddField()
+	c.addFieldMap[src.PoolIndex] = dst
+	dst.Struct = src.Struct
+	dst.Field = src.Field
+	dst.Value = src.Value
+	return dst
+}
+
+func (c *SpecCloner) CloneRemoveField(src *RemoveField) *RemoveField {
+	dst := c.removeFieldMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateRemoveField()
+	c.removeFieldMap[src.PoolIndex] = dst
+	dst.Struct = src.Struct
+	dst.Field = src.Field
+	return dst
+}
+
+func (c *SpecCloner) CloneReplaceValue(src *ReplaceValue) *ReplaceValue {
+	dst := c.replaceValueMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateReplaceValue()
+	c.replaceValueMap[src.PoolIndex] = dst
+	dst.Struct = src.Struct
+	dst.Field = src.Field
+	dst.From = src.From
+	dst.To = src.To
+	return dst
+}
+
+func (c *SpecCloner) CloneMigration(src *Migration) *Migration {
+	dst := c.migrationMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateMigration()
+	c.migrationMap[src.PoolIndex] = dst
+	dst.RenameStruct = make([]*RenameStruct, len(src.RenameStruct))
+	for i0, _ := range src.RenameStruct {
+		dst.RenameStruct[i0] = c.CloneRenameStruct(src.RenameStruct[i0])
+	}
+	dst.RenameField = make([]*RenameField, len(src.RenameField))
+	for i0, _ := range src.RenameField {
+		dst.RenameField[i0] = c.CloneRenameField(src.RenameField[i0])
+	}
+	dst.AddField = make([]*AddField, len(src.AddField))
+	for i0, _ := range src.AddField {
+		dst.AddField[i0] = c.CloneAddField(src.AddField[i0])
+	}
+	dst.RemoveField = make([]*RemoveField, len(src.RemoveField))
+	for i0, _ := range src.RemoveField {
+		dst.RemoveField[i0] = c.CloneRemoveField(src.RemoveField[i0])
+	}
+	dst.ReplaceValue = make([]*ReplaceValue, len(src.ReplaceValue))
+	for i0, _ := range src.ReplaceValue {
+		dst.ReplaceValue[i0] = c.CloneReplaceValue(src.ReplaceValue[i0])
+	}
+	return dst
+}
+
+// Check the region against the constraints in its schema.
+func (r *SpecRegion) Validate() error {
+	return runtime.Validate(r)
+}
+
+func init() {
+
+	renameStructSchema.Fields = []*runtime.FieldSchema{
+		{Name: "from", Type: &runtime.StringSchema{}},
+		{Name: "to", Type: &runtime.StringSchema{}},
+	}
+
+	renameFieldSchema.Fields = []*runtime.FieldSchema{
+		{Name: "struct", Type: &runtime.StringSchema{}},
+		{Name: "from", Type: &runtime.StringSchema{}},
+		{Name: "to", Type: &runtime.StringSchema{}},
+	}
+
+	addFieldSchema.Fields = []*runtime.FieldSchema{
+		{Name: "struct", Type: &runtime.StringSchema{}},
+		{Name: "field", Type: &runtime.StringSchema{}},
+		{Name: "value", Type: &runtime.StringSchema{}},
+	}
+
+	removeFieldSchema.Fields = []*runtime.FieldSchema{
+		{Name: "struct", Type: &runtime.StringSchema{}},
+		{Name: "field", Type: &runtime.StringSchema{}},
+	}
+
+	replaceValueSchema.Fields = []*runtime.FieldSchema{
+		{Name: "struct", Type: &runtime.StringSchema{}},
+		{Name: "field", Type: &runtime.StringSchema{}},
+		{Name: "from", Type: &runtime.StringSchema{}},
+		{Name: "to", Type: &runtime.StringSchema{}},
+	}
+
+	migrationSchema.Fields = []*runtime.FieldSchema{
+		{Name: "rename_struct", Type: (renameStructSchema).List()},
+		{Name: "rename_field", Type: (renameFieldSchema).List()},
+		{Name: "add_field", Type: (addFieldSchema).List()},
+		{Name: "remove_field", Type: (removeFieldSchema).List()},
+		{Name: "replace_value", Type: (replaceValueSchema).List()},
+	}
+
+	specRegionSchema.Structs = []*runtime.StructSchema{
+		renameStructSchema,
+		renameFieldSchema,
+		addFieldSchema,
+		removeFieldSchema,
+		replaceValueSchema,
+		migrationSchema,
+	}
+	specRegionSchema.Init()
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"github.com/ncbray/compilerutil/writer"
+	"strings"
+)
+
+// Write comments that are on the lines before a node.
+func WriteLeadingComments(comments []*Comment, out *writer.TabbedWriter) {
+	for _, c := range comments {
+		out.WriteLine(c.Raw.Text)
+	}
+}
+
+// Write the comments that trail a node and end the line.
+func EndLineWithComments(comments []*Comment, out *writer.TabbedWriter) {
+	lineStart := false
+	for _, c := range comments {
+		if c.Newline && !lineStart {
+			out.EndOfLine()
+			lineStart = true
+		}
+		if !lineStart {
+			out.WriteString(" ")
+		}
+		out.WriteString(c.Raw.Text)
+		lineStart = false
+		// A line comment runs to the end of the line.
+		if c.Newline || strings.HasPrefix(c.Raw.Text, "//") {
+			out.EndOfLine()
+			lineStart = true
+		}
+	}
+	if !lineStart {
+		out.EndOfLine()
+	}
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// Position in a source file.  Lines count from one, columns count runes from
+// zero.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Span is a range of text in a source file.
+type Span struct {
+	File  string   `json:"file"`
+	Begin Position `json:"begin"`
+	End   Position `json:"end"`
+	// The text of the line the span begins on, for rendering.
+	Text string `json:"-"`
+}
+
+// Related points at another location that helps explain a diagnostic, such as
+// a previous definition.
+type Related struct {
+	Loc     Location
+	Message string
+}
+
+type RelatedInformation struct {
+	// Nil if the location is unknown.
+	Span    *Span  `json:"span,omitempty"`
+	Message string `json:"message"`
+}
+
+// Fix is a suggested edit that resolves a diagnostic, replacing the text at a
+// location.
+type Fix struct {
+	Loc  Location
+	Text string
+}
+
+type TextEdit struct {
+	// Nil if the location is unknown.
+	Span    *Span  `json:"span,omitempty"`
+	NewText string `json:"new_text"`
+}
+
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Nil if the location is unknown.
+	Span    *Span                 `json:"span,omitempty"`
+	Message string                `json:"message"`
+	Related []*RelatedInformation `json:"related,omitempty"`
+	Fixes   []*TextEdit           `json:"fixes,omitempty"`
+}
+
+// Sink receives diagnostics as they are reported.
+type Sink interface {
+	Report(d *Diagnostic)
+}
+
+// TextSink writes diagnostics for humans, with a caret under the location.
+type TextSink struct {
+	Out io.Writer
+}
+
+func (s *TextSink) write(severity Severity, span *Span, message string) {
+	label := strings.ToUpper(string(severity))
+	if span == nil {
+		fmt.Fprintf(s.Out, "%s %s\n", label, message)
+		return
+	}
+	text := span.Text
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	arrow := strings.Repeat(" ", span.Begin.Column) + "^"
+	fmt.Fprintf(s.Out, "%s:%d:%d - %s %s\n%s%s\n", span.File, span.Begin.Line, span.Begin.Column, label, message, text, arrow)
+}
+
+func (s *TextSink) Report(d *Diagnostic) {
+	s.write(d.Severity, d.Span, d.Message)
+	for _, r := range d.Related {
+		s.write(SeverityNote, r.Span, r.Message)
+	}
+	for _, f := range d.Fixes {
+		s.write(SeverityNote, f.Span, fmt.Sprintf("fix, replace with %#v", f.NewText))
+	}
+}
+
+// JSONSink writes each diagnostic as a JSON object on its own line.
+type JSONSink struct {
+	Out io.Writer
+}
+
+func (s *JSONSink) Report(d *Diagnostic) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		panic(err)
+	}
+	s.Out.Write(append(data, '\n'))
+}
+
+// MemorySink keeps diagnostics so they can be inspected.
+type MemorySink struct {
+	Diagnostics []*Diagnostic
+}
+
+func (s *MemorySink) Report(d *Diagnostic) {
+	s.Diagnostics = append(s.Diagnostics, d)
+}
+
+// The messages of the diagnostics, in the order they were reported.
+func (s *MemorySink) Messages() []string {
+	messages := make([]string, len(s.Diagnostics))
+	for i, d := range s.Diagnostics {
+		messages[i] = d.Message
+	}
+	return messages
+}
@@ -0,0 +1,269 @@
+package parser
+
+import (
+	"strings"
+)
+
+// Comment is a line or block comment, including its delimiters.
+type Comment struct {
+	Raw SourceString
+	// The comment starts on its own line.
+	Newline bool
+	// A blank line separates the comment from whatever follows it.
+	Detached bool
+}
+
+// Comments attached to a node.  Leading comments are on the lines before the
+// node, trailing comments follow the node on the same line.
+type Comments struct {
+	Leading  []*Comment
+	Trailing []*Comment
+}
+
+func (c *Comments) Attached() *Comments {
+	return c
+}
+
+// The text of a doc comment, a line comment starting with "///" or a block
+// comment starting with "/**".  The delimiters, the space after them, and the
+// "*" that starts each line of a block comment are removed.  Returns false for
+// other comments.
+func (c *Comment) Doc() (string, bool) {
+	text := c.Raw.Text
+	if strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////") {
+		return strings.TrimRight(strings.TrimPrefix(text[3:], " "), " \t\r"), true
+	}
+	if !strings.HasPrefix(text, "/**") || strings.HasPrefix(text, "/**/") || strings.HasPrefix(text, "/***") {
+		return "", false
+	}
+	lines := strings.Split(strings.TrimSuffix(text[3:], "*/"), "\n")
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " \t")
+		if i > 0 {
+			line = strings.TrimPrefix(line, "*")
+		}
+		lines[i] = strings.TrimRight(strings.TrimPrefix(line, " "), " \t\r")
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// Match a single rune.
+func Punc(state *RuneParserState, value rune) bool {
+	if state.Is(value) {
+		state.GetNext()
+		return true
+	} else {
+		return false
+	}
+}
+
+func Identifier(state *RuneParserState) (SourceString, bool) {
+	if state.IsLetter() || state.Is('_') {
+		begin := state.Position()
+		state.GetNext()
+		for state.IsLetter() || state.IsDigit() || state.Is('_') {
+			state.GetNext()
+		}
+		return state.Slice(begin), true
+	} else {
+		return SourceString{}, false
+	}
+}
+
+func comment(state *RuneParserState) (*Comment, bool) {
+	begin := state.Position()
+	if !Punc(state, '/') {
+		return nil, false
+	}
+	switch {
+	case Punc(state, '/'):
+		for !state.IsEndOfStream() && !state.Is('\n') {
+			state.GetNext()
+		}
+	case Punc(state, '*'):
+		for {
+			if state.IsEndOfStream() {
+				return nil, false
+			}
+			current := state.Peek()
+			state.GetNext()
+			if current == '*' && Punc(state, '/') {
+				break
+			}
+		}
+	default:
+		return nil, false
+	}
+	return &Comment{Raw: state.Slice(begin)}, true
+}
+
+// Skip whitespace and collect any comments.
+func SkipComments(state *RuneParserState) []*Comment {
+	var result []*Comment
+	for {
+		lines := 0
+		for state.IsSpace() {
+			if state.Is('\n') {
+				lines += 1
+			}
+			state.GetNext()
+		}
+		if lines > 1 && len(result) > 0 {
+			result[len(result)-1].Detached = true
+		}
+		newline := lines > 0
+		begin := state.Position()
+		c, ok := comment(state)
+		if !ok {
+			state.Recover(begin)
+			return result
+		}
+		c.Newline = newline
+		result = append(result, c)
+	}
+}
+
+// Collect the comments on the remainder of the current line.  Comments followed
+// by more of the line, other than a separator or closing bracket, lead what
+// follows them and are left for it.
+func TrailingComments(state *RuneParserState) []*Comment {
+	start := state.Position()
+	var result []*Comment
+	for {
+		for state.IsSpace() && !state.Is('\n') {
+			state.GetNext()
+		}
+		begin := state.Position()
+		c, ok := comment(state)
+		if !ok {
+			state.Recover(begin)
+			break
+		}
+		result = append(result, c)
+	}
+	if len(result) > 0 && !state.Is('\n') && !state.Is(',') && !isClosing(state) {
+		state.Seek(start)
+		return nil
+	}
+	return result
+}
+
+// Parse a comma separated sequence, attaching the surrounding comments to each
+// element.  Returns the comments following the last element.
+func CommaSeparated(state *RuneParserState, element func(state *RuneParserState) (*Comments, bool)) []*Comment {
+	for {
+		begin := state.Position()
+		leading := SkipComments(state)
+		c, ok := element(state)
+		if !ok {
+			state.Recover(begin)
+			break
+		}
+		c.Leading = append(leading, c.Leading...)
+		c.Trailing = TrailingComments(state)
+		separator := state.Position()
+		between := SkipComments(state)
+		if !Punc(state, ',') {
+			state.Recover(separator)
+			break
+		}
+		c.Trailing = append(c.Trailing, between...)
+		c.Trailing = append(c.Trailing, TrailingComments(state)...)
+	}
+	return SkipComments(state)
+}
+
+func isClosing(state *RuneParserState) bool {
+	return state.Is('}') || state.Is(']') || state.Is(')') || state.IsEndOfStream()
+}
+
+// Skip to the next ',' or closing bracket that is not nested inside brackets, a
+// string, or a comment.  Returns the skipped text.
+func SkipToSeparator(state *RuneParserState) SourceString {
+	begin := state.Position()
+	depth := 0
+	for !state.IsEndOfStream() {
+		switch {
+		case depth == 0 && (state.Is(',') || isClosing(state)):
+			return state.Slice(begin)
+		case state.Is('{') || state.Is('[') || state.Is('('):
+			depth += 1
+		case state.Is('}') || state.Is(']') || state.Is(')'):
+			depth -= 1
+		case state.Is('"'):
+			state.GetNext()
+			for !state.IsEndOfStream() && !state.Is('"') && !state.Is('\n') {
+				if Punc(state, '\\') && state.IsEndOfStream() {
+					break
+				}
+				state.GetNext()
+			}
+			if !state.Is('"') {
+				continue
+			}
+		case state.Is('/'):
+			pos := state.Position()
+			if _, ok := comment(state); ok {
+				continue
+			}
+			state.Seek(pos)
+		}
+		state.GetNext()
+	}
+	return state.Slice(begin)
+}
+
+// Like CommaSeparated, but recovers from elements that fail to parse.  The
+// failure is recorded, the input is skipped up to the next ',' or closing
+// bracket, and bad is called with the skipped text.  A missing ',' between
+// elements is recorded and parsing continues with the next element.
+func RecoveringCommaSeparated(state *RuneParserState, element func(state *RuneParserState) (*Comments, bool), bad func(skipped SourceString)) []*Comment {
+	missing := false
+	for {
+		begin := state.Position()
+		leading := SkipComments(state)
+		if isClosing(state) {
+			state.Recover(begin)
+			break
+		}
+		elementBegin := state.Position()
+		c, ok := element(state)
+		if !ok {
+			if missing && (len(state.expected) == 0 || state.expectedPos <= elementBegin) {
+				// Already reported as a missing ','.
+				state.Forget()
+			} else {
+				state.Fail()
+			}
+			state.Seek(elementBegin)
+			bad(SkipToSeparator(state))
+			if !Punc(state, ',') {
+				break
+			}
+			missing = false
+			continue
+		}
+		c.Leading = append(leading, c.Leading...)
+		c.Trailing = TrailingComments(state)
+		separator := state.Position()
+		between := SkipComments(state)
+		missing = !Punc(state, ',')
+		if missing {
+			if isClosing(state) {
+				state.Recover(separator)
+				break
+			}
+			state.Expected("','")
+			state.Fail()
+		}
+		c.Trailing = append(c.Trailing, between...)
+		c.Trailing = append(c.Trailing, TrailingComments(state)...)
+	}
+	return SkipComments(state)
+}
@@ -2,7 +2,7 @@
 package parser
 
 import (
-	"fmt"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -34,7 +34,9 @@ func (s *SourceInfo) Location(begin RuneStreamPos, end RuneStreamPos) Location {
 	return Location{file: s.File, begin: begin, end: end}
 }
 
-func (s *SourceInfo) GetLineInfo(loc Location) (string, int, int, string) {
+// The line, column, and text of the line containing pos.  Lines count from one,
+// columns count runes from zero.
+func (s *SourceInfo) position(pos RuneStreamPos) (int, int, string) {
 	// Lazy create
 	if s.lineInfo == nil {
 		info := []RuneStreamPos{}
@@ -54,22 +56,34 @@ func (s *SourceInfo) GetLineInfo(loc Location) (string, int, int, string) {
 		info = append(info, RuneStreamPos(len(s.data)))
 		s.lineInfo = info
 	}
+	if len(s.data) == 0 && pos == 0 {
+		// An empty file still has an empty first line.
+		return 1, 0, ""
+	}
 	// HACK linear scan
 	for line, offset := range s.lineInfo {
-		if loc.begin < offset {
+		if pos < offset || line > 0 && pos == offset && offset == RuneStreamPos(len(s.data)) {
 			start := s.lineInfo[line-1]
 			col_index := 0
-			for i := start; i < loc.begin; {
+			for i := start; i < pos; {
 				_, size := utf8.DecodeRune(s.data[i:])
 				i += RuneStreamPos(size)
 				col_index += 1
 			}
 			bytes := s.data[start:offset]
-			return loc.file, line, col_index, string(bytes)
+			return line, col_index, string(bytes)
 		}
 	}
 
-	return "", 0, 0, ""
+	return 0, 0, ""
+}
+
+func (s *SourceInfo) GetLineInfo(loc Location) (string, int, int, string) {
+	line, col, text := s.position(loc.begin)
+	if line == 0 {
+		return "", 0, 0, ""
+	}
+	return loc.file, line, col, text
 }
 
 type SourceSet struct {
@@ -86,21 +100,84 @@ func CreateSourceSet() *SourceSet {
 	return &SourceSet{files: map[string]*SourceInfo{}}
 }
 
+// Status collects diagnostics and passes them to a Sink.  A Status without
+// Sources or a Sink counts errors without reporting them.
 type Status struct {
 	Sources *SourceSet
+	Sink    Sink
 	errors  int
 }
 
-func (s *Status) Error(loc Location, message string) {
-	info := s.Sources.files[loc.file]
-	file, line, col, text := info.GetLineInfo(loc)
-	arrow := ""
-	for i := 0; i < col; i++ {
-		arrow += " "
+func CreateStatus(sources *SourceSet, sink Sink) *Status {
+	return &Status{Sources: sources, Sink: sink}
+}
+
+// Resolve a location into a span, or nil if the source is unknown.
+func (s *Status) span(loc Location) *Span {
+	if s.Sources == nil {
+		return nil
+	}
+	info, ok := s.Sources.files[loc.file]
+	if !ok {
+		return nil
+	}
+	line, col, text := info.position(loc.begin)
+	if line == 0 {
+		return nil
 	}
-	arrow += "^"
-	fmt.Printf("%s:%d:%d - ERROR %s\n%s%s\n", file, line, col, message, text, arrow)
-	s.errors += 1
+	end_line, end_col, _ := info.position(loc.end)
+	if end_line == 0 {
+		end_line, end_col = line, col
+	}
+	return &Span{
+		File:  loc.file,
+		Begin: Position{Line: line, Column: col},
+		End:   Position{Line: end_line, Column: end_col},
+		Text:  text,
+	}
+}
+
+func (s *Status) report(severity Severity, loc Location, message string, related []Related, fixes []Fix) {
+	if severity == SeverityError {
+		s.errors += 1
+	}
+	if s.Sink == nil {
+		return
+	}
+	d := &Diagnostic{Severity: severity, Span: s.span(loc), Message: message}
+	for _, r := range related {
+		d.Related = append(d.Related, &RelatedInformation{Span: s.span(r.Loc), Message: r.Message})
+	}
+	for _, f := range fixes {
+		d.Fixes = append(d.Fixes, &TextEdit{Span: s.span(f.Loc), NewText: f.Text})
+	}
+	s.Sink.Report(d)
+}
+
+func (s *Status) Error(loc Location, message string, related ...Related) {
+	s.report(SeverityError, loc, message, related, nil)
+}
+
+// Report an error that the fix resolves.
+func (s *Status) ErrorWithFix(loc Location, message string, fix Fix, related ...Related) {
+	s.report(SeverityError, loc, message, related, []Fix{fix})
+}
+
+func (s *Status) Warning(loc Location, message string, related ...Related) {
+	s.report(SeverityWarning, loc, message, related, nil)
+}
+
+// Warn about a problem that the fix resolves.
+func (s *Status) WarningWithFix(loc Location, message string, fix Fix, related ...Related) {
+	s.report(SeverityWarning, loc, message, related, []Fix{fix})
+}
+
+func (s *Status) Note(loc Location, message string, related ...Related) {
+	s.report(SeverityNote, loc, message, related, nil)
+}
+
+func (s *Status) ErrorCount() int {
+	return s.errors
 }
 
 func (s *Status) ShouldStop() bool {
@@ -172,10 +249,20 @@ func (state *RuneStream) Slice(begin RuneStreamPos) SourceString {
 	}
 }
 
+// SyntaxError is a problem the parser found and recovered from.
+type SyntaxError struct {
+	Loc     Location
+	Message string
+}
+
 type RuneParserState struct {
 	RuneStream
 	deepest RuneStreamPos
 	ok      bool
+	// What was expected where parsing failed furthest into the input.
+	expectedPos RuneStreamPos
+	expected    []string
+	errors      []SyntaxError
 }
 
 func (p *RuneParserState) Recover(pos RuneStreamPos) {
@@ -192,11 +279,70 @@ func (p *RuneParserState) Deepest() Location {
 	return p.info.Location(p.deepest, p.deepest+1)
 }
 
+// Note that parsing failed at the current position because something else was
+// expected.  Only the failures furthest into the input are kept, since they
+// are usually the most specific.
+func (p *RuneParserState) Expected(what string) {
+	pos := p.currentPos
+	if len(p.expected) == 0 || pos > p.expectedPos {
+		p.expectedPos = pos
+		p.expected = []string{what}
+		return
+	}
+	if pos < p.expectedPos {
+		return
+	}
+	for _, e := range p.expected {
+		if e == what {
+			return
+		}
+	}
+	p.expected = append(p.expected, what)
+}
+
+// Describe the furthest failure.
+func (p *RuneParserState) Failure() (Location, string) {
+	if len(p.expected) == 0 {
+		return p.Deepest(), "unexpected character"
+	}
+	return p.info.Location(p.expectedPos, p.expectedPos+1), "expected " + strings.Join(p.expected, " or ")
+}
+
+// Forget the failures so far, such as when the parser has recovered.
+func (p *RuneParserState) Forget() {
+	p.expected = nil
+	p.deepest = p.currentPos
+}
+
+// Record the furthest failure as an error and forget it, so parsing can resume.
+func (p *RuneParserState) Fail() {
+	loc, message := p.Failure()
+	p.Forget()
+	e := SyntaxError{Loc: loc, Message: message}
+	for _, other := range p.errors {
+		if other == e {
+			return
+		}
+	}
+	p.errors = append(p.errors, e)
+}
+
+// Did the parser record any errors?
+func (p *RuneParserState) HasErrors() bool {
+	return len(p.errors) > 0
+}
+
+// Report the errors recorded by Fail.
+func (p *RuneParserState) ReportErrors(status *Status) {
+	for _, e := range p.errors {
+		status.Error(e.Loc, e.Message)
+	}
+}
+
 func CreateRuneParser(info *SourceInfo, input []byte) *RuneParserState {
 	s := &RuneParserState{
-		*CreateRuneStream(info, input),
-		0,
-		true,
+		RuneStream: *CreateRuneStream(info, input),
+		ok:         true,
 	}
 	s.Seek(0)
 	return s
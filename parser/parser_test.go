@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -42,3 +43,129 @@ func TestCharacterDecoding(t *testing.T) {
 	assert.False(t, state.IsSpace())
 	assert.True(t, state.IsEndOfStream())
 }
+
+func TestDiagnostics(t *testing.T) {
+	sources := CreateSourceSet()
+	data := []byte("first\nsecond line")
+	info := sources.Add("test", data)
+	sink := &MemorySink{}
+	status := CreateStatus(sources, sink)
+
+	status.Warning(info.Location(0, 5), "odd")
+	assert.False(t, status.ShouldStop())
+	status.Error(info.Location(13, 17), "bad", Related{Loc: info.Location(6, 12), Message: "because"})
+	status.Error(Location{}, "lost")
+	assert.True(t, status.ShouldStop())
+	assert.Equal(t, 2, status.ErrorCount())
+
+	assert.Equal(t, []string{"odd", "bad", "lost"}, sink.Messages())
+	d := sink.Diagnostics[1]
+	assert.Equal(t, SeverityError, d.Severity)
+	assert.Equal(t, &Span{File: "test", Begin: Position{Line: 2, Column: 7}, End: Position{Line: 2, Column: 11}, Text: "second line"}, d.Span)
+	assert.Equal(t, Position{Line: 2, Column: 0}, d.Related[0].Span.Begin)
+	assert.Nil(t, sink.Diagnostics[2].Span)
+}
+
+func TestTextSink(t *testing.T) {
+	sources := CreateSourceSet()
+	info := sources.Add("test", []byte("a\nbad\n"))
+	var out bytes.Buffer
+	status := CreateStatus(sources, &TextSink{Out: &out})
+	status.Error(info.Location(3, 4), "bad thing", Related{Loc: info.Location(0, 1), Message: "see here"})
+	status.Error(Location{}, "somewhere")
+	assert.Equal(t, "test:2:1 - ERROR bad thing\nbad\n ^\ntest:1:0 - NOTE see here\na\n^\nERROR somewhere\n", out.String())
+}
+
+func TestJSONSink(t *testing.T) {
+	sources := CreateSourceSet()
+	info := sources.Add("test", []byte("abc"))
+	var out bytes.Buffer
+	status := CreateStatus(sources, &JSONSink{Out: &out})
+	status.Warning(info.Location(1, 2), "hmm")
+	assert.Equal(t, `{"severity":"warning","span":{"file":"test","begin":{"line":1,"column":1},"end":{"line":1,"column":2}},"message":"hmm"}`+"\n", out.String())
+}
+
+func TestFix(t *testing.T) {
+	sources := CreateSourceSet()
+	info := sources.Add("test", []byte("hp: 3"))
+	var text, json bytes.Buffer
+	for _, sink := range []Sink{&TextSink{Out: &text}, &JSONSink{Out: &json}} {
+		status := CreateStatus(sources, sink)
+		status.WarningWithFix(info.Location(0, 2), "old name", Fix{Loc: info.Location(0, 2), Text: "health"})
+		assert.False(t, status.ShouldStop())
+	}
+	assert.Equal(t, "test:1:0 - WARNING old name\nhp: 3\n^\ntest:1:0 - NOTE fix, replace with \"health\"\nhp: 3\n^\n", text.String())
+	assert.Equal(t, `{"severity":"warning","span":{"file":"test","begin":{"line":1,"column":0},"end":{"line":1,"column":2}},"message":"old name","fixes":[{"span":{"file":"test","begin":{"line":1,"column":0},"end":{"line":1,"column":2}},"new_text":"health"}]}`+"\n", json.String())
+}
+
+func TestSilentStatus(t *testing.T) {
+	status := &Status{}
+	status.Error(Location{}, "counted")
+	assert.True(t, status.ShouldStop())
+	assert.Equal(t, 1, status.ErrorCount())
+}
+
+func TestRecoveringCommaSeparated(t *testing.T) {
+	sources := CreateSourceSet()
+	data := []byte("[1, x{,}, 2 3, \"a,b\"]")
+	info := sources.Add("test", data)
+	state := CreateRuneParser(info, data)
+	sink := &MemorySink{}
+	status := CreateStatus(sources, sink)
+
+	digits := []string{}
+	skipped := []string{}
+	assert.True(t, Punc(state, '['))
+	RecoveringCommaSeparated(state, func(state *RuneParserState) (*Comments, bool) {
+		if !state.IsDigit() {
+			state.Expected("digit")
+			return nil, false
+		}
+		begin := state.Position()
+		state.GetNext()
+		digits = append(digits, state.Slice(begin).Text)
+		return &Comments{}, true
+	}, func(s SourceString) {
+		skipped = append(skipped, s.Text)
+	})
+	assert.True(t, Punc(state, ']'))
+	assert.True(t, state.IsEndOfStream())
+
+	assert.Equal(t, []string{"1", "2", "3"}, digits)
+	assert.Equal(t, []string{"x{,}", "\"a,b\""}, skipped)
+	assert.True(t, state.HasErrors())
+	state.ReportErrors(status)
+	assert.Equal(t, []string{"expected digit", "expected ','", "expected digit"}, sink.Messages())
+	assert.Equal(t, Position{Line: 1, Column: 12}, sink.Diagnostics[1].Span.Begin)
+}
+
+func TestEmptyFilePosition(t *testing.T) {
+	sources := CreateSourceSet()
+	info := sources.Add("test", []byte{})
+	sink := &MemorySink{}
+	status := CreateStatus(sources, sink)
+	status.Error(info.Location(0, 0), "empty")
+	assert.Equal(t, Position{Line: 1, Column: 0}, sink.Diagnostics[0].Span.Begin)
+}
+
+func TestCommentDoc(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		doc  string
+		ok   bool
+	}{
+		{"/// An item.", "An item.", true},
+		{"///  Indented.", " Indented.", true},
+		{"///", "", true},
+		{"/** One line. */", "One line.", true},
+		{"/**\n * First.\n *\n *   Code.\n */", "First.\n\n  Code.", true},
+		{"// Plain.", "", false},
+		{"//// Banner.", "", false},
+		{"/* Block. */", "", false},
+		{"/**/", "", false},
+	} {
+		doc, ok := (&Comment{Raw: SourceString{Text: c.text}}).Doc()
+		assert.Equal(t, c.ok, ok, c.text)
+		assert.Equal(t, c.doc, doc, c.text)
+	}
+}
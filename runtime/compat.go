@@ -0,0 +1,341 @@
+package runtime
+
+import (
+	"fmt"
+)
+
+// Change is a difference between two versions of a region's schema.
+type Change struct {
+	Region string
+	// Data written with the old schema cannot be read with the new schema.
+	Breaking bool
+	Message  string
+}
+
+func (c *Change) String() string {
+	return c.Region + ": " + c.Message
+}
+
+// Pair up the names in two versions of a list.  Names in both versions are
+// matched, then names only in one version at the same position are taken to be
+// renames.  Old names without a match map to -1, and new names without a match
+// are returned as added.
+func matchNames(old []string, new []string) ([]int, []int) {
+	index := map[string]int{}
+	for i, n := range new {
+		index[n] = i
+	}
+	matched := make([]bool, len(new))
+	pairs := make([]int, len(old))
+	for i, n := range old {
+		j, ok := index[n]
+		if ok {
+			matched[j] = true
+		} else {
+			j = -1
+		}
+		pairs[i] = j
+	}
+	for i, j := range pairs {
+		if j < 0 && i < len(new) && !matched[i] {
+			pairs[i] = i
+			matched[i] = true
+		}
+	}
+	added := []int{}
+	for j, ok := range matched {
+		if !ok {
+			added = append(added, j)
+		}
+	}
+	return pairs, added
+}
+
+type comparison struct {
+	old     *RegionSchema
+	new     *RegionSchema
+	structs map[*StructSchema]*StructSchema
+	enums   map[*EnumSchema]*EnumSchema
+	unions  map[*UnionSchema]*UnionSchema
+	changes []*Change
+}
+
+func (c *comparison) report(breaking bool, format string, args ...interface{}) {
+	c.changes = append(c.changes, &Change{Region: c.old.Name, Breaking: breaking, Message: fmt.Sprintf(format, args...)})
+}
+
+// Is the new type the old type, allowing for renames?
+func (c *comparison) sameType(ot TypeSchema, nt TypeSchema) bool {
+	switch ot := ot.(type) {
+	case *StructSchema:
+		return c.structs[ot] == nt
+	case *EnumSchema:
+		return c.enums[ot] == nt
+	case *UnionSchema:
+		return c.unions[ot] == nt
+	case *OptionalSchema:
+		nt, ok := nt.(*OptionalSchema)
+		return ok && c.sameType(ot.Element, nt.Element)
+	case *ListSchema:
+		nt, ok := nt.(*ListSchema)
+		return ok && c.sameType(ot.Element, nt.Element)
+	case *ArraySchema:
+		nt, ok := nt.(*ArraySchema)
+		return ok && ot.Length == nt.Length && c.sameType(ot.Element, nt.Element)
+	case *MapSchema:
+		nt, ok := nt.(*MapSchema)
+		return ok && c.sameType(ot.Key, nt.Key) && c.sameType(ot.Value, nt.Value)
+	default:
+		return ot.CanonicalName() == nt.CanonicalName()
+	}
+}
+
+// Can every value of type b be held by type a?  Only numbers can be widened.
+func holdsAll(a TypeSchema, b TypeSchema) bool {
+	switch a := a.(type) {
+	case *IntegerSchema:
+		b, ok := b.(*IntegerSchema)
+		if !ok || a.Unsigned && !b.Unsigned {
+			return false
+		}
+		if a.Unsigned == b.Unsigned {
+			return a.Bits >= b.Bits
+		}
+		return a.Bits > b.Bits
+	case *FloatSchema:
+		b, ok := b.(*FloatSchema)
+		return ok && a.Bits >= b.Bits
+	default:
+		return false
+	}
+}
+
+func (c *comparison) compareType(field string, ot TypeSchema, nt TypeSchema) {
+	same := c.sameType(ot, nt)
+	if c.old.layout(ot) == c.new.layout(nt) {
+		if !same {
+			c.report(false, "field %s changed type from %s to %s, the encoding is unchanged", field, ot.CanonicalName(), nt.CanonicalName())
+		}
+		return
+	}
+	switch {
+	case same:
+		c.report(true, "field %s has type %s, which is now encoded differently", field, nt.CanonicalName())
+	case holdsAll(nt, ot):
+		c.report(true, "field %s was widened from %s to %s, the encoding changes", field, ot.CanonicalName(), nt.CanonicalName())
+	case holdsAll(ot, nt):
+		c.report(true, "field %s was narrowed from %s to %s", field, ot.CanonicalName(), nt.CanonicalName())
+	default:
+		c.report(true, "field %s changed type from %s to %s", field, ot.CanonicalName(), nt.CanonicalName())
+	}
+}
+
+func (c *comparison) compareStructs() {
+	names := func(structs []*StructSchema) []string {
+		out := make([]string, len(structs))
+		for i, s := range structs {
+			out[i] = s.Name
+		}
+		return out
+	}
+	pairs, added := matchNames(names(c.old.Structs), names(c.new.Structs))
+	for i, j := range pairs {
+		os := c.old.Structs[i]
+		if j < 0 {
+			c.report(true, "struct %s was removed", os.Name)
+			continue
+		}
+		ns := c.new.Structs[j]
+		c.structs[os] = ns
+		if os.Name != ns.Name {
+			c.report(false, "struct %s was renamed to %s", os.Name, ns.Name)
+		}
+		if i != j {
+			c.report(true, "struct %s moved from position %d to %d, pools are encoded in declaration order", os.Name, i, j)
+		}
+	}
+	for _, j := range added {
+		c.report(true, "struct %s was added, old data does not have a pool for it", c.new.Structs[j].Name)
+	}
+}
+
+func (c *comparison) compareFields(os *StructSchema, ns *StructSchema) {
+	names := func(fields []*FieldSchema) []string {
+		out := make([]string, len(fields))
+		for i, f := range fields {
+			out[i] = f.Name
+		}
+		return out
+	}
+	pairs, added := matchNames(names(os.Fields), names(ns.Fields))
+	for i, j := range pairs {
+		of := os.Fields[i]
+		if j < 0 {
+			c.report(true, "field %s.%s was removed", os.Name, of.Name)
+			continue
+		}
+		nf := ns.Fields[j]
+		if of.Name != nf.Name {
+			c.report(false, "field %s.%s was renamed to %s", os.Name, of.Name, nf.Name)
+		}
+		if i != j {
+			c.report(true, "field %s.%s was reordered from position %d to %d", os.Name, of.Name, i, j)
+		}
+		c.compareType(ns.Name+"."+nf.Name, of.Type, nf.Type)
+	}
+	for _, j := range added {
+		c.report(true, "field %s.%s was added", ns.Name, ns.Fields[j].Name)
+	}
+}
+
+func (c *comparison) compareEnums() {
+	names := func(enums []*EnumSchema) []string {
+		out := make([]string, len(enums))
+		for i, e := range enums {
+			out[i] = e.Name
+		}
+		return out
+	}
+	pairs, _ := matchNames(names(c.old.Enums), names(c.new.Enums))
+	for i, j := range pairs {
+		if j < 0 {
+			// Fields that used the enum report the change.
+			continue
+		}
+		oe := c.old.Enums[i]
+		ne := c.new.Enums[j]
+		c.enums[oe] = ne
+		if oe.Name != ne.Name {
+			c.report(false, "enum %s was renamed to %s", oe.Name, ne.Name)
+		}
+		values, added := matchNames(oe.Values, ne.Values)
+		for vi, vj := range values {
+			switch {
+			case vj < 0:
+				c.report(true, "value %s.%s was removed", oe.Name, oe.Values[vi])
+			case vi != vj:
+				c.report(true, "value %s.%s moved from index %d to %d, the data stores the index", oe.Name, oe.Values[vi], vi, vj)
+			case oe.Values[vi] != ne.Values[vj]:
+				c.report(false, "value %s.%s was renamed to %s", oe.Name, oe.Values[vi], ne.Values[vj])
+			}
+		}
+		for _, vj := range added {
+			c.report(false, "value %s.%s was added", ne.Name, ne.Values[vj])
+		}
+	}
+}
+
+func (c *comparison) compareUnions() {
+	names := func(unions []*UnionSchema) []string {
+		out := make([]string, len(unions))
+		for i, u := range unions {
+			out[i] = u.Name
+		}
+		return out
+	}
+	pairs, _ := matchNames(names(c.old.Unions), names(c.new.Unions))
+	for i, j := range pairs {
+		if j < 0 {
+			continue
+		}
+		ou := c.old.Unions[i]
+		nu := c.new.Unions[j]
+		c.unions[ou] = nu
+		if ou.Name != nu.Name {
+			c.report(false, "union %s was renamed to %s", ou.Name, nu.Name)
+		}
+		// Compare the arms by their new names, so renamed structs match.
+		old_arms := make([]string, len(ou.Arms))
+		for ai, a := range ou.Arms {
+			old_arms[ai] = a.Name
+			if ns, ok := c.structs[a]; ok {
+				old_arms[ai] = ns.Name
+			}
+		}
+		new_arms := make([]string, len(nu.Arms))
+		for aj, a := range nu.Arms {
+			new_arms[aj] = a.Name
+		}
+		arms, added := matchNames(old_arms, new_arms)
+		for ai, aj := range arms {
+			switch {
+			case aj < 0:
+				c.report(true, "arm %s of union %s was removed", ou.Arms[ai].Name, ou.Name)
+			case ai != aj:
+				c.report(true, "arm %s of union %s moved from tag %d to %d, the data stores the tag", ou.Arms[ai].Name, ou.Name, ai, aj)
+			case old_arms[ai] != new_arms[aj]:
+				// A different struct now has the tag.
+				c.report(true, "arm %s of union %s was replaced by %s", ou.Arms[ai].Name, ou.Name, new_arms[aj])
+			}
+		}
+		for _, aj := range added {
+			c.report(false, "arm %s of union %s was added", nu.Arms[aj].Name, nu.Name)
+		}
+	}
+}
+
+func compareRegion(old *RegionSchema, new *RegionSchema) []*Change {
+	c := &comparison{
+		old:     old,
+		new:     new,
+		structs: map[*StructSchema]*StructSchema{},
+		enums:   map[*EnumSchema]*EnumSchema{},
+		unions:  map[*UnionSchema]*UnionSchema{},
+	}
+	c.compareStructs()
+	// Types are matched before fields are compared, so renamed types are
+	// recognized.
+	c.compareEnums()
+	c.compareUnions()
+	for _, os := range old.Structs {
+		if ns, ok := c.structs[os]; ok {
+			c.compareFields(os, ns)
+		}
+	}
+	// Readers reject data unless the fingerprint in the header matches, and
+	// the fingerprint covers every name, so even changes that keep the layout
+	// cannot be read.
+	if old.Fingerprint() != new.Fingerprint() {
+		for _, change := range c.changes {
+			if !change.Breaking {
+				change.Breaking = true
+				change.Message += ", which changes the fingerprint in the header"
+			}
+		}
+		if len(c.changes) == 0 {
+			c.report(true, "the fingerprint in the header changed")
+		}
+	}
+	return c.changes
+}
+
+// Describe how the schemas of regions changed between two versions, and
+// whether data written with the old version can be read with the new version.
+// A change is only compatible if readers still accept the header, so within a
+// region that is only true of changes that keep the fingerprint.
+// Regions are matched by name, as the name is checked when data is read.
+// Changes that do not affect the binary encoding, such as defaults, are not
+// reported.
+func CompareRegions(old []*RegionSchema, new []*RegionSchema) []*Change {
+	changes := []*Change{}
+	lut := map[string]*RegionSchema{}
+	for _, r := range new {
+		lut[r.Name] = r
+	}
+	seen := map[string]bool{}
+	for _, r := range old {
+		other, ok := lut[r.Name]
+		if !ok {
+			changes = append(changes, &Change{Region: r.Name, Breaking: true, Message: "region was removed"})
+			continue
+		}
+		seen[r.Name] = true
+		changes = append(changes, compareRegion(r, other)...)
+	}
+	for _, r := range new {
+		if !seen[r.Name] {
+			changes = append(changes, &Change{Region: r.Name, Message: "region was added"})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,164 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func describeChanges(changes []*Change) []string {
+	out := make([]string, len(changes))
+	for i, c := range changes {
+		prefix := "ok "
+		if c.Breaking {
+			prefix = "BREAKING "
+		}
+		out[i] = prefix + c.String()
+	}
+	return out
+}
+
+func TestCompareUnchanged(t *testing.T) {
+	changed := inventorySchema()
+	changed.StructLUT["Item"].FieldLUT["count"].Default = uint64(5)
+	assert.Equal(t, []string{}, describeChanges(CompareRegions([]*RegionSchema{inventorySchema()}, []*RegionSchema{changed})))
+}
+
+func TestCompareRenames(t *testing.T) {
+	changed := inventorySchema()
+	changed.Structs[1].Name = "Sack"
+	changed.StructLUT["Item"].FieldLUT["name"].Name = "title"
+	changed.Enums[0].Values = []string{"tool", "meal", "junk"}
+	other := &RegionSchema{Name: "Other"}
+
+	changes := CompareRegions([]*RegionSchema{inventorySchema()}, []*RegionSchema{changed.Init(), other.Init()})
+	assert.Equal(t, []string{
+		"BREAKING Inventory: struct Bag was renamed to Sack, which changes the fingerprint in the header",
+		"BREAKING Inventory: value Kind.food was renamed to meal, which changes the fingerprint in the header",
+		"BREAKING Inventory: value Kind.junk was added, which changes the fingerprint in the header",
+		"BREAKING Inventory: field Item.name was renamed to title, which changes the fingerprint in the header",
+		"ok Other: region was added",
+	}, describeChanges(changes))
+
+	// The bytes could be decoded, but readers reject the header.
+	assert.Equal(t, inventorySchema().LayoutFingerprint(), changed.LayoutFingerprint())
+}
+
+func TestCompareBreaking(t *testing.T) {
+	changed := inventorySchema()
+	item := changed.StructLUT["Item"]
+	item.Fields = []*FieldSchema{
+		item.FieldLUT["kind"],
+		item.FieldLUT["name"],
+		{Name: "count", Type: &IntegerSchema{Bits: 32, Unsigned: true}},
+	}
+	changed.Enums[0].Values = []string{"food", "tool"}
+	changed.Structs = append(changed.Structs, &StructSchema{Name: "Chest"})
+
+	changes := CompareRegions([]*RegionSchema{inventorySchema(), &RegionSchema{Name: "Gone"}}, []*RegionSchema{changed.Init()})
+	assert.Equal(t, []string{
+		"BREAKING Inventory: struct Chest was added, old data does not have a pool for it",
+		"BREAKING Inventory: value Kind.tool moved from index 0 to 1, the data stores the index",
+		"BREAKING Inventory: value Kind.food moved from index 1 to 0, the data stores the index",
+		"BREAKING Inventory: field Item.name was reordered from position 0 to 1",
+		"BREAKING Inventory: field Item.kind was reordered from position 1 to 0",
+		"BREAKING Inventory: field Item.count was widened from uint16 to uint32, the encoding changes",
+		"BREAKING Inventory: field Item.next was removed",
+		"BREAKING Gone: region was removed",
+	}, describeChanges(changes))
+	assert.NotEqual(t, inventorySchema().LayoutFingerprint(), changed.LayoutFingerprint())
+}
+
+func TestCompareTypes(t *testing.T) {
+	changed := inventorySchema()
+	item := changed.StructLUT["Item"]
+	item.FieldLUT["count"].Type = &IntegerSchema{Bits: 8, Unsigned: true}
+	item.FieldLUT["name"].Type = &BytesSchema{}
+	item.Fields = append(item.Fields, &FieldSchema{Name: "weight", Type: &FloatSchema{Bits: 32}})
+	// With one value the index is no longer encoded.
+	changed.Enums[0].Values = []string{"tool"}
+
+	changes := CompareRegions([]*RegionSchema{inventorySchema()}, []*RegionSchema{changed.Init()})
+	assert.Equal(t, []string{
+		"BREAKING Inventory: value Kind.food was removed",
+		"BREAKING Inventory: field Item.name changed type from string to bytes",
+		"BREAKING Inventory: field Item.kind has type Kind, which is now encoded differently",
+		"BREAKING Inventory: field Item.count was narrowed from uint16 to uint8",
+		"BREAKING Inventory: field Item.weight was added",
+	}, describeChanges(changes))
+}
+
+func TestCompareUnions(t *testing.T) {
+	build := func(arms ...string) *RegionSchema {
+		rs := &RegionSchema{Name: "Shapes"}
+		lut := map[string]*StructSchema{}
+		for _, name := range []string{"Circle", "Square", "Line"} {
+			s := &StructSchema{Name: name}
+			lut[name] = s
+			rs.Structs = append(rs.Structs, s)
+		}
+		u := &UnionSchema{Name: "Shape"}
+		for _, a := range arms {
+			u.Arms = append(u.Arms, lut[a])
+		}
+		rs.Unions = []*UnionSchema{u}
+		return rs.Init()
+	}
+	old := []*RegionSchema{build("Circle", "Square")}
+	assert.Equal(t, []string{
+		"BREAKING Shapes: arm Line of union Shape was added, which changes the fingerprint in the header",
+	}, describeChanges(CompareRegions(old, []*RegionSchema{build("Circle", "Square", "Line")})))
+	assert.Equal(t, []string{
+		"BREAKING Shapes: arm Circle of union Shape moved from tag 0 to 1, the data stores the tag",
+		"BREAKING Shapes: arm Square of union Shape moved from tag 1 to 0, the data stores the tag",
+	}, describeChanges(CompareRegions(old, []*RegionSchema{build("Square", "Circle")})))
+	assert.Equal(t, []string{
+		"BREAKING Shapes: arm Square of union Shape was replaced by Line",
+	}, describeChanges(CompareRegions(old, []*RegionSchema{build("Circle", "Line")})))
+}
+
+// Compat only calls a change compatible if the new schema's reader accepts
+// data written with the old schema.
+func TestCompareMatchesReaders(t *testing.T) {
+	for name, change := range map[string]func(rs *RegionSchema){
+		"default": func(rs *RegionSchema) {
+			rs.StructLUT["Item"].FieldLUT["count"].Default = uint64(5)
+		},
+		"constraint": func(rs *RegionSchema) {
+			rs.StructLUT["Item"].FieldLUT["name"].Constraints = &Constraints{MinLength: 1}
+		},
+		"doc": func(rs *RegionSchema) {
+			rs.StructLUT["Item"].Doc = "An item."
+		},
+		"struct renamed": func(rs *RegionSchema) {
+			rs.StructLUT["Bag"].Name = "Sack"
+		},
+		"field renamed": func(rs *RegionSchema) {
+			rs.StructLUT["Item"].FieldLUT["name"].Name = "title"
+		},
+		"value renamed": func(rs *RegionSchema) {
+			rs.Enums[0].Values = []string{"tool", "meal"}
+		},
+		"value added": func(rs *RegionSchema) {
+			rs.Enums[0].Values = append(rs.Enums[0].Values, "junk")
+		},
+		"unused enum added": func(rs *RegionSchema) {
+			rs.Enums = append(rs.Enums, &EnumSchema{Name: "Color", Values: []string{"red"}})
+		},
+	} {
+		region := CreateDynamicRegion(inventorySchema())
+		region.Allocate("Item").(*DynamicStruct).Set(region.Schema().StructLUT["Item"].FieldLUT["name"], "rope")
+		data, err := region.MarshalBinary()
+		assert.NoError(t, err)
+
+		changed := inventorySchema()
+		change(changed)
+		changed.Init()
+		breaking := false
+		for _, c := range CompareRegions([]*RegionSchema{inventorySchema()}, []*RegionSchema{changed}) {
+			breaking = breaking || c.Breaking
+		}
+		err = CreateDynamicRegion(changed).UnmarshalBinary(data)
+		assert.Equal(t, breaking, err != nil, name)
+	}
+}
@@ -1,8 +1,11 @@
 package runtime
 
 import (
+	"encoding/hex"
+	"github.com/ncbray/compilerutil/names"
 	"github.com/ncbray/compilerutil/writer"
 	"io"
+	"math"
 	"reflect"
 	"strconv"
 )
@@ -11,26 +14,123 @@ type RommyStruct interface {
 	Schema() *StructSchema
 }
 
-func isDefaultValue(o reflect.Value, schema TypeSchema) bool {
-	switch schema := schema.(type) {
+func isDefaultValue(o reflect.Value, f *FieldSchema) bool {
+	switch schema := f.Type.(type) {
 	case *StringSchema:
-		return o.String() == ""
+		def, _ := f.Default.(string)
+		return o.String() == def
+	case *BooleanSchema:
+		def, _ := f.Default.(bool)
+		return o.Bool() == def
 	case *IntegerSchema:
 		if schema.Unsigned {
-			return o.Uint() == 0
+			def, _ := f.Default.(uint64)
+			return o.Uint() == def
 		} else {
-			return o.Int() == 0
+			def, _ := f.Default.(int64)
+			return o.Int() == def
 		}
-	case *StructSchema:
-		return false
-	case *ListSchema:
+	case *FloatSchema:
+		def, _ := f.Default.(float64)
+		return o.Float() == def
+	case *EnumSchema:
+		def, _ := f.Default.(int)
+		return o.Uint() == uint64(def)
+	case *StructSchema, *OptionalSchema, *UnionSchema:
+		return o.IsNil()
+	case *ListSchema, *MapSchema, *BytesSchema:
 		return o.Len() == 0
+	case *ArraySchema:
+		return reflect.DeepEqual(o.Interface(), reflect.Zero(o.Type()).Interface())
 	default:
 		panic(schema)
 	}
 }
 
-func dumpStruct(o reflect.Value, schema TypeSchema, expected TypeSchema, out *writer.TabbedWriter) {
+type textDumper struct {
+	// How many times each struct is referenced.
+	references map[interface{}]int
+	labels     map[interface{}]string
+	usedLabels map[string]bool
+	// Labels that have already been defined in the output.
+	emitted map[interface{}]bool
+	out     *writer.TabbedWriter
+}
+
+// Count the references to each struct reachable from o.
+func (d *textDumper) countReferences(o reflect.Value, schema TypeSchema) {
+	switch schema := schema.(type) {
+	case *StructSchema:
+		if o.IsNil() {
+			return
+		}
+		key := o.Interface()
+		d.references[key] += 1
+		if d.references[key] > 1 {
+			return
+		}
+		for _, f := range schema.Fields {
+			d.countReferences(StructField(o, f), f.Type)
+		}
+	case *OptionalSchema:
+		d.countReferences(o, schema.Element)
+	case *UnionSchema:
+		if o.IsNil() {
+			return
+		}
+		o = o.Elem()
+		d.countReferences(o, o.Interface().(RommyStruct).Schema())
+	case *ArraySchema:
+		for i := 0; i < o.Len(); i++ {
+			d.countReferences(o.Index(i), schema.Element)
+		}
+	case *ListSchema:
+		for i := 0; i < o.Len(); i++ {
+			d.countReferences(o.Index(i), schema.Element)
+		}
+	case *MapSchema:
+		keys := sortedKeys(o)
+		for i := 0; i < keys.Len(); i++ {
+			d.countReferences(o.MapIndex(keys.Index(i)), schema.Value)
+		}
+	}
+}
+
+// Get the label for a struct, or "" if it is only referenced once.
+func (d *textDumper) label(key interface{}, schema *StructSchema) string {
+	if d.references[key] <= 1 {
+		return ""
+	}
+	l, ok := d.labels[key]
+	if !ok {
+		base := names.JoinCamelCase(names.SplitCamelCase(schema.Name), false)
+		for i := 0; ; i++ {
+			l = base + strconv.Itoa(i)
+			if !d.usedLabels[l] {
+				break
+			}
+		}
+		d.usedLabels[l] = true
+		d.labels[key] = l
+	}
+	return l
+}
+
+// Format a float the way it is written in data.
+func formatFloat(f float64, t *FloatSchema) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, int(t.Bits))
+}
+
+func (d *textDumper) dumpStruct(o reflect.Value, schema TypeSchema, expected TypeSchema) {
+	out := d.out
 	switch schema := schema.(type) {
 	case *StringSchema:
 		// TODO custom string quoting.
@@ -41,8 +141,35 @@ func dumpStruct(o reflect.Value, schema TypeSchema, expected TypeSchema, out *wr
 		} else {
 			out.WriteString(strconv.FormatInt(o.Int(), 10))
 		}
-	case *StructSchema:
+	case *BooleanSchema:
+		out.WriteString(strconv.FormatBool(o.Bool()))
+	case *FloatSchema:
+		out.WriteString(formatFloat(o.Float(), schema))
+	case *EnumSchema:
+		out.WriteString(schema.Values[o.Uint()])
+	case *OptionalSchema:
+		if o.IsNil() {
+			out.WriteString("null")
+			return
+		}
+		d.dumpStruct(o, schema.Element, schema.Element)
+	case *UnionSchema:
+		// The arm is written as a typed struct literal.
 		o = o.Elem()
+		d.dumpStruct(o, o.Interface().(RommyStruct).Schema(), schema)
+	case *StructSchema:
+		key := o.Interface()
+		label := d.label(key, schema)
+		if label != "" {
+			if d.emitted[key] {
+				out.WriteString("@")
+				out.WriteString(label)
+				return
+			}
+			d.emitted[key] = true
+			out.WriteString(label)
+			out.WriteString(" = ")
+		}
 		if schema != expected {
 			out.WriteString(schema.Name)
 			out.WriteString(" ")
@@ -51,25 +178,40 @@ func dumpStruct(o reflect.Value, schema TypeSchema, expected TypeSchema, out *wr
 		out.EndOfLine()
 		out.Indent()
 		for _, f := range schema.Fields {
-			child := o.FieldByName(f.GoName())
-			if isDefaultValue(child, f.Type) {
+			child := StructField(o, f)
+			if isDefaultValue(child, f) {
 				continue
 			}
 			out.WriteString(f.Name)
 			out.WriteString(": ")
-			dumpStruct(child, f.Type, f.Type, out)
+			d.dumpStruct(child, f.Type, f.Type)
 			out.WriteString(",")
 			out.EndOfLine()
 		}
 		out.Dedent()
 		out.WriteString("}")
 	case *ListSchema:
+		d.dumpElements(o, schema.Element)
+	case *ArraySchema:
+		d.dumpElements(o, schema.Element)
+	case *BytesSchema:
+		out.WriteString("x\"")
+		out.WriteString(hex.EncodeToString(o.Bytes()))
+		out.WriteString("\"")
+	case *MapSchema:
+		if o.Len() == 0 {
+			out.WriteString("[:]")
+			return
+		}
 		out.WriteString("[")
 		out.EndOfLine()
 		out.Indent()
-		for i := 0; i < o.Len(); i++ {
-			child := o.Index(i)
-			dumpStruct(child, schema.Element, schema.Element, out)
+		keys := sortedKeys(o)
+		for i := 0; i < keys.Len(); i++ {
+			key := keys.Index(i)
+			d.dumpStruct(key, schema.Key, schema.Key)
+			out.WriteString(": ")
+			d.dumpStruct(o.MapIndex(key), schema.Value, schema.Value)
 			out.WriteString(",")
 			out.EndOfLine()
 		}
@@ -80,8 +222,33 @@ func dumpStruct(o reflect.Value, schema TypeSchema, expected TypeSchema, out *wr
 	}
 }
 
-func DumpText(s RommyStruct, w io.Writer) {
-	out := writer.MakeTabbedWriter("  ", w)
-	dumpStruct(reflect.ValueOf(s), s.Schema(), nil, out)
+func (d *textDumper) dumpElements(o reflect.Value, element TypeSchema) {
+	out := d.out
+	out.WriteString("[")
 	out.EndOfLine()
+	out.Indent()
+	for i := 0; i < o.Len(); i++ {
+		child := o.Index(i)
+		d.dumpStruct(child, element, element)
+		out.WriteString(",")
+		out.EndOfLine()
+	}
+	out.Dedent()
+	out.WriteString("]")
+}
+
+// Write a struct and everything reachable from it as text.  Structs that are
+// reachable more than once are labeled.
+func DumpText(s RommyStruct, w io.Writer) {
+	d := &textDumper{
+		references: map[interface{}]int{},
+		labels:     map[interface{}]string{},
+		usedLabels: map[string]bool{},
+		emitted:    map[interface{}]bool{},
+		out:        writer.MakeTabbedWriter("  ", w),
+	}
+	o := reflect.ValueOf(s)
+	d.countReferences(o, s.Schema())
+	d.dumpStruct(o, s.Schema(), nil)
+	d.out.EndOfLine()
 }
@@ -0,0 +1,494 @@
+package runtime
+
+import (
+	"reflect"
+)
+
+// DynamicStruct is an instance of a struct that is not a generated Go type.
+// Fields are addressed by their FieldSchema, and hold the same Go types as the
+// fields of a generated struct, except structs are *DynamicStruct, unions are
+// Struct, and enums are uint32.  See DynamicType.
+type DynamicStruct struct {
+	schema    *StructSchema
+	PoolIndex int
+	fields    []reflect.Value
+}
+
+func (o *DynamicStruct) Schema() *StructSchema {
+	return o.schema
+}
+
+// The value of a field, which can be set.
+func (o *DynamicStruct) Field(f *FieldSchema) reflect.Value {
+	return o.fields[f.ID]
+}
+
+func (o *DynamicStruct) Get(f *FieldSchema) interface{} {
+	return o.fields[f.ID].Interface()
+}
+
+// Set a field.  Panics if the value is not of the field's type.
+func (o *DynamicStruct) Set(f *FieldSchema, value interface{}) {
+	if value == nil {
+		o.fields[f.ID].Set(reflect.Zero(o.fields[f.ID].Type()))
+		return
+	}
+	o.fields[f.ID].Set(reflect.ValueOf(value))
+}
+
+// DynamicRegion holds DynamicStructs, so data can be handled with any schema,
+// including one loaded at runtime, without generated code.
+type DynamicRegion struct {
+	schema *RegionSchema
+	pools  map[*StructSchema][]*DynamicStruct
+}
+
+func CreateDynamicRegion(schema *RegionSchema) *DynamicRegion {
+	return &DynamicRegion{schema: schema, pools: map[*StructSchema][]*DynamicStruct{}}
+}
+
+func (r *DynamicRegion) Schema() *RegionSchema {
+	return r.schema
+}
+
+// Allocate a struct with every field set to its default.  Returns nil if the
+// region does not have a struct with that name.
+func (r *DynamicRegion) Allocate(name string) interface{} {
+	s, ok := r.schema.StructLUT[name]
+	if !ok {
+		return nil
+	}
+	o := &DynamicStruct{schema: s, fields: make([]reflect.Value, len(s.Fields))}
+	for i, f := range s.Fields {
+		t := DynamicType(f.Type)
+		v := reflect.New(t).Elem()
+		if f.Default != nil {
+			v.Set(reflect.ValueOf(f.Default).Convert(t))
+		}
+		o.fields[i] = v
+	}
+	o.PoolIndex = len(r.pools[s])
+	r.pools[s] = append(r.pools[s], o)
+	return o
+}
+
+// The structs of a type, in the order they were allocated.
+func (r *DynamicRegion) Pool(s *StructSchema) []*DynamicStruct {
+	return r.pools[s]
+}
+
+// Encode the region exactly as the generated MarshalBinary would.  After the
+// header, the number of structs in each pool is written, then the fields of
+// each struct.
+func (r *DynamicRegion) MarshalBinary() ([]byte, error) {
+	s := MakeSerializer()
+	WriteHeader(s, r.schema)
+	for _, st := range r.schema.Structs {
+		err := s.WriteCount(len(r.pools[st]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, st := range r.schema.Structs {
+		for _, o := range r.pools[st] {
+			for _, f := range st.Fields {
+				err := r.serialize(s, o.fields[f.ID], f.Type, st.Name+"."+f.Name)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return s.Data(), nil
+}
+
+// field describes what is being serialized for error messages.
+func (r *DynamicRegion) serialize(s *Serializer, v reflect.Value, t TypeSchema, field string) error {
+	switch t := t.(type) {
+	case *IntegerSchema:
+		if t.Unsigned {
+			switch t.Bits {
+			case 8:
+				s.WriteUint8(uint8(v.Uint()))
+			case 16:
+				s.WriteUint16(uint16(v.Uint()))
+			case 32:
+				s.WriteUint32(uint32(v.Uint()))
+			default:
+				s.WriteUint64(v.Uint())
+			}
+		} else {
+			switch t.Bits {
+			case 8:
+				s.WriteInt8(int8(v.Int()))
+			case 16:
+				s.WriteInt16(int16(v.Int()))
+			case 32:
+				s.WriteInt32(int32(v.Int()))
+			default:
+				s.WriteInt64(v.Int())
+			}
+		}
+	case *FloatSchema:
+		if t.Bits == 32 {
+			s.WriteFloat32(float32(v.Float()))
+		} else {
+			s.WriteFloat64(v.Float())
+		}
+	case *StringSchema:
+		s.WriteString(v.String())
+	case *BooleanSchema:
+		s.WriteBool(v.Bool())
+	case *BytesSchema:
+		s.WriteBytes(v.Bytes())
+	case *StructSchema:
+		if v.IsNil() {
+			return NilReference(field)
+		}
+		return s.WriteIndex(v.Interface().(*DynamicStruct).PoolIndex, len(r.pools[t]))
+	case *UnionSchema:
+		return r.serializeUnion(s, v, t, false, field)
+	case *OptionalSchema:
+		if u, ok := t.Element.(*UnionSchema); ok {
+			return r.serializeUnion(s, v, u, true, field)
+		}
+		pool := len(r.pools[t.Element.(*StructSchema)])
+		if v.IsNil() {
+			return s.WriteOptionalIndex(NoIndex, pool)
+		}
+		return s.WriteOptionalIndex(v.Interface().(*DynamicStruct).PoolIndex, pool)
+	case *EnumSchema:
+		return s.WriteIndex(int(v.Uint()), len(t.Values))
+	case *ListSchema:
+		err := s.WriteCount(v.Len())
+		if err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			err = r.serialize(s, v.Index(i), t.Element, field)
+			if err != nil {
+				return err
+			}
+		}
+	case *ArraySchema:
+		// The length is part of the type.
+		for i := 0; i < v.Len(); i++ {
+			err := r.serialize(s, v.Index(i), t.Element, field)
+			if err != nil {
+				return err
+			}
+		}
+	case *MapSchema:
+		err := s.WriteCount(v.Len())
+		if err != nil {
+			return err
+		}
+		// Iterate in key order so the encoding is deterministic.
+		keys := sortedKeys(v)
+		for i := 0; i < keys.Len(); i++ {
+			key := keys.Index(i)
+			err = r.serialize(s, key, t.Key, field)
+			if err != nil {
+				return err
+			}
+			err = r.serialize(s, v.MapIndex(key), t.Value, field)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		panic(t)
+	}
+	return nil
+}
+
+// Write the tag of the arm, then the index of the struct in its pool.
+func (r *DynamicRegion) serializeUnion(s *Serializer, v reflect.Value, t *UnionSchema, optional bool, field string) error {
+	if v.IsNil() {
+		if optional {
+			return s.WriteOptionalIndex(NoIndex, len(t.Arms))
+		}
+		return NilReference(field)
+	}
+	o := v.Interface().(*DynamicStruct)
+	tag := t.Tag(o.schema)
+	var err error
+	if optional {
+		err = s.WriteOptionalIndex(tag, len(t.Arms))
+	} else {
+		err = s.WriteIndex(tag, len(t.Arms))
+	}
+	if err != nil {
+		return err
+	}
+	return s.WriteIndex(o.PoolIndex, len(r.pools[o.schema]))
+}
+
+// Decode a region encoded by MarshalBinary or the generated MarshalBinary.
+func (r *DynamicRegion) UnmarshalBinary(data []byte) error {
+	d := MakeDeserializer(data)
+	err := CheckHeader(d, r.schema)
+	if err != nil {
+		return err
+	}
+	for _, st := range r.schema.Structs {
+		count, err := d.ReadCount()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			r.Allocate(st.Name)
+		}
+	}
+	for _, st := range r.schema.Structs {
+		for _, o := range r.pools[st] {
+			for _, f := range st.Fields {
+				err := r.deserialize(d, o.fields[f.ID], f.Type)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Read the struct at an index in a pool.
+func (r *DynamicRegion) readStruct(d *Deserializer, s *StructSchema) (*DynamicStruct, error) {
+	pool := r.pools[s]
+	index, err := d.ReadIndex(len(pool))
+	if err != nil {
+		return nil, err
+	}
+	return pool[index], nil
+}
+
+// Read a value into v, which can be set.
+func (r *DynamicRegion) deserialize(d *Deserializer, v reflect.Value, t TypeSchema) error {
+	switch t := t.(type) {
+	case *IntegerSchema:
+		if t.Unsigned {
+			var value uint64
+			var err error
+			switch t.Bits {
+			case 8:
+				var p uint8
+				p, err = d.ReadUint8()
+				value = uint64(p)
+			case 16:
+				var p uint16
+				p, err = d.ReadUint16()
+				value = uint64(p)
+			case 32:
+				var p uint32
+				p, err = d.ReadUint32()
+				value = uint64(p)
+			default:
+				value, err = d.ReadUint64()
+			}
+			if err != nil {
+				return err
+			}
+			v.SetUint(value)
+		} else {
+			var value int64
+			var err error
+			switch t.Bits {
+			case 8:
+				var p int8
+				p, err = d.ReadInt8()
+				value = int64(p)
+			case 16:
+				var p int16
+				p, err = d.ReadInt16()
+				value = int64(p)
+			case 32:
+				var p int32
+				p, err = d.ReadInt32()
+				value = int64(p)
+			default:
+				value, err = d.ReadInt64()
+			}
+			if err != nil {
+				return err
+			}
+			v.SetInt(value)
+		}
+	case *FloatSchema:
+		var value float64
+		var err error
+		if t.Bits == 32 {
+			var p float32
+			p, err = d.ReadFloat32()
+			value = float64(p)
+		} else {
+			value, err = d.ReadFloat64()
+		}
+		if err != nil {
+			return err
+		}
+		v.SetFloat(value)
+	case *StringSchema:
+		value, err := d.ReadString()
+		if err != nil {
+			return err
+		}
+		v.SetString(value)
+	case *BooleanSchema:
+		value, err := d.ReadBool()
+		if err != nil {
+			return err
+		}
+		v.SetBool(value)
+	case *BytesSchema:
+		value, err := d.ReadBytes()
+		if err != nil {
+			return err
+		}
+		v.SetBytes(value)
+	case *StructSchema:
+		o, err := r.readStruct(d, t)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(o))
+	case *UnionSchema:
+		return r.deserializeUnion(d, v, t, false)
+	case *OptionalSchema:
+		if u, ok := t.Element.(*UnionSchema); ok {
+			return r.deserializeUnion(d, v, u, true)
+		}
+		pool := r.pools[t.Element.(*StructSchema)]
+		index, err := d.ReadOptionalIndex(len(pool))
+		if err != nil {
+			return err
+		}
+		if index != NoIndex {
+			v.Set(reflect.ValueOf(pool[index]))
+		}
+	case *EnumSchema:
+		index, err := d.ReadIndex(len(t.Values))
+		if err != nil {
+			return err
+		}
+		v.SetUint(uint64(index))
+	case *ListSchema:
+		count, err := d.ReadCount()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), count, count))
+		for i := 0; i < count; i++ {
+			err = r.deserialize(d, v.Index(i), t.Element)
+			if err != nil {
+				return err
+			}
+		}
+	case *ArraySchema:
+		for i := 0; i < v.Len(); i++ {
+			err := r.deserialize(d, v.Index(i), t.Element)
+			if err != nil {
+				return err
+			}
+		}
+	case *MapSchema:
+		count, err := d.ReadCount()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), count))
+		for i := 0; i < count; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			err = r.deserialize(d, key, t.Key)
+			if err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			err = r.deserialize(d, value, t.Value)
+			if err != nil {
+				return err
+			}
+			if v.MapIndex(key).IsValid() {
+				return ErrDuplicateKey
+			}
+			v.SetMapIndex(key, value)
+		}
+	default:
+		panic(t)
+	}
+	return nil
+}
+
+func (r *DynamicRegion) deserializeUnion(d *Deserializer, v reflect.Value, t *UnionSchema, optional bool) error {
+	var tag int
+	var err error
+	if optional {
+		tag, err = d.ReadOptionalIndex(len(t.Arms))
+	} else {
+		tag, err = d.ReadIndex(len(t.Arms))
+	}
+	if err != nil {
+		return err
+	}
+	if tag == NoIndex {
+		return nil
+	}
+	o, err := r.readStruct(d, t.Arms[tag])
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(o))
+	return nil
+}
+
+// Call f for each struct directly referenced by a value.
+func forEachReference(v reflect.Value, t TypeSchema, f func(o *DynamicStruct)) {
+	switch t := t.(type) {
+	case *StructSchema, *UnionSchema:
+		if !v.IsNil() {
+			f(v.Interface().(*DynamicStruct))
+		}
+	case *OptionalSchema:
+		forEachReference(v, t.Element, f)
+	case *ListSchema:
+		for i := 0; i < v.Len(); i++ {
+			forEachReference(v.Index(i), t.Element, f)
+		}
+	case *ArraySchema:
+		for i := 0; i < v.Len(); i++ {
+			forEachReference(v.Index(i), t.Element, f)
+		}
+	case *MapSchema:
+		iter := v.MapRange()
+		for iter.Next() {
+			forEachReference(iter.Value(), t.Value, f)
+		}
+	}
+}
+
+// The structs that no other struct refers to, in pool order.  Data parsed from
+// text has a single root, so it is the only struct that is not referenced,
+// unless the root is part of a cycle.
+func (r *DynamicRegion) Roots() []*DynamicStruct {
+	referenced := map[*DynamicStruct]bool{}
+	for _, st := range r.schema.Structs {
+		for _, o := range r.pools[st] {
+			for _, f := range st.Fields {
+				forEachReference(o.fields[f.ID], f.Type, func(other *DynamicStruct) {
+					if other != o {
+						referenced[other] = true
+					}
+				})
+			}
+		}
+	}
+	roots := []*DynamicStruct{}
+	for _, st := range r.schema.Structs {
+		for _, o := range r.pools[st] {
+			if !referenced[o] {
+				roots = append(roots, o)
+			}
+		}
+	}
+	return roots
+}
@@ -0,0 +1,92 @@
+package runtime
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func inventorySchema() *RegionSchema {
+	kind := &EnumSchema{Name: "Kind", Values: []string{"tool", "food"}}
+	item := &StructSchema{Name: "Item"}
+	item.Fields = []*FieldSchema{
+		{Name: "name", Type: &StringSchema{}},
+		{Name: "kind", Type: kind, Default: 1},
+		{Name: "count", Type: &IntegerSchema{Bits: 16, Unsigned: true}, Default: uint64(1)},
+		{Name: "next", Type: &OptionalSchema{Element: item}},
+	}
+	bag := &StructSchema{Name: "Bag"}
+	bag.Fields = []*FieldSchema{
+		{Name: "items", Type: item.List()},
+		{Name: "by_name", Type: &MapSchema{Key: &StringSchema{}, Value: item}},
+	}
+	return (&RegionSchema{Name: "Inventory", Structs: []*StructSchema{item, bag}, Enums: []*EnumSchema{kind}}).Init()
+}
+
+func TestDynamicStruct(t *testing.T) {
+	rs := inventorySchema()
+	item := rs.StructLUT["Item"]
+	region := CreateDynamicRegion(rs)
+	o := region.Allocate("Item").(*DynamicStruct)
+	assert.Equal(t, "", o.Get(item.FieldLUT["name"]))
+	assert.Equal(t, uint32(1), o.Get(item.FieldLUT["kind"]))
+	assert.Equal(t, uint16(1), o.Get(item.FieldLUT["count"]))
+
+	o.Set(item.FieldLUT["name"], "hammer")
+	o.Set(item.FieldLUT["next"], o)
+	assert.Equal(t, "hammer", o.Get(item.FieldLUT["name"]))
+	assert.Equal(t, o, o.Get(item.FieldLUT["next"]))
+	o.Set(item.FieldLUT["next"], nil)
+	assert.Nil(t, o.Get(item.FieldLUT["next"]))
+	assert.Panics(t, func() { o.Set(item.FieldLUT["count"], 3) })
+}
+
+func TestDynamicRoundTrip(t *testing.T) {
+	rs := inventorySchema()
+	item := rs.StructLUT["Item"]
+	bag := rs.StructLUT["Bag"]
+
+	region := CreateDynamicRegion(rs)
+	b := region.Allocate("Bag").(*DynamicStruct)
+	hammer := region.Allocate("Item").(*DynamicStruct)
+	hammer.Set(item.FieldLUT["name"], "hammer")
+	hammer.Set(item.FieldLUT["kind"], uint32(0))
+	apple := region.Allocate("Item").(*DynamicStruct)
+	apple.Set(item.FieldLUT["name"], "apple")
+	apple.Set(item.FieldLUT["count"], uint16(3))
+	apple.Set(item.FieldLUT["next"], hammer)
+	b.Set(bag.FieldLUT["items"], []*DynamicStruct{hammer, apple})
+	b.Set(bag.FieldLUT["by_name"], map[string]*DynamicStruct{"apple": apple})
+
+	encoded, err := region.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := CreateDynamicRegion(rs)
+	assert.NoError(t, decoded.UnmarshalBinary(encoded))
+	assert.Equal(t, 2, len(decoded.Pool(item)))
+	roots := decoded.Roots()
+	assert.Equal(t, 1, len(roots))
+
+	var expected, actual bytes.Buffer
+	DumpText(b, &expected)
+	DumpText(roots[0], &actual)
+	assert.Equal(t, `Bag {
+  items: [
+    item0 = {
+      name: "hammer",
+      kind: tool,
+    },
+    item1 = {
+      name: "apple",
+      count: 3,
+      next: @item0,
+    },
+  ],
+  by_name: [
+    "apple": @item1,
+  ],
+}
+`, expected.String())
+	assert.Equal(t, expected.String(), actual.String())
+}
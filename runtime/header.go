@@ -0,0 +1,168 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Every binary region starts with these bytes.
+const Magic = "ROMY"
+
+// The version of the binary encoding.  Readers reject other versions.
+const FormatVersion = 1
+
+// The number of bytes WriteIndex uses for an index in [0, index_range).
+func indexBytes(index_range int) int {
+	if index_range <= 1 {
+		return 0
+	} else if index_range <= 1<<8 {
+		return 1
+	} else if index_range <= 1<<16 {
+		return 2
+	}
+	return 4
+}
+
+// Describe how values of a type are encoded.  Names are left out, as they do
+// not appear in the binary form.  References to structs are identified by the
+// position of the struct's pool.
+func (r *RegionSchema) layout(t TypeSchema) string {
+	switch t := t.(type) {
+	case *StructSchema:
+		for i, s := range r.Structs {
+			if s == t {
+				return "pool" + strconv.Itoa(i)
+			}
+		}
+		panic(t.Name)
+	case *EnumSchema:
+		return "index" + strconv.Itoa(indexBytes(len(t.Values)))
+	case *UnionSchema:
+		return "tag" + strconv.Itoa(indexBytes(len(t.Arms)))
+	case *OptionalSchema:
+		if u, ok := t.Element.(*UnionSchema); ok {
+			// The tag is shifted up by one, reserving zero for no struct.
+			return "?tag" + strconv.Itoa(indexBytes(len(u.Arms)+1))
+		}
+		return "?" + r.layout(t.Element)
+	case *ListSchema:
+		return "[]" + r.layout(t.Element)
+	case *ArraySchema:
+		return "[" + strconv.Itoa(t.Length) + "]" + r.layout(t.Element)
+	case *MapSchema:
+		return "map[" + r.layout(t.Key) + "]" + r.layout(t.Value)
+	default:
+		return t.CanonicalName()
+	}
+}
+
+// A stable hash of everything in a schema that affects the binary encoding:
+// the region name, and the structs, fields, enums, and unions in declaration
+// order.  Defaults only affect newly allocated structs, so they are not
+// included.
+func (r *RegionSchema) Fingerprint() uint64 {
+	lines := []string{"region " + r.Name}
+	for _, s := range r.Structs {
+		lines = append(lines, "struct "+s.Name)
+		for _, f := range s.Fields {
+			lines = append(lines, "field "+f.Name+" "+f.Type.CanonicalName())
+		}
+	}
+	for _, e := range r.Enums {
+		lines = append(lines, "enum "+e.Name)
+		for _, v := range e.Values {
+			lines = append(lines, "value "+v)
+		}
+	}
+	for _, u := range r.Unions {
+		lines = append(lines, "union "+u.Name)
+		for _, a := range u.Arms {
+			lines = append(lines, "arm "+a.Name)
+		}
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(lines, "\n")))
+	return h.Sum64()
+}
+
+// A stable hash of only the byte layout of a region: the pools in declaration
+// order and the encoding of each field.  Unlike Fingerprint, renames and new
+// enum values that fit in the same number of bytes keep the hash, so it tells
+// whether the bytes of old data can be decoded at all.  CompareRegions
+// describes how the meaning of the data changes.
+func (r *RegionSchema) LayoutFingerprint() uint64 {
+	lines := []string{}
+	for _, s := range r.Structs {
+		fields := make([]string, len(s.Fields))
+		for i, f := range s.Fields {
+			fields[i] = r.layout(f.Type)
+		}
+		lines = append(lines, "struct "+strings.Join(fields, " "))
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(lines, "\n")))
+	return h.Sum64()
+}
+
+// Header identifies the encoding and schema of a binary region.
+type Header struct {
+	Version     int
+	Region      string
+	Fingerprint uint64
+}
+
+// Write the magic bytes, the format version, the region name, and the
+// fingerprint of the schema.
+func WriteHeader(s *Serializer, r *RegionSchema) {
+	for i := 0; i < len(Magic); i++ {
+		s.WriteUint8(Magic[i])
+	}
+	s.WriteCount(FormatVersion)
+	s.WriteString(r.Name)
+	s.WriteUint64(r.Fingerprint())
+}
+
+// Read a header without checking it against a schema, such as to find which
+// region the data is for.
+func ReadHeader(d *Deserializer) (*Header, error) {
+	for i := 0; i < len(Magic); i++ {
+		b, err := d.ReadUint8()
+		if err != nil || b != Magic[i] {
+			return nil, errors.New("not a binary region, the magic bytes are missing")
+		}
+	}
+	version, err := d.ReadCount()
+	if err != nil {
+		return nil, err
+	}
+	if version != FormatVersion {
+		return nil, fmt.Errorf("unsupported format version %d, expected version %d", version, FormatVersion)
+	}
+	region, err := d.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := d.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+	return &Header{Version: version, Region: region, Fingerprint: fingerprint}, nil
+}
+
+// Read a header and check the data was written with the same schema.
+func CheckHeader(d *Deserializer, r *RegionSchema) error {
+	h, err := ReadHeader(d)
+	if err != nil {
+		return err
+	}
+	if h.Region != r.Name {
+		return fmt.Errorf("the data is for region %s, expected region %s", h.Region, r.Name)
+	}
+	if h.Fingerprint != r.Fingerprint() {
+		return fmt.Errorf("the data for region %s was written with a different schema, fingerprint %016x, expected %016x", r.Name, h.Fingerprint, r.Fingerprint())
+	}
+	return nil
+}
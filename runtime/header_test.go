@@ -0,0 +1,101 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	rs := inventorySchema()
+	fingerprint := rs.Fingerprint()
+	assert.Equal(t, fingerprint, inventorySchema().Fingerprint())
+
+	// Defaults do not change the encoding.
+	changed := inventorySchema()
+	changed.StructLUT["Item"].FieldLUT["count"].Default = uint64(2)
+	assert.Equal(t, fingerprint, changed.Fingerprint())
+
+	changed = inventorySchema()
+	changed.StructLUT["Item"].FieldLUT["count"].Type = &IntegerSchema{Bits: 32, Unsigned: true}
+	assert.NotEqual(t, fingerprint, changed.Fingerprint())
+
+	changed = inventorySchema()
+	changed.StructLUT["Item"].FieldLUT["name"].Name = "title"
+	assert.NotEqual(t, fingerprint, changed.Fingerprint())
+
+	changed = inventorySchema()
+	changed.Enums[0].Values = []string{"food", "tool"}
+	assert.NotEqual(t, fingerprint, changed.Fingerprint())
+
+	// Swapping fields of the same type keeps the layout but not the meaning.
+	changed = inventorySchema()
+	item := changed.StructLUT["Item"]
+	item.Fields[0], item.Fields[2] = item.Fields[2], item.Fields[0]
+	item.Fields[0].Type, item.Fields[2].Type = item.Fields[2].Type, item.Fields[0].Type
+	assert.Equal(t, rs.LayoutFingerprint(), changed.LayoutFingerprint())
+	assert.NotEqual(t, fingerprint, changed.Fingerprint())
+}
+
+func TestLayoutFingerprint(t *testing.T) {
+	rs := inventorySchema()
+	layout := rs.LayoutFingerprint()
+
+	changed := inventorySchema()
+	changed.StructLUT["Item"].FieldLUT["count"].Type = &IntegerSchema{Bits: 32, Unsigned: true}
+	assert.NotEqual(t, layout, changed.LayoutFingerprint())
+
+	// Names are not encoded.
+	changed = inventorySchema()
+	changed.StructLUT["Item"].FieldLUT["name"].Name = "title"
+	changed.StructLUT["Bag"].Name = "Sack"
+	assert.Equal(t, layout, changed.LayoutFingerprint())
+	assert.NotEqual(t, rs.Fingerprint(), changed.Fingerprint())
+
+	// A third value still fits in a byte.
+	changed = inventorySchema()
+	changed.Enums[0].Values = append(changed.Enums[0].Values, "junk")
+	assert.Equal(t, layout, changed.LayoutFingerprint())
+
+	// With a single value the index is implicit.
+	changed = inventorySchema()
+	changed.Enums[0].Values = []string{"tool"}
+	assert.NotEqual(t, layout, changed.LayoutFingerprint())
+
+	changed = inventorySchema()
+	changed.Structs[0], changed.Structs[1] = changed.Structs[1], changed.Structs[0]
+	assert.NotEqual(t, layout, changed.LayoutFingerprint())
+}
+
+func TestHeader(t *testing.T) {
+	rs := inventorySchema()
+	s := MakeSerializer()
+	WriteHeader(s, rs)
+	data := s.Data()
+	assert.Equal(t, Magic, string(data[:4]))
+
+	h, err := ReadHeader(MakeDeserializer(data))
+	assert.NoError(t, err)
+	assert.Equal(t, &Header{Version: FormatVersion, Region: "Inventory", Fingerprint: rs.Fingerprint()}, h)
+	assert.NoError(t, CheckHeader(MakeDeserializer(data), rs))
+
+	_, err = ReadHeader(MakeDeserializer([]byte("RO")))
+	assert.EqualError(t, err, "not a binary region, the magic bytes are missing")
+
+	old := append([]byte(Magic), 0)
+	_, err = ReadHeader(MakeDeserializer(old))
+	assert.EqualError(t, err, "unsupported format version 0, expected version 1")
+
+	other := inventorySchema()
+	other.Name = "Other"
+	assert.EqualError(t, CheckHeader(MakeDeserializer(data), other), "the data is for region Inventory, expected region Other")
+
+	changed := inventorySchema()
+	changed.Enums[0].Values = []string{"food", "tool"}
+	err = CheckHeader(MakeDeserializer(data), changed)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "the data for region Inventory was written with a different schema")
+
+	// Regions check the header before decoding.
+	assert.Equal(t, err, CreateDynamicRegion(changed).UnmarshalBinary(data))
+}
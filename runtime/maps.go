@@ -0,0 +1,41 @@
+package runtime
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// Two map entries, or two structs in a pool, have the same key.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// Get the keys of a map in ascending order, as a slice of the map's key type.
+// Maps are serialized in this order so the encoding is deterministic.
+func SortedKeys(m interface{}) interface{} {
+	return sortedKeys(reflect.ValueOf(m)).Interface()
+}
+
+func sortedKeys(m reflect.Value) reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+	result := reflect.MakeSlice(reflect.SliceOf(m.Type().Key()), len(keys), len(keys))
+	for i, k := range keys {
+		result.Index(i).Set(k)
+	}
+	return result
+}
+
+func keyLess(a reflect.Value, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() < b.Uint()
+	default:
+		panic(a.Kind())
+	}
+}
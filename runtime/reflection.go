@@ -0,0 +1,107 @@
+package runtime
+
+import (
+	"reflect"
+)
+
+// The Go type that holds values of a type.  Structs and unions without a
+// generated Go type are held as *DynamicStruct and Struct, and enums without a
+// generated Go type are held as uint32.
+func ReflectionType(t TypeSchema) reflect.Type {
+	return reflectionType(t, false)
+}
+
+// The Go type that holds values of a type in a DynamicRegion.  Generated Go
+// types are ignored, so any schema can be used.
+func DynamicType(t TypeSchema) reflect.Type {
+	return reflectionType(t, true)
+}
+
+func reflectionType(t TypeSchema, dynamic bool) reflect.Type {
+	switch t := t.(type) {
+	case *StructSchema:
+		if dynamic || t.GoType == nil {
+			return reflect.TypeOf((*DynamicStruct)(nil))
+		}
+		return reflect.TypeOf(t.GoType)
+	case *ListSchema:
+		return reflect.SliceOf(reflectionType(t.Element, dynamic))
+	case *ArraySchema:
+		return reflect.ArrayOf(t.Length, reflectionType(t.Element, dynamic))
+	case *BytesSchema:
+		return reflect.TypeOf([]byte{})
+	case *OptionalSchema:
+		return reflectionType(t.Element, dynamic)
+	case *UnionSchema:
+		if dynamic || t.GoType == nil {
+			return reflect.TypeOf((*Struct)(nil)).Elem()
+		}
+		// GoType is a pointer to the interface.
+		return reflect.TypeOf(t.GoType).Elem()
+	case *MapSchema:
+		return reflect.MapOf(reflectionType(t.Key, dynamic), reflectionType(t.Value, dynamic))
+	case *StringSchema:
+		return reflect.TypeOf("")
+	case *BooleanSchema:
+		return reflect.TypeOf(false)
+	case *EnumSchema:
+		if !dynamic && t.GoType != nil {
+			return reflect.TypeOf(t.GoType)
+		}
+		return reflect.TypeOf(uint32(0))
+	case *IntegerSchema:
+		if t.Unsigned {
+			switch t.Bits {
+			case 8:
+				return reflect.TypeOf(uint8(0))
+			case 16:
+				return reflect.TypeOf(uint16(0))
+			case 32:
+				return reflect.TypeOf(uint32(0))
+			case 64:
+				return reflect.TypeOf(uint64(0))
+			}
+		} else {
+			switch t.Bits {
+			case 8:
+				return reflect.TypeOf(int8(0))
+			case 16:
+				return reflect.TypeOf(int16(0))
+			case 32:
+				return reflect.TypeOf(int32(0))
+			case 64:
+				return reflect.TypeOf(int64(0))
+			}
+		}
+		panic(t.CanonicalName())
+	case *FloatSchema:
+		switch t.Bits {
+		case 32:
+			return reflect.TypeOf(float32(0))
+		case 64:
+			return reflect.TypeOf(float64(0))
+		}
+		panic(t.CanonicalName())
+	default:
+		panic(t)
+	}
+}
+
+// The field of a pointer to a generated struct or a DynamicStruct.  The field
+// can be set.
+func StructField(o reflect.Value, f *FieldSchema) reflect.Value {
+	if d, ok := o.Interface().(*DynamicStruct); ok {
+		return d.Field(f)
+	}
+	return o.Elem().FieldByName(f.GoName())
+}
+
+// The structs of a type in a generated region or a DynamicRegion, in the order
+// they were allocated.  Each element is a pointer to a generated struct or a
+// DynamicStruct.
+func RegionPool(r Region, s *StructSchema) reflect.Value {
+	if d, ok := r.(*DynamicRegion); ok {
+		return reflect.ValueOf(d.Pool(s))
+	}
+	return reflect.ValueOf(r).Elem().FieldByName(s.PoolGoName())
+}
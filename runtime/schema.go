@@ -108,7 +108,40 @@ func (s *FloatSchema) CanonicalName() string {
 type FieldSchema struct {
 	Name string
 	Type TypeSchema
-	ID   int
+	// Documentation for generated code.
+	Doc string
+	// The declared default, or nil if the default is the zero value.  Integers
+	// are int64 or uint64, floats are float64, and enum values are the index
+	// of the value.
+	Default interface{}
+	// Other names the field can be set by in data, such as names it had
+	// before it was renamed.
+	Aliases []string
+	// The field should no longer be set in data.
+	Deprecated bool
+	// Checked by Validate, or nil if the field has no constraints.
+	Constraints *Constraints
+	// The field identifies the struct, and is unique in its pool.
+	Key bool
+	ID  int
+}
+
+// Constraints on the value of a field, beyond what its type allows.
+type Constraints struct {
+	// Bounds on numbers, inclusive, or nil for no bound.  Represented like
+	// defaults.
+	Min interface{}
+	Max interface{}
+	// Bounds on the length of strings, in characters.  A maximum of zero is no
+	// bound.
+	MinLength int
+	MaxLength int
+	// A regular expression strings must match in full, or empty.
+	Pattern string
+	// Strings, bytes, lists, and maps must have at least one element.
+	NonEmpty bool
+	// No two structs in the pool have the same value for the field.
+	Unique bool
 }
 
 func (f *FieldSchema) GoName() string {
@@ -135,23 +168,153 @@ func (s *ListSchema) CanonicalName() string {
 	return "[]" + s.Element.CanonicalName()
 }
 
+// ArraySchema is a list with a fixed number of elements.  The length is part of
+// the type, so it is not encoded.
+type ArraySchema struct {
+	Element   TypeSchema
+	Length    int
+	listCache *ListSchema
+}
+
+func (s *ArraySchema) List() *ListSchema {
+	if s.listCache == nil {
+		s.listCache = &ListSchema{Element: s}
+	}
+	return s.listCache
+}
+
+func (s *ArraySchema) CanHold(other TypeSchema) bool {
+	os, ok := other.(*ArraySchema)
+	if !ok {
+		return false
+	}
+	return s.CanonicalName() == os.CanonicalName()
+}
+
+func (s *ArraySchema) CanonicalName() string {
+	return "[" + strconv.Itoa(s.Length) + "]" + s.Element.CanonicalName()
+}
+
+// BytesSchema is an opaque blob of bytes.
+type BytesSchema struct {
+	listCache *ListSchema
+}
+
+func (s *BytesSchema) List() *ListSchema {
+	if s.listCache == nil {
+		s.listCache = &ListSchema{Element: s}
+	}
+	return s.listCache
+}
+
+func (s *BytesSchema) CanHold(other TypeSchema) bool {
+	_, ok := other.(*BytesSchema)
+	return ok
+}
+
+func (s *BytesSchema) CanonicalName() string {
+	return "bytes"
+}
+
+// OptionalSchema is a reference that may be absent.
+type OptionalSchema struct {
+	Element   TypeSchema
+	listCache *ListSchema
+}
+
+func (s *OptionalSchema) List() *ListSchema {
+	if s.listCache == nil {
+		s.listCache = &ListSchema{Element: s}
+	}
+	return s.listCache
+}
+
+func (s *OptionalSchema) CanHold(other TypeSchema) bool {
+	os, ok := other.(*OptionalSchema)
+	if !ok {
+		return false
+	}
+	return s.Element == os.Element
+}
+
+func (s *OptionalSchema) CanonicalName() string {
+	return "?" + s.Element.CanonicalName()
+}
+
+// MapSchema associates keys with values.  Keys are strings, integers, or enums.
+type MapSchema struct {
+	Key       TypeSchema
+	Value     TypeSchema
+	listCache *ListSchema
+}
+
+func (s *MapSchema) List() *ListSchema {
+	if s.listCache == nil {
+		s.listCache = &ListSchema{Element: s}
+	}
+	return s.listCache
+}
+
+func (s *MapSchema) CanHold(other TypeSchema) bool {
+	os, ok := other.(*MapSchema)
+	if !ok {
+		return false
+	}
+	return s.CanonicalName() == os.CanonicalName()
+}
+
+func (s *MapSchema) CanonicalName() string {
+	return "map[" + s.Key.CanonicalName() + "]" + s.Value.CanonicalName()
+}
+
+// Can values of this type be used as map keys?
+func IsKeyType(t TypeSchema) bool {
+	switch t.(type) {
+	case *StringSchema, *IntegerSchema, *EnumSchema:
+		return true
+	default:
+		return false
+	}
+}
+
 type StructSchema struct {
-	Name      string
-	Fields    []*FieldSchema
-	FieldLUT  map[string]*FieldSchema
+	Name string
+	// Documentation for generated code.
+	Doc      string
+	Fields   []*FieldSchema
+	FieldLUT map[string]*FieldSchema
+	// The key field, or nil if the struct does not have one.
+	Key       *FieldSchema
 	listCache *ListSchema
 	GoType    Struct
 }
 
 func (s *StructSchema) Init() *StructSchema {
 	s.FieldLUT = map[string]*FieldSchema{}
+	s.Key = nil
 	for i, f := range s.Fields {
 		f.ID = i
 		s.FieldLUT[f.Name] = f
+		if f.Key && s.Key == nil {
+			s.Key = f
+		}
+	}
+	// Field names take precedence over aliases.
+	for _, f := range s.Fields {
+		for _, a := range f.Aliases {
+			if _, ok := s.FieldLUT[a]; !ok {
+				s.FieldLUT[a] = f
+			}
+		}
 	}
 	return s
 }
 
+// The name of the field holding the struct's pool in a generated region.
+func (s *StructSchema) PoolGoName() string {
+	return names.JoinCamelCase(names.SplitCamelCase(s.Name+"Pool"), true)
+}
+
 func (s *StructSchema) List() *ListSchema {
 	if s.listCache == nil {
 		s.listCache = &ListSchema{Element: s}
@@ -167,10 +330,84 @@ func (s *StructSchema) CanonicalName() string {
 	return s.Name
 }
 
-type RegionSchema struct {
+// EnumSchema is a closed set of named values.  Values are identified by their
+// index.
+type EnumSchema struct {
+	Name      string
+	Values    []string
+	ValueLUT  map[string]int
+	listCache *ListSchema
+	GoType    interface{}
+}
+
+func (s *EnumSchema) Init() *EnumSchema {
+	s.ValueLUT = map[string]int{}
+	for i, v := range s.Values {
+		s.ValueLUT[v] = i
+	}
+	return s
+}
+
+func (s *EnumSchema) List() *ListSchema {
+	if s.listCache == nil {
+		s.listCache = &ListSchema{Element: s}
+	}
+	return s.listCache
+}
+
+func (s *EnumSchema) CanHold(other TypeSchema) bool {
+	return s == other
+}
+
+func (s *EnumSchema) CanonicalName() string {
+	return s.Name
+}
+
+// UnionSchema is a reference to a struct of one of several types, the arms.
+type UnionSchema struct {
 	Name      string
+	Arms      []*StructSchema
+	listCache *ListSchema
+	GoType    interface{}
+}
+
+func (s *UnionSchema) List() *ListSchema {
+	if s.listCache == nil {
+		s.listCache = &ListSchema{Element: s}
+	}
+	return s.listCache
+}
+
+// The index of an arm, or -1 if the struct is not an arm of the union.
+func (s *UnionSchema) Tag(arm *StructSchema) int {
+	for i, a := range s.Arms {
+		if a == arm {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *UnionSchema) CanHold(other TypeSchema) bool {
+	if s == other {
+		return true
+	}
+	arm, ok := other.(*StructSchema)
+	return ok && s.Tag(arm) >= 0
+}
+
+func (s *UnionSchema) CanonicalName() string {
+	return s.Name
+}
+
+type RegionSchema struct {
+	Name string
+	// Documentation for generated code.
+	Doc       string
 	Structs   []*StructSchema
 	StructLUT map[string]*StructSchema
+	Enums     []*EnumSchema
+	Unions    []*UnionSchema
 	GoType    Region
 }
 
@@ -180,5 +417,8 @@ func (r *RegionSchema) Init() *RegionSchema {
 		s.Init()
 		r.StructLUT[s.Name] = s
 	}
+	for _, e := range r.Enums {
+		e.Init()
+	}
 	return r
 }
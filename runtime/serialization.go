@@ -13,6 +13,14 @@ func outOfRange() error {
 	return errors.New("value out of range")
 }
 
+// A required reference was not set.
+func NilReference(field string) error {
+	return errors.New(field + " is required but is nil")
+}
+
+// The index of an absent optional reference.
+const NoIndex = -1
+
 type Serializer struct {
 	data []byte
 }
@@ -104,6 +112,14 @@ func (s *Serializer) WriteIndex(index int, index_range int) error {
 	return nil
 }
 
+// Optional indexes are shifted up by one, reserving zero for NoIndex.
+func (s *Serializer) WriteOptionalIndex(index int, index_range int) error {
+	if index < NoIndex {
+		return outOfRange()
+	}
+	return s.WriteIndex(index+1, index_range+1)
+}
+
 func (s *Serializer) WriteCount(index int) error {
 	if index < 0 || index > math.MaxInt32 {
 		return outOfRange()
@@ -117,6 +133,11 @@ func (s *Serializer) WriteString(value string) {
 	s.data = append(s.data, value...)
 }
 
+func (s *Serializer) WriteBytes(value []byte) {
+	s.WriteCount(len(value))
+	s.data = append(s.data, value...)
+}
+
 type Deserializer struct {
 	data []byte
 }
@@ -254,6 +275,14 @@ func (s *Deserializer) ReadIndex(index_range int) (int, error) {
 	return v, err
 }
 
+func (s *Deserializer) ReadOptionalIndex(index_range int) (int, error) {
+	v, err := s.ReadIndex(index_range + 1)
+	if err != nil {
+		return 0, err
+	}
+	return v - 1, nil
+}
+
 func (s *Deserializer) ReadCount() (int, error) {
 	p, err := s.ReadUvarint()
 	if err != nil {
@@ -279,3 +308,19 @@ func (s *Deserializer) ReadString() (string, error) {
 		return "", endOfData()
 	}
 }
+
+func (s *Deserializer) ReadBytes() ([]byte, error) {
+	l, err := s.ReadCount()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.data) >= l {
+		// Copy so the result does not alias the input.
+		v := make([]byte, l)
+		copy(v, s.data)
+		s.data = s.data[l:]
+		return v, nil
+	} else {
+		return nil, endOfData()
+	}
+}
@@ -320,3 +320,46 @@ func TestString(t *testing.T) {
 	_, err := d.ReadString()
 	assert.NotNil(t, err)
 }
+
+func TestBytes(t *testing.T) {
+	s := MakeSerializer()
+	expected := [][]byte{{0, 1, 255}, {}, {42}}
+	for _, value := range expected {
+		s.WriteBytes(value)
+	}
+	data := s.Data()
+	assert.Equal(t, 4+1+2, len(data))
+	d := MakeDeserializer(data)
+	for _, value := range expected {
+		actual, err := d.ReadBytes()
+		assert.Nil(t, err)
+		assert.Equal(t, value, actual)
+	}
+	_, err := d.ReadBytes()
+	assert.NotNil(t, err)
+
+	// Truncated data.
+	d = MakeDeserializer([]byte{2, 0})
+	_, err = d.ReadBytes()
+	assert.NotNil(t, err)
+}
+
+func TestOptionalIndex(t *testing.T) {
+	s := MakeSerializer()
+	expected := []int{NoIndex, 0, 254, NoIndex}
+	for _, value := range expected {
+		assert.Nil(t, s.WriteOptionalIndex(value, 255))
+	}
+	assert.NotNil(t, s.WriteOptionalIndex(255, 255))
+	assert.NotNil(t, s.WriteOptionalIndex(-2, 255))
+	data := s.Data()
+	assert.Equal(t, len(expected), len(data))
+	d := MakeDeserializer(data)
+	for _, value := range expected {
+		actual, err := d.ReadOptionalIndex(255)
+		assert.Nil(t, err)
+		assert.Equal(t, value, actual)
+	}
+	_, err := d.ReadOptionalIndex(255)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,186 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Violation is a field of a struct that does not satisfy its constraints.
+type Violation struct {
+	// A pointer to a generated struct or a DynamicStruct.
+	Struct interface{}
+	Field  *FieldSchema
+	// For unique fields, an earlier struct in the pool with the same value.
+	Other   interface{}
+	Message string
+}
+
+// ValidationError holds every violation found in a region.
+type ValidationError struct {
+	Violations []*Violation
+}
+
+func (e *ValidationError) Error() string {
+	message := e.Violations[0].Message
+	if more := len(e.Violations) - 1; more > 0 {
+		message += fmt.Sprintf(" (and %d more)", more)
+	}
+	return message
+}
+
+// The regular expression for a pattern constraint, which must match the whole
+// string.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	// Check the pattern alone, so errors do not mention the anchors.
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+type validator struct {
+	patterns   map[string]*regexp.Regexp
+	violations []*Violation
+}
+
+func (v *validator) report(o reflect.Value, s *StructSchema, f *FieldSchema, format string, args ...interface{}) {
+	v.violations = append(v.violations, &Violation{
+		Struct:  o.Interface(),
+		Field:   f,
+		Message: s.Name + "." + f.Name + " " + fmt.Sprintf(format, args...),
+	})
+}
+
+// Describe a value the way it would be written in data.
+func describeValue(value reflect.Value, t TypeSchema) string {
+	switch t := t.(type) {
+	case *StringSchema:
+		return fmt.Sprintf("%#v", value.String())
+	case *EnumSchema:
+		return t.Values[value.Uint()]
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+// Compare a number to a bound represented like a default, returning -1, 0, or
+// 1.
+func compareNumber(value reflect.Value, t TypeSchema, bound interface{}) int {
+	switch t := t.(type) {
+	case *IntegerSchema:
+		if t.Unsigned {
+			a, b := value.Uint(), bound.(uint64)
+			if a < b {
+				return -1
+			} else if a > b {
+				return 1
+			}
+			return 0
+		}
+		a, b := value.Int(), bound.(int64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	case *FloatSchema:
+		a, b := value.Float(), bound.(float64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	default:
+		panic(t)
+	}
+}
+
+func (v *validator) checkField(o reflect.Value, s *StructSchema, f *FieldSchema) {
+	c := f.Constraints
+	value := StructField(o, f)
+	if c.Min != nil && compareNumber(value, f.Type, c.Min) < 0 {
+		v.report(o, s, f, "is %s, less than the minimum %v", describeValue(value, f.Type), c.Min)
+	}
+	if c.Max != nil && compareNumber(value, f.Type, c.Max) > 0 {
+		v.report(o, s, f, "is %s, greater than the maximum %v", describeValue(value, f.Type), c.Max)
+	}
+	if c.MinLength > 0 || c.MaxLength > 0 {
+		length := utf8.RuneCountInString(value.String())
+		if length < c.MinLength {
+			v.report(o, s, f, "has %d characters, fewer than the minimum length %d", length, c.MinLength)
+		}
+		if c.MaxLength > 0 && length > c.MaxLength {
+			v.report(o, s, f, "has %d characters, more than the maximum length %d", length, c.MaxLength)
+		}
+	}
+	if c.Pattern != "" {
+		re, ok := v.patterns[c.Pattern]
+		if !ok {
+			var err error
+			re, err = CompilePattern(c.Pattern)
+			if err != nil {
+				panic(err)
+			}
+			v.patterns[c.Pattern] = re
+		}
+		if !re.MatchString(value.String()) {
+			v.report(o, s, f, "is %s, which does not match the pattern %#v", describeValue(value, f.Type), c.Pattern)
+		}
+	}
+	if c.NonEmpty && value.Len() == 0 {
+		v.report(o, s, f, "is empty")
+	}
+}
+
+func (v *validator) checkUnique(pool reflect.Value, s *StructSchema, f *FieldSchema) {
+	seen := map[interface{}]reflect.Value{}
+	for i := 0; i < pool.Len(); i++ {
+		o := pool.Index(i)
+		value := StructField(o, f)
+		key := value.Interface()
+		if other, ok := seen[key]; ok {
+			v.report(o, s, f, "is %s, which another %s already has", describeValue(value, f.Type), s.Name)
+			v.violations[len(v.violations)-1].Other = other.Interface()
+			continue
+		}
+		seen[key] = o
+	}
+}
+
+// Check every struct in a generated region or a DynamicRegion against the
+// constraints in its schema.  Violations are grouped by pool, in the order the
+// structs were allocated, and duplicate values are reported last.
+func FindViolations(r Region) []*Violation {
+	v := &validator{patterns: map[string]*regexp.Regexp{}}
+	for _, s := range r.Schema().Structs {
+		pool := RegionPool(r, s)
+		for i := 0; i < pool.Len(); i++ {
+			o := pool.Index(i)
+			for _, f := range s.Fields {
+				if f.Constraints != nil {
+					v.checkField(o, s, f)
+				}
+			}
+		}
+		for _, f := range s.Fields {
+			if f.Key || f.Constraints != nil && f.Constraints.Unique {
+				v.checkUnique(pool, s, f)
+			}
+		}
+	}
+	return v.violations
+}
+
+// Check a region against the constraints in its schema.  Returns a
+// *ValidationError if any are violated.
+func Validate(r Region) error {
+	violations := FindViolations(r)
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: violations}
+}
@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func constrainedSchema() *RegionSchema {
+	rs := inventorySchema()
+	item := rs.StructLUT["Item"]
+	item.FieldLUT["name"].Constraints = &Constraints{MinLength: 1, MaxLength: 8, Pattern: "[a-z]+", Unique: true}
+	item.FieldLUT["count"].Constraints = &Constraints{Min: uint64(1), Max: uint64(99)}
+	rs.StructLUT["Bag"].FieldLUT["items"].Constraints = &Constraints{NonEmpty: true}
+	return rs
+}
+
+func TestValidate(t *testing.T) {
+	rs := constrainedSchema()
+	item := rs.StructLUT["Item"]
+	region := CreateDynamicRegion(rs)
+	hammer := region.Allocate("Item").(*DynamicStruct)
+	hammer.Set(item.FieldLUT["name"], "hammer")
+	bag := region.Allocate("Bag").(*DynamicStruct)
+	bag.Set(rs.StructLUT["Bag"].FieldLUT["items"], []*DynamicStruct{hammer})
+	assert.Nil(t, Validate(region))
+
+	bag.Set(rs.StructLUT["Bag"].FieldLUT["items"], []*DynamicStruct{})
+	empty := region.Allocate("Item").(*DynamicStruct)
+	empty.Set(item.FieldLUT["count"], uint16(0))
+	long := region.Allocate("Item").(*DynamicStruct)
+	long.Set(item.FieldLUT["name"], "Sledgehammer")
+	long.Set(item.FieldLUT["count"], uint16(100))
+	again := region.Allocate("Item").(*DynamicStruct)
+	again.Set(item.FieldLUT["name"], "hammer")
+
+	violations := FindViolations(region)
+	messages := []string{}
+	for _, v := range violations {
+		messages = append(messages, v.Message)
+	}
+	assert.Equal(t, []string{
+		"Item.name has 0 characters, fewer than the minimum length 1",
+		`Item.name is "", which does not match the pattern "[a-z]+"`,
+		"Item.count is 0, less than the minimum 1",
+		"Item.name has 12 characters, more than the maximum length 8",
+		`Item.name is "Sledgehammer", which does not match the pattern "[a-z]+"`,
+		"Item.count is 100, greater than the maximum 99",
+		`Item.name is "hammer", which another Item already has`,
+		"Bag.items is empty",
+	}, messages)
+	assert.Equal(t, long, violations[3].Struct)
+	assert.Equal(t, again, violations[6].Struct)
+	assert.Equal(t, hammer, violations[6].Other)
+
+	err := Validate(region)
+	assert.Equal(t, "Item.name has 0 characters, fewer than the minimum length 1 (and 7 more)", err.Error())
+	assert.Equal(t, violations, err.(*ValidationError).Violations)
+}
+
+func TestValidateEnum(t *testing.T) {
+	rs := inventorySchema()
+	item := rs.StructLUT["Item"]
+	item.FieldLUT["kind"].Constraints = &Constraints{Unique: true}
+	region := CreateDynamicRegion(rs)
+	region.Allocate("Item")
+	region.Allocate("Item")
+	assert.Equal(t, "Item.kind is food, which another Item already has", Validate(region).Error())
+}
+
+func TestValidateKeys(t *testing.T) {
+	rs := inventorySchema()
+	item := rs.StructLUT["Item"]
+	item.FieldLUT["name"].Key = true
+	item.Init()
+	assert.Equal(t, item.FieldLUT["name"], item.Key)
+	region := CreateDynamicRegion(rs)
+	region.Allocate("Item").(*DynamicStruct).Set(item.Key, "rope")
+	region.Allocate("Item").(*DynamicStruct).Set(item.Key, "rope")
+	assert.Equal(t, `Item.name is "rope", which another Item already has`, Validate(region).Error())
+}
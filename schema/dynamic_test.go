@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"bytes"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/internal/fixture"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func loadFixtureSchema(t *testing.T) *runtime.RegionSchema {
+	data, err := ioutil.ReadFile("../internal/fixture/fixture.rommy")
+	assert.NoError(t, err)
+	regions, ok := LoadSchema("fixture.rommy", data, parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{}))
+	assert.True(t, ok)
+	return regions[0]
+}
+
+func marshal(t *testing.T, region runtime.Region, text string) []byte {
+	sources := parser.CreateSourceSet()
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(sources, sink)
+	data := []byte(text)
+	info := sources.Add("t", data)
+	node := human.ParseData(info, data, status)
+	_, ok := human.DataToStruct(region, node, nil, status)
+	assert.True(t, ok, sink.Messages())
+	encoded, err := region.(interface {
+		MarshalBinary() ([]byte, error)
+	}).MarshalBinary()
+	assert.NoError(t, err)
+	return encoded
+}
+
+var dynamicData = []string{
+	`Numbers {i8: -1, u8: 200, i64: -3, u64: 4, f32: 1.5, f64: -2.25, ints: [1, 2], floats: [0.5]}`,
+	`Node {name: "root", next: n = Node {name: "n", next: @n}, children: [@n, Node {}], named: ["b": @n, "a": Node {}]}`,
+	`Edge {from: Node {name: "a"}}`,
+	`Creature {element: water, weaknesses: [fire, none], stats: ["speed": 3, "hp": 10], resistances: [earth_quake: 0.5, fire: 2]}`,
+	`Weapon {price: 7}`,
+	`Spell {name: "s", effect: Heal {amount: 3}, bonus: Damage {amount: 1, element: fire}, combo: [Damage {}, Heal {}]}`,
+	`Tileset {palette: [1, 2, 3, 0xffff], mask: x"00ff", corners: [null, Node {}]}`,
+}
+
+func TestDynamicMatchesGenerated(t *testing.T) {
+	// Either a loaded schema or the generated schema can be used.
+	for _, rs := range []*runtime.RegionSchema{loadFixtureSchema(t), fixture.CreateFixtureRegion().Schema()} {
+		for _, text := range dynamicData {
+			expected := marshal(t, fixture.CreateFixtureRegion(), text)
+			actual := marshal(t, runtime.CreateDynamicRegion(rs), text)
+			assert.Equal(t, expected, actual, text)
+		}
+	}
+}
+
+func TestDynamicRegion(t *testing.T) {
+	rs := loadFixtureSchema(t)
+	region := runtime.CreateDynamicRegion(rs)
+	assert.Nil(t, region.Allocate("Nothing"))
+
+	weapon := rs.StructLUT["Weapon"]
+	o := region.Allocate("Weapon").(*runtime.DynamicStruct)
+	assert.Equal(t, weapon, o.Schema())
+	assert.Equal(t, "sword", o.Field(weapon.FieldLUT["name"]).Interface())
+	assert.Equal(t, float32(1.5), o.Field(weapon.FieldLUT["weight"]).Interface())
+	assert.Equal(t, uint32(1), o.Field(weapon.FieldLUT["element"]).Interface())
+	assert.Equal(t, []*runtime.DynamicStruct{o}, region.Pool(weapon))
+
+	// A required reference must be set.
+	edge := region.Allocate("Edge").(*runtime.DynamicStruct)
+	assert.Equal(t, 0, edge.PoolIndex)
+	_, err := region.MarshalBinary()
+	assert.EqualError(t, err, "Edge.from is required but is nil")
+}
+
+func TestDynamicUnmarshal(t *testing.T) {
+	rs := loadFixtureSchema(t)
+	for _, text := range dynamicData {
+		encoded := marshal(t, fixture.CreateFixtureRegion(), text)
+
+		region := runtime.CreateDynamicRegion(rs)
+		assert.NoError(t, region.UnmarshalBinary(encoded))
+		roots := region.Roots()
+		assert.Len(t, roots, 1, text)
+		root := roots[0]
+
+		// The text is the same as for the generated structs.
+		generated := fixture.CreateFixtureRegion()
+		assert.NoError(t, generated.UnmarshalBinary(encoded))
+		pool := reflect.ValueOf(generated).Elem().FieldByName(root.Schema().Name + "Pool")
+		var expected, actual bytes.Buffer
+		runtime.DumpText(pool.Index(root.PoolIndex).Interface().(runtime.RommyStruct), &expected)
+		runtime.DumpText(root, &actual)
+		assert.Equal(t, expected.String(), actual.String())
+
+		// And it can be compiled again.
+		assert.Equal(t, encoded, marshal(t, fixture.CreateFixtureRegion(), actual.String()), actual.String())
+	}
+}
+
+func TestDynamicUnmarshalErrors(t *testing.T) {
+	rs := loadFixtureSchema(t)
+	encoded := marshal(t, runtime.CreateDynamicRegion(rs), `Edge {from: Node {}}`)
+	assert.Error(t, runtime.CreateDynamicRegion(rs).UnmarshalBinary(encoded[:len(encoded)-1]))
+}
+
+func TestDynamicRoots(t *testing.T) {
+	rs := loadFixtureSchema(t)
+	node := rs.StructLUT["Node"]
+	region := runtime.CreateDynamicRegion(rs)
+	a := region.Allocate("Node").(*runtime.DynamicStruct)
+	b := region.Allocate("Node").(*runtime.DynamicStruct)
+	assert.Equal(t, []*runtime.DynamicStruct{a, b}, region.Roots())
+
+	// Referring to itself does not make a struct any less of a root.
+	a.Field(node.FieldLUT["next"]).Set(reflect.ValueOf(a))
+	b.Field(node.FieldLUT["children"]).Set(reflect.ValueOf([]*runtime.DynamicStruct{a}))
+	assert.Equal(t, []*runtime.DynamicStruct{b}, region.Roots())
+}
+
+func TestGeneratedChecksHeader(t *testing.T) {
+	rs := loadFixtureSchema(t)
+	rs.Enums[0].Values = append(rs.Enums[0].Values, "wind")
+	encoded := marshal(t, runtime.CreateDynamicRegion(rs), `Node {}`)
+	err := fixture.CreateFixtureRegion().UnmarshalBinary(encoded)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "the data for region Fixture was written with a different schema")
+}
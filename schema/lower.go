@@ -0,0 +1,201 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"strings"
+)
+
+// The default in the string form used by Field.Default.
+func defaultString(e human.Expr) string {
+	var b bytes.Buffer
+	human.WriteData(e, &b)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Documentation from the doc comments on the lines directly before a
+// declaration, or if there are none, the doc comments after it on the same
+// line.  Leading comments that end the previous line belong to it, unless
+// nothing precedes them in the file.
+func docString(c *parser.Comments, fileStart bool) string {
+	lines := []string{}
+	for i := len(c.Leading) - 1; i >= 0; i-- {
+		comment := c.Leading[i]
+		text, ok := comment.Doc()
+		if !ok || comment.Detached || !comment.Newline && !(fileStart && i == 0) {
+			break
+		}
+		lines = append([]string{text}, lines...)
+	}
+	if len(lines) == 0 {
+		for _, comment := range c.Trailing {
+			if text, ok := comment.Doc(); ok {
+				lines = append(lines, text)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The names in an alias attribute, either a single name or a list of names.
+func aliasNames(e human.Expr) ([]string, bool) {
+	switch e := e.(type) {
+	case *human.Symbol:
+		return []string{e.Raw.Text}, true
+	case *human.String:
+		return []string{e.Value}, true
+	case *human.List:
+		names := []string{}
+		for _, arg := range e.Args {
+			more, ok := aliasNames(arg)
+			if !ok {
+				return nil, false
+			}
+			names = append(names, more...)
+		}
+		return names, true
+	default:
+		return nil, false
+	}
+}
+
+func lowerAttributes(f *FieldDecl, ff *Field, locations *human.Locations, status *parser.Status) bool {
+	all_ok := true
+	for _, a := range f.Attributes {
+		switch a.Name.Text {
+		case "alias":
+			names, ok := aliasNames(a.Value)
+			if !ok {
+				status.Error(a.Name.Loc, "expected alias = name or alias = [name, ...]")
+				all_ok = false
+				continue
+			}
+			ff.Aliases = append(ff.Aliases, names...)
+			locations.SetField(ff, "aliases", a.Name.Loc)
+		case "deprecated", "non_empty", "unique", "key":
+			if a.Value != nil {
+				status.Error(human.Location(a.Value), fmt.Sprintf("%s does not take a value", a.Name.Text))
+				all_ok = false
+				continue
+			}
+			switch a.Name.Text {
+			case "deprecated":
+				ff.Deprecated = true
+			case "non_empty":
+				ff.NonEmpty = true
+			case "unique":
+				ff.Unique = true
+			case "key":
+				ff.Key = true
+			}
+			locations.SetField(ff, a.Name.Text, a.Name.Loc)
+		case "min", "max":
+			switch a.Value.(type) {
+			case *human.Integer, *human.Float:
+			default:
+				status.Error(a.Name.Loc, fmt.Sprintf("expected %s = number", a.Name.Text))
+				all_ok = false
+				continue
+			}
+			if a.Name.Text == "min" {
+				ff.Min = defaultString(a.Value)
+			} else {
+				ff.Max = defaultString(a.Value)
+			}
+			locations.SetField(ff, a.Name.Text, human.Location(a.Value))
+		case "min_length", "max_length":
+			if a.Value == nil {
+				status.Error(a.Name.Loc, fmt.Sprintf("expected %s = count", a.Name.Text))
+				all_ok = false
+				continue
+			}
+			value, ok := human.ConstantValue(a.Value, &runtime.IntegerSchema{Bits: 32}, status)
+			if !ok {
+				all_ok = false
+				continue
+			}
+			if a.Name.Text == "min_length" {
+				ff.MinLength = int32(value.(int64))
+			} else {
+				ff.MaxLength = int32(value.(int64))
+			}
+			locations.SetField(ff, a.Name.Text, human.Location(a.Value))
+		case "pattern":
+			if _, ok := a.Value.(*human.String); !ok {
+				status.Error(a.Name.Loc, "expected pattern = \"regular expression\"")
+				all_ok = false
+				continue
+			}
+			ff.Pattern = a.Value.(*human.String).Value
+			locations.SetField(ff, "pattern", human.Location(a.Value))
+		default:
+			status.Error(a.Name.Loc, fmt.Sprintf("unknown field attribute %#v", a.Name.Text))
+			all_ok = false
+		}
+	}
+	return all_ok
+}
+
+// Lower schema definitions to the same model the data format produces.  Only
+// the syntax is checked, the locations of the declarations are recorded so
+// Resolve can report every other problem.
+func LowerSchemaFile(file *SchemaFile, region *TypeDeclRegion, locations *human.Locations, status *parser.Status) (*Schemas, bool) {
+	all_ok := true
+	schemas := region.AllocateSchemas()
+	for i, r := range file.Regions {
+		rr := region.AllocateRegion()
+		rr.Name = r.Name.Text
+		rr.Doc = docString(&r.Comments, i == 0)
+		locations.SetStruct(rr, r.Name.Loc)
+		for _, d := range r.Decls {
+			switch d := d.(type) {
+			case *StructDecl:
+				s := region.AllocateStruct()
+				s.Name = d.Name.Text
+				s.Doc = docString(&d.Comments, false)
+				locations.SetStruct(s, d.Name.Loc)
+				for _, f := range d.Fields {
+					ff := region.AllocateField()
+					ff.Name = f.Name.Text
+					ff.Type = typeString(f.Type)
+					ff.Doc = docString(&f.Comments, false)
+					locations.SetStruct(ff, f.Name.Loc)
+					locations.SetField(ff, "type", typeLocation(f.Type))
+					if f.Default != nil {
+						ff.Default = defaultString(f.Default)
+						locations.SetField(ff, "default", human.Location(f.Default))
+					}
+					all_ok = lowerAttributes(f, ff, locations, status) && all_ok
+					s.Fields = append(s.Fields, ff)
+				}
+				rr.Struct = append(rr.Struct, s)
+			case *EnumDecl:
+				e := region.AllocateEnum()
+				e.Name = d.Name.Text
+				locations.SetStruct(e, d.Name.Loc)
+				for _, v := range d.Values {
+					e.Values = append(e.Values, v.Name.Text)
+				}
+				rr.Enum = append(rr.Enum, e)
+			case *UnionDecl:
+				u := region.AllocateUnion()
+				u.Name = d.Name.Text
+				locations.SetStruct(u, d.Name.Loc)
+				for i, arm := range d.Arms {
+					if i == 0 {
+						locations.SetField(u, "arms", arm.Name.Loc)
+					}
+					u.Arms = append(u.Arms, arm.Name.Text)
+				}
+				rr.Union = append(rr.Union, u)
+			default:
+				panic(d)
+			}
+		}
+		schemas.Region = append(schemas.Region, rr)
+	}
+	return schemas, all_ok
+}
@@ -3,20 +3,64 @@ package schema
 
 import (
 	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"os"
+	"path/filepath"
 )
 
 //go:generate rommyc schema.rommy --go_out .
 
-func ParseSchema(file string, data []byte) (*TypeDeclRegion, *Schemas, bool) {
+// Schema files with this extension are written in the schema definition
+// language.  Other schema files are written in the data format.
+const DefinitionExtension = ".rschema"
+
+func parseDefinitions(file string, data []byte, status *parser.Status) (*TypeDeclRegion, *Schemas, *human.Locations, bool) {
+	info := status.Sources.Add(file, data)
+	f := ParseSchemaFile(info, data, status)
+	if status.ShouldStop() {
+		return nil, nil, nil, false
+	}
 	region := CreateTypeDeclRegion()
+	locations := human.CreateLocations()
+	result, ok := LowerSchemaFile(f, region, locations, status)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return region, result, locations, true
+}
+
+func parseSchema(file string, data []byte, status *parser.Status) (*TypeDeclRegion, *Schemas, *human.Locations, bool) {
+	if filepath.Ext(file) == DefinitionExtension {
+		return parseDefinitions(file, data, status)
+	}
 
-	generic_result, ok := human.ParseFile(file, data, region)
+	region := CreateTypeDeclRegion()
+
+	generic_result, locations, ok := human.ParseProjectWithLocations(file, data, region, status)
 	if !ok {
-		return nil, nil, false
+		return nil, nil, nil, false
 	}
 	result, ok := generic_result.(*Schemas)
 	if !ok {
-		return nil, nil, false
+		status.Error(locations.Struct(generic_result), "expected a Schemas struct")
+		return nil, nil, nil, false
+	}
+	return region, result, locations, true
+}
+
+func ParseSchema(file string, data []byte) (*TypeDeclRegion, *Schemas, bool) {
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.TextSink{Out: os.Stderr})
+	region, result, _, ok := parseSchema(file, data, status)
+	return region, result, ok
+}
+
+// Parse a schema file and resolve it into runtime schemas, reporting every
+// problem found.
+func LoadSchema(file string, data []byte, status *parser.Status) ([]*runtime.RegionSchema, bool) {
+	_, result, locations, ok := parseSchema(file, data, status)
+	if !ok {
+		return nil, false
 	}
-	return region, result, true
+	return Resolve(result, locations, status)
 }
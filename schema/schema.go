@@ -7,9 +7,21 @@ import (
 )
 
 type Field struct {
-	PoolIndex int
-	Name      string
-	Type      string
+	PoolIndex  int
+	Name       string
+	Type       string
+	Default    string
+	Aliases    []string
+	Deprecated bool
+	Min        string
+	Max        string
+	MinLength  int32
+	MaxLength  int32
+	Pattern    string
+	NonEmpty   bool
+	Unique     bool
+	Key        bool
+	Doc        string
 }
 
 func (s *Field) Schema() *runtime.StructSchema {
@@ -22,6 +34,7 @@ type Struct struct {
 	PoolIndex int
 	Name      string
 	Fields    []*Field
+	Doc       string
 }
 
 func (s *Struct) Schema() *runtime.StructSchema {
@@ -30,10 +43,37 @@ func (s *Struct) Schema() *runtime.StructSchema {
 
 var structSchema = &runtime.StructSchema{Name: "Struct", GoType: (*Struct)(nil)}
 
+type Enum struct {
+	PoolIndex int
+	Name      string
+	Values    []string
+}
+
+func (s *Enum) Schema() *runtime.StructSchema {
+	return enumSchema
+}
+
+var enumSchema = &runtime.StructSchema{Name: "Enum", GoType: (*Enum)(nil)}
+
+type Union struct {
+	PoolIndex int
+	Name      string
+	Arms      []string
+}
+
+func (s *Union) Schema() *runtime.StructSchema {
+	return unionSchema
+}
+
+var unionSchema = &runtime.StructSchema{Name: "Union", GoType: (*Union)(nil)}
+
 type Region struct {
 	PoolIndex int
 	Name      string
 	Struct    []*Struct
+	Enum      []*Enum
+	Union     []*Union
+	Doc       string
 }
 
 func (s *Region) Schema() *runtime.StructSchema {
@@ -56,6 +96,8 @@ var schemasSchema = &runtime.StructSchema{Name: "Schemas", GoType: (*Schemas)(ni
 type TypeDeclRegion struct {
 	FieldPool   []*Field
 	StructPool  []*Struct
+	EnumPool    []*Enum
+	UnionPool   []*Union
 	RegionPool  []*Region
 	SchemasPool []*Schemas
 }
@@ -84,6 +126,20 @@ func (r *TypeDeclRegion) AllocateStruct() *Struct {
 	return o
 }
 
+func (r *TypeDeclRegion) AllocateEnum() *Enum {
+	o := &Enum{}
+	o.PoolIndex = len(r.EnumPool)
+	r.EnumPool = append(r.EnumPool, o)
+	return o
+}
+
+func (r *TypeDeclRegion) AllocateUnion() *Union {
+	o := &Union{}
+	o.PoolIndex = len(r.UnionPool)
+	r.UnionPool = append(r.UnionPool, o)
+	return o
+}
+
 func (r *TypeDeclRegion) AllocateRegion() *Region {
 	o := &Region{}
 	o.PoolIndex = len(r.RegionPool)
@@ -104,6 +160,10 @@ func (r *TypeDeclRegion) Allocate(name string) interface{} {
 		return r.AllocateField()
 	case "Struct":
 		return r.AllocateStruct()
+	case "Enum":
+		return r.AllocateEnum()
+	case "Union":
+		return r.AllocateUnion()
 	case "Region":
 		return r.AllocateRegion()
 	case "Schemas":
@@ -115,6 +175,7 @@ func (r *TypeDeclRegion) Allocate(name string) interface{} {
 func (r *TypeDeclRegion) MarshalBinary() ([]byte, error) {
 	s := runtime.MakeSerializer()
 	var err error
+	runtime.WriteHeader(s, r.Schema())
 	err = s.WriteCount(len(r.FieldPool))
 	if err != nil {
 		return nil, err
@@ -123,6 +184,14 @@ func (r *TypeDeclRegion) MarshalBinary() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = s.WriteCount(len(r.EnumPool))
+	if err != nil {
+		return nil, err
+	}
+	err = s.WriteCount(len(r.UnionPool))
+	if err != nil {
+		return nil, err
+	}
 	err = s.WriteCount(len(r.RegionPool))
 	if err != nil {
 		return nil, err
@@ -134,6 +203,24 @@ func (r *TypeDeclRegion) MarshalBinary() ([]byte, error) {
 	for _, o := range r.FieldPool {
 		s.WriteString(o.Name)
 		s.WriteString(o.Type)
+		s.WriteString(o.Default)
+		err = s.WriteCount(len(o.Aliases))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Aliases {
+			s.WriteString(o0)
+		}
+		s.WriteBool(o.Deprecated)
+		s.WriteString(o.Min)
+		s.WriteString(o.Max)
+		s.WriteInt32(o.MinLength)
+		s.WriteInt32(o.MaxLength)
+		s.WriteString(o.Pattern)
+		s.WriteBool(o.NonEmpty)
+		s.WriteBool(o.Unique)
+		s.WriteBool(o.Key)
+		s.WriteString(o.Doc)
 	}
 	for _, o := range r.StructPool {
 		s.WriteString(o.Name)
@@ -142,11 +229,35 @@ func (r *TypeDeclRegion) MarshalBinary() ([]byte, error) {
 			return nil, err
 		}
 		for _, o0 := range o.Fields {
+			if o0 == nil {
+				return nil, runtime.NilReference("Struct.fields")
+			}
 			err = s.WriteIndex(o0.PoolIndex, len(r.FieldPool))
 			if err != nil {
 				return nil, err
 			}
 		}
+		s.WriteString(o.Doc)
+	}
+	for _, o := range r.EnumPool {
+		s.WriteString(o.Name)
+		err = s.WriteCount(len(o.Values))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Values {
+			s.WriteString(o0)
+		}
+	}
+	for _, o := range r.UnionPool {
+		s.WriteString(o.Name)
+		err = s.WriteCount(len(o.Arms))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Arms {
+			s.WriteString(o0)
+		}
 	}
 	for _, o := range r.RegionPool {
 		s.WriteString(o.Name)
@@ -155,11 +266,41 @@ func (r *TypeDeclRegion) MarshalBinary() ([]byte, error) {
 			return nil, err
 		}
 		for _, o0 := range o.Struct {
+			if o0 == nil {
+				return nil, runtime.NilReference("Region.struct")
+			}
 			err = s.WriteIndex(o0.PoolIndex, len(r.StructPool))
 			if err != nil {
 				return nil, err
 			}
 		}
+		err = s.WriteCount(len(o.Enum))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Enum {
+			if o0 == nil {
+				return nil, runtime.NilReference("Region.enum")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.EnumPool))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = s.WriteCount(len(o.Union))
+		if err != nil {
+			return nil, err
+		}
+		for _, o0 := range o.Union {
+			if o0 == nil {
+				return nil, runtime.NilReference("Region.union")
+			}
+			err = s.WriteIndex(o0.PoolIndex, len(r.UnionPool))
+			if err != nil {
+				return nil, err
+			}
+		}
+		s.WriteString(o.Doc)
 	}
 	for _, o := range r.SchemasPool {
 		err = s.WriteCount(len(o.Region))
@@ -167,6 +308,9 @@ func (r *TypeDeclRegion) MarshalBinary() ([]byte, error) {
 			return nil, err
 		}
 		for _, o0 := range o.Region {
+			if o0 == nil {
+				return nil, runtime.NilReference("Schemas.region")
+			}
 			err = s.WriteIndex(o0.PoolIndex, len(r.RegionPool))
 			if err != nil {
 				return nil, err
@@ -180,6 +324,10 @@ func (r *TypeDeclRegion) UnmarshalBinary(data []byte) error {
 	d := runtime.MakeDeserializer(data)
 	var index int
 	var err error
+	err = runtime.CheckHeader(d, r.Schema())
+	if err != nil {
+		return err
+	}
 	index, err = d.ReadCount()
 	if err != nil {
 		return err
@@ -198,6 +346,20 @@ func (r *TypeDeclRegion) UnmarshalBinary(data []byte) error {
 	if err != nil {
 		return err
 	}
+	for i := 0; i < index; i++ {
+		r.AllocateEnum()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < index; i++ {
+		r.AllocateUnion()
+	}
+	index, err = d.ReadCount()
+	if err != nil {
+		return err
+	}
 	for i := 0; i < index; i++ {
 		r.AllocateRegion()
 	}
@@ -217,6 +379,61 @@ func (r *TypeDeclRegion) UnmarshalBinary(data []byte) error {
 		if err != nil {
 			return err
 		}
+		o.Default, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Aliases = make([]string, index)
+		for i0, _ := range o.Aliases {
+			o.Aliases[i0], err = d.ReadString()
+			if err != nil {
+				return err
+			}
+		}
+		o.Deprecated, err = d.ReadBool()
+		if err != nil {
+			return err
+		}
+		o.Min, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.Max, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.MinLength, err = d.ReadInt32()
+		if err != nil {
+			return err
+		}
+		o.MaxLength, err = d.ReadInt32()
+		if err != nil {
+			return err
+		}
+		o.Pattern, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		o.NonEmpty, err = d.ReadBool()
+		if err != nil {
+			return err
+		}
+		o.Unique, err = d.ReadBool()
+		if err != nil {
+			return err
+		}
+		o.Key, err = d.ReadBool()
+		if err != nil {
+			return err
+		}
+		o.Doc, err = d.ReadString()
+		if err != nil {
+			return err
+		}
 	}
 	for _, o := range r.StructPool {
 		o.Name, err = d.ReadString()
@@ -235,6 +452,44 @@ func (r *TypeDeclRegion) UnmarshalBinary(data []byte) error {
 			}
 			o.Fields[i0] = r.FieldPool[index]
 		}
+		o.Doc, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range r.EnumPool {
+		o.Name, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Values = make([]string, index)
+		for i0, _ := range o.Values {
+			o.Values[i0], err = d.ReadString()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, o := range r.UnionPool {
+		o.Name, err = d.ReadString()
+		if err != nil {
+			return err
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Arms = make([]string, index)
+		for i0, _ := range o.Arms {
+			o.Arms[i0], err = d.ReadString()
+			if err != nil {
+				return err
+			}
+		}
 	}
 	for _, o := range r.RegionPool {
 		o.Name, err = d.ReadString()
@@ -253,6 +508,34 @@ func (r *TypeDeclRegion) UnmarshalBinary(data []byte) error {
 			}
 			o.Struct[i0] = r.StructPool[index]
 		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Enum = make([]*Enum, index)
+		for i0, _ := range o.Enum {
+			index, err = d.ReadIndex(len(r.EnumPool))
+			if err != nil {
+				return err
+			}
+			o.Enum[i0] = r.EnumPool[index]
+		}
+		index, err = d.ReadCount()
+		if err != nil {
+			return err
+		}
+		o.Union = make([]*Union, index)
+		for i0, _ := range o.Union {
+			index, err = d.ReadIndex(len(r.UnionPool))
+			if err != nil {
+				return err
+			}
+			o.Union[i0] = r.UnionPool[index]
+		}
+		o.Doc, err = d.ReadString()
+		if err != nil {
+			return err
+		}
 	}
 	for _, o := range r.SchemasPool {
 		index, err = d.ReadCount()
@@ -276,6 +559,8 @@ type TypeDeclCloner struct {
 	dst        *TypeDeclRegion
 	fieldMap   []*Field
 	structMap  []*Struct
+	enumMap    []*Enum
+	unionMap   []*Union
 	regionMap  []*Region
 	schemasMap []*Schemas
 }
@@ -286,6 +571,8 @@ func CreateTypeDeclCloner(src *TypeDeclRegion, dst *TypeDeclRegion) *TypeDeclClo
 		dst:        dst,
 		fieldMap:   make([]*Field, len(src.FieldPool)),
 		structMap:  make([]*Struct, len(src.StructPool)),
+		enumMap:    make([]*Enum, len(src.EnumPool)),
+		unionMap:   make([]*Union, len(src.UnionPool)),
 		regionMap:  make([]*Region, len(src.RegionPool)),
 		schemasMap: make([]*Schemas, len(src.SchemasPool)),
 	}
@@ -301,6 +588,21 @@ func (c *TypeDeclCloner) CloneField(src *Field) *Field {
 	c.fieldMap[src.PoolIndex] = dst
 	dst.Name = src.Name
 	dst.Type = src.Type
+	dst.Default = src.Default
+	dst.Aliases = make([]string, len(src.Aliases))
+	for i0, _ := range src.Aliases {
+		dst.Aliases[i0] = src.Aliases[i0]
+	}
+	dst.Deprecated = src.Deprecated
+	dst.Min = src.Min
+	dst.Max = src.Max
+	dst.MinLength = src.MinLength
+	dst.MaxLength = src.MaxLength
+	dst.Pattern = src.Pattern
+	dst.NonEmpty = src.NonEmpty
+	dst.Unique = src.Unique
+	dst.Key = src.Key
+	dst.Doc = src.Doc
 	return dst
 }
 
@@ -316,6 +618,37 @@ func (c *TypeDeclCloner) CloneStruct(src *Struct) *Struct {
 	for i0, _ := range src.Fields {
 		dst.Fields[i0] = c.CloneField(src.Fields[i0])
 	}
+	dst.Doc = src.Doc
+	return dst
+}
+
+func (c *TypeDeclCloner) CloneEnum(src *Enum) *Enum {
+	dst := c.enumMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateEnum()
+	c.enumMap[src.PoolIndex] = dst
+	dst.Name = src.Name
+	dst.Values = make([]string, len(src.Values))
+	for i0, _ := range src.Values {
+		dst.Values[i0] = src.Values[i0]
+	}
+	return dst
+}
+
+func (c *TypeDeclCloner) CloneUnion(src *Union) *Union {
+	dst := c.unionMap[src.PoolIndex]
+	if dst != nil {
+		return dst
+	}
+	dst = c.dst.AllocateUnion()
+	c.unionMap[src.PoolIndex] = dst
+	dst.Name = src.Name
+	dst.Arms = make([]string, len(src.Arms))
+	for i0, _ := range src.Arms {
+		dst.Arms[i0] = src.Arms[i0]
+	}
 	return dst
 }
 
@@ -331,6 +664,15 @@ func (c *TypeDeclCloner) CloneRegion(src *Region) *Region {
 	for i0, _ := range src.Struct {
 		dst.Struct[i0] = c.CloneStruct(src.Struct[i0])
 	}
+	dst.Enum = make([]*Enum, len(src.Enum))
+	for i0, _ := range src.Enum {
+		dst.Enum[i0] = c.CloneEnum(src.Enum[i0])
+	}
+	dst.Union = make([]*Union, len(src.Union))
+	for i0, _ := range src.Union {
+		dst.Union[i0] = c.CloneUnion(src.Union[i0])
+	}
+	dst.Doc = src.Doc
 	return dst
 }
 
@@ -348,21 +690,52 @@ func (c *TypeDeclCloner) CloneSchemas(src *Schemas) *Schemas {
 	return dst
 }
 
+// Check the region against the constraints in its schema.
+func (r *TypeDeclRegion) Validate() error {
+	return runtime.Validate(r)
+}
+
 func init() {
 
 	fieldSchema.Fields = []*runtime.FieldSchema{
 		{Name: "name", Type: &runtime.StringSchema{}},
 		{Name: "type", Type: &runtime.StringSchema{}},
+		{Name: "default", Type: &runtime.StringSchema{}},
+		{Name: "aliases", Type: (&runtime.StringSchema{}).List()},
+		{Name: "deprecated", Type: &runtime.BooleanSchema{}},
+		{Name: "min", Type: &runtime.StringSchema{}},
+		{Name: "max", Type: &runtime.StringSchema{}},
+		{Name: "min_length", Type: &runtime.IntegerSchema{Bits: 32, Unsigned: false}},
+		{Name: "max_length", Type: &runtime.IntegerSchema{Bits: 32, Unsigned: false}},
+		{Name: "pattern", Type: &runtime.StringSchema{}},
+		{Name: "non_empty", Type: &runtime.BooleanSchema{}},
+		{Name: "unique", Type: &runtime.BooleanSchema{}},
+		{Name: "key", Type: &runtime.BooleanSchema{}},
+		{Name: "doc", Type: &runtime.StringSchema{}},
 	}
 
 	structSchema.Fields = []*runtime.FieldSchema{
 		{Name: "name", Type: &runtime.StringSchema{}},
 		{Name: "fields", Type: (fieldSchema).List()},
+		{Name: "doc", Type: &runtime.StringSchema{}},
+	}
+
+	enumSchema.Fields = []*runtime.FieldSchema{
+		{Name: "name", Type: &runtime.StringSchema{}},
+		{Name: "values", Type: (&runtime.StringSchema{}).List()},
+	}
+
+	unionSchema.Fields = []*runtime.FieldSchema{
+		{Name: "name", Type: &runtime.StringSchema{}},
+		{Name: "arms", Type: (&runtime.StringSchema{}).List()},
 	}
 
 	regionSchema.Fields = []*runtime.FieldSchema{
 		{Name: "name", Type: &runtime.StringSchema{}},
 		{Name: "struct", Type: (structSchema).List()},
+		{Name: "enum", Type: (enumSchema).List()},
+		{Name: "union", Type: (unionSchema).List()},
+		{Name: "doc", Type: &runtime.StringSchema{}},
 	}
 
 	schemasSchema.Fields = []*runtime.FieldSchema{
@@ -372,6 +745,8 @@ func init() {
 	typeDeclRegionSchema.Structs = []*runtime.StructSchema{
 		fieldSchema,
 		structSchema,
+		enumSchema,
+		unionSchema,
 		regionSchema,
 		schemasSchema,
 	}
@@ -1,23 +1,251 @@
 package schema
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
 	"github.com/ncbray/rommy/runtime"
 )
 
-func getType(types map[string]runtime.TypeSchema, name string) (runtime.TypeSchema, bool) {
-	if len(name) >= 2 && name[0:2] == "[]" {
-		t, ok := getType(types, name[2:])
-		if ok {
-			return t.List(), true
-		} else {
-			return nil, false
+// The index of the ']' that closes the '[' at the start of name, or -1.
+func closingBracket(name string) int {
+	depth := 0
+	for i, c := range name {
+		switch c {
+		case '[':
+			depth += 1
+		case ']':
+			depth -= 1
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Resolve a type in the string form used by Field.Type.  Returns a description
+// of the problem if it does not resolve.
+func getType(types map[string]runtime.TypeSchema, name string) (runtime.TypeSchema, string) {
+	if strings.HasPrefix(name, "[]") {
+		t, problem := getType(types, name[2:])
+		if problem != "" {
+			return nil, problem
 		}
+		return t.List(), ""
+	}
+	if strings.HasPrefix(name, "[") {
+		end := closingBracket(name)
+		if end < 0 {
+			return nil, fmt.Sprintf("cannot resolve type %#v", name)
+		}
+		length, err := strconv.Atoi(name[1:end])
+		if err != nil || length <= 0 {
+			return nil, fmt.Sprintf("invalid array length %s", name[1:end])
+		}
+		t, problem := getType(types, name[end+1:])
+		if problem != "" {
+			return nil, problem
+		}
+		return &runtime.ArraySchema{Element: t, Length: length}, ""
+	}
+	if strings.HasPrefix(name, "?") {
+		t, problem := getType(types, name[1:])
+		if problem != "" {
+			return nil, problem
+		}
+		switch t.(type) {
+		case *runtime.StructSchema, *runtime.UnionSchema:
+			return &runtime.OptionalSchema{Element: t}, ""
+		default:
+			return nil, fmt.Sprintf("cannot make %s optional, only references may be absent", name[1:])
+		}
+	}
+	if strings.HasPrefix(name, "map[") {
+		end := closingBracket(name[3:])
+		if end < 0 {
+			return nil, fmt.Sprintf("cannot resolve type %#v", name)
+		}
+		end += 3
+		k, problem := getType(types, name[4:end])
+		if problem != "" {
+			return nil, problem
+		}
+		if !runtime.IsKeyType(k) {
+			return nil, fmt.Sprintf("cannot use %s as a map key", name[4:end])
+		}
+		v, problem := getType(types, name[end+1:])
+		if problem != "" {
+			return nil, problem
+		}
+		return &runtime.MapSchema{Key: k, Value: v}, ""
 	}
 	t, ok := types[name]
-	return t, ok
+	if !ok {
+		return nil, fmt.Sprintf("cannot resolve type %#v", name)
+	}
+	return t, ""
 }
 
-func Resolve(schemas *Schemas) []*runtime.RegionSchema {
+func builtinTypes() map[string]runtime.TypeSchema {
+	types := map[string]runtime.TypeSchema{
+		"string": &runtime.StringSchema{},
+		"bool":   &runtime.BooleanSchema{},
+		"bytes":  &runtime.BytesSchema{},
+	}
+	for _, unsigned := range []bool{false, true} {
+		for _, bits := range []uint8{8, 16, 32, 64} {
+			t := &runtime.IntegerSchema{Bits: bits, Unsigned: unsigned}
+			types[t.CanonicalName()] = t
+		}
+	}
+
+	for _, bits := range []uint8{32, 64} {
+		t := &runtime.FloatSchema{Bits: bits}
+		types[t.CanonicalName()] = t
+	}
+	return types
+}
+
+// Evaluate the literal text of a default.  The text is not a source file, so
+// rather than reporting errors, the first message is returned.
+func parseDefault(text string, t runtime.TypeSchema) (interface{}, string) {
+	sources := parser.CreateSourceSet()
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(sources, sink)
+	data := []byte(text)
+	info := sources.Add("default", data)
+	value, ok := human.ParseConstant(info, data, t, status)
+	if !ok {
+		if len(sink.Diagnostics) == 0 {
+			return nil, "not a valid literal"
+		}
+		return nil, sink.Diagnostics[0].Message
+	}
+	return value, ""
+}
+
+// Check the constraints on a field can apply to its type.  Returns nil if the
+// field has no constraints.
+func resolveConstraints(f *Field, ft runtime.TypeSchema, locations *human.Locations, status *parser.Status) (*runtime.Constraints, bool) {
+	c := &runtime.Constraints{
+		MinLength: int(f.MinLength),
+		MaxLength: int(f.MaxLength),
+		Pattern:   f.Pattern,
+		NonEmpty:  f.NonEmpty,
+		Unique:    f.Unique,
+	}
+	if *c == (runtime.Constraints{}) && f.Min == "" && f.Max == "" {
+		return nil, true
+	}
+	all_ok := true
+	invalid := func(attr string, message string) {
+		status.Error(locations.Field(f, attr), fmt.Sprintf("%s cannot apply to field %s of type %s, %s", attr, f.Name, ft.CanonicalName(), message))
+		all_ok = false
+	}
+	bound := func(attr string, text string) interface{} {
+		if text == "" {
+			return nil
+		}
+		switch ft.(type) {
+		case *runtime.IntegerSchema, *runtime.FloatSchema:
+		default:
+			invalid(attr, "expected a number")
+			return nil
+		}
+		value, problem := parseDefault(text, ft)
+		if problem != "" {
+			status.Error(locations.Field(f, attr), fmt.Sprintf("invalid %s %s for type %s, %s", attr, text, ft.CanonicalName(), problem))
+			all_ok = false
+		}
+		return value
+	}
+	c.Min = bound("min", f.Min)
+	c.Max = bound("max", f.Max)
+	if c.Min != nil && c.Max != nil && boundGreater(c.Min, c.Max) {
+		status.Error(locations.Field(f, "min"), fmt.Sprintf("the minimum %s of field %s is greater than the maximum %s", f.Min, f.Name, f.Max))
+		all_ok = false
+	}
+
+	_, is_string := ft.(*runtime.StringSchema)
+	if c.MinLength < 0 {
+		status.Error(locations.Field(f, "min_length"), fmt.Sprintf("invalid min_length %d, cannot be negative", c.MinLength))
+		all_ok = false
+	} else if c.MinLength > 0 && !is_string {
+		invalid("min_length", "expected a string")
+	}
+	if c.MaxLength < 0 {
+		status.Error(locations.Field(f, "max_length"), fmt.Sprintf("invalid max_length %d, cannot be negative", c.MaxLength))
+		all_ok = false
+	} else if c.MaxLength > 0 && !is_string {
+		invalid("max_length", "expected a string")
+	}
+	if c.MaxLength > 0 && c.MinLength > c.MaxLength {
+		status.Error(locations.Field(f, "min_length"), fmt.Sprintf("the minimum length %d of field %s is greater than the maximum length %d", c.MinLength, f.Name, c.MaxLength))
+		all_ok = false
+	}
+	if c.Pattern != "" {
+		if !is_string {
+			invalid("pattern", "expected a string")
+		} else if _, err := runtime.CompilePattern(c.Pattern); err != nil {
+			status.Error(locations.Field(f, "pattern"), fmt.Sprintf("invalid pattern %#v, %s", c.Pattern, err))
+			all_ok = false
+		}
+	}
+	if c.NonEmpty {
+		switch ft.(type) {
+		case *runtime.StringSchema, *runtime.BytesSchema, *runtime.ListSchema, *runtime.MapSchema:
+		default:
+			invalid("non_empty", "expected a string, bytes, list, or map")
+		}
+	}
+	if c.Unique {
+		switch ft.(type) {
+		case *runtime.StringSchema, *runtime.IntegerSchema, *runtime.FloatSchema, *runtime.EnumSchema:
+		default:
+			invalid("unique", "expected a string, number, or enum")
+		}
+	}
+	return c, all_ok
+}
+
+// Is bound a greater than bound b?  Both have the same type, represented like
+// defaults.
+func boundGreater(a interface{}, b interface{}) bool {
+	switch a := a.(type) {
+	case int64:
+		return a > b.(int64)
+	case uint64:
+		return a > b.(uint64)
+	case float64:
+		return a > b.(float64)
+	default:
+		panic(a)
+	}
+}
+
+// The names of the members of a generated Go region, other than key lookups,
+// and what they are.
+func regionMembers(r *runtime.RegionSchema) map[string]string {
+	members := map[string]string{}
+	for _, name := range []string{"Schema", "Allocate", "Validate", "MarshalBinary", "UnmarshalBinary", "Reindex"} {
+		members[name] = "the method " + name
+	}
+	for _, s := range r.Structs {
+		members["Allocate"+s.Name] = "the method Allocate" + s.Name
+		members["index"+s.Name] = "the method index" + s.Name
+		members["Set"+s.Name+"Key"] = "the method Set" + s.Name + "Key"
+		members[s.PoolGoName()] = "the field " + s.PoolGoName()
+	}
+	return members
+}
+
+// Resolve type names and build the runtime schemas.  Problems are reported to
+// status, at the locations where the declarations were defined, if known.
+func Resolve(schemas *Schemas, locations *human.Locations, status *parser.Status) ([]*runtime.RegionSchema, bool) {
 	type structWork struct {
 		parsed *Struct
 		built  *runtime.StructSchema
@@ -30,39 +258,103 @@ func Resolve(schemas *Schemas) []*runtime.RegionSchema {
 		struct_work []structWork
 	}
 
+	all_ok := true
 	region_work := []regionWork{}
+	builtins := builtinTypes()
 
 	// Index
+	regions := map[string]bool{}
 	for _, r := range schemas.Region {
+		if regions[r.Name] {
+			status.Error(locations.Field(r, "name"), fmt.Sprintf("region %#v is already defined", r.Name))
+			all_ok = false
+		}
+		regions[r.Name] = true
+
 		rr := &runtime.RegionSchema{
 			Name: r.Name,
+			Doc:  r.Doc,
 		}
 
-		types := map[string]runtime.TypeSchema{
-			"string": &runtime.StringSchema{},
-			"bool":   &runtime.BooleanSchema{},
-		}
-		for _, unsigned := range []bool{false, true} {
-			for _, bits := range []uint8{8, 16, 32, 64} {
-				t := &runtime.IntegerSchema{Bits: bits, Unsigned: unsigned}
-				types[t.CanonicalName()] = t
+		types := builtinTypes()
+		decls := map[string]interface{}{}
+		declare := func(decl interface{}, name string, t runtime.TypeSchema) {
+			loc := locations.Field(decl, "name")
+			if builtins[name] != nil {
+				status.Error(loc, fmt.Sprintf("cannot redefine built-in type %#v", name))
+				all_ok = false
+			} else if previous, ok := decls[name]; ok {
+				status.Error(loc, fmt.Sprintf("type %#v is already defined", name), parser.Related{Loc: locations.Field(previous, "name"), Message: "previous definition"})
+				all_ok = false
+			} else {
+				types[name] = t
+				decls[name] = decl
 			}
 		}
 
-		for _, bits := range []uint8{32, 64} {
-			t := &runtime.FloatSchema{Bits: bits}
-			types[t.CanonicalName()] = t
+		for _, e := range r.Enum {
+			ee := &runtime.EnumSchema{
+				Name:   e.Name,
+				Values: e.Values,
+			}
+			if len(e.Values) == 0 {
+				// There is no value a field of this type could hold.
+				status.Error(locations.Field(e, "name"), fmt.Sprintf("enum %s has no values", e.Name))
+				all_ok = false
+			}
+			seen := map[string]bool{}
+			for _, v := range e.Values {
+				if seen[v] {
+					status.Error(locations.Field(e, "values"), fmt.Sprintf("enum %s has more than one value named %#v", e.Name, v))
+					all_ok = false
+				}
+				seen[v] = true
+			}
+			// Defaults may refer to the values.
+			ee.Init()
+			declare(e, ee.Name, ee)
+			rr.Enums = append(rr.Enums, ee)
 		}
 
 		struct_work := []structWork{}
 		for _, s := range r.Struct {
 			ss := &runtime.StructSchema{
 				Name: s.Name,
+				Doc:  s.Doc,
 			}
 			struct_work = append(struct_work, structWork{parsed: s, built: ss})
-			types[ss.Name] = ss
+			declare(s, ss.Name, ss)
 			rr.Structs = append(rr.Structs, ss)
 		}
+
+		for _, u := range r.Union {
+			uu := &runtime.UnionSchema{
+				Name: u.Name,
+			}
+			if len(u.Arms) == 0 {
+				// There is no struct a field of this type could hold.
+				status.Error(locations.Field(u, "name"), fmt.Sprintf("union %s has no arms", u.Name))
+				all_ok = false
+			}
+			seen := map[string]bool{}
+			for _, arm := range u.Arms {
+				s, ok := types[arm].(*runtime.StructSchema)
+				if !ok {
+					status.Error(locations.Field(u, "arms"), fmt.Sprintf("cannot use %#v as an arm of %s, expected a struct", arm, u.Name))
+					all_ok = false
+					continue
+				}
+				if seen[arm] {
+					status.Error(locations.Field(u, "arms"), fmt.Sprintf("%#v is already an arm of %s", arm, u.Name))
+					all_ok = false
+					continue
+				}
+				seen[arm] = true
+				uu.Arms = append(uu.Arms, s)
+			}
+			declare(u, uu.Name, uu)
+			rr.Unions = append(rr.Unions, uu)
+		}
 		region_work = append(region_work, regionWork{parsed: r, built: rr, types: types, struct_work: struct_work})
 	}
 
@@ -71,18 +363,96 @@ func Resolve(schemas *Schemas) []*runtime.RegionSchema {
 		for _, sw := range rw.struct_work {
 			s := sw.parsed
 			ss := sw.built
+			fields := map[string]*Field{}
 			for _, f := range s.Fields {
-				ft, ok := getType(rw.types, f.Type)
+				if previous, ok := fields[f.Name]; ok {
+					status.Error(locations.Field(f, "name"), fmt.Sprintf("%s has more than one field named %#v", s.Name, f.Name), parser.Related{Loc: locations.Field(previous, "name"), Message: "previous definition"})
+					all_ok = false
+				} else {
+					fields[f.Name] = f
+				}
+
+				ft, problem := getType(rw.types, f.Type)
+				if problem != "" {
+					status.Error(locations.Field(f, "type"), problem)
+					all_ok = false
+					continue
+				}
+				var def interface{}
+				if f.Default != "" {
+					var problem string
+					def, problem = parseDefault(f.Default, ft)
+					if problem != "" {
+						status.Error(locations.Field(f, "default"), fmt.Sprintf("invalid default %s for type %s, %s", f.Default, ft.CanonicalName(), problem))
+						all_ok = false
+						continue
+					}
+				}
+				if f.Key {
+					switch ft.(type) {
+					case *runtime.StringSchema, *runtime.IntegerSchema:
+					default:
+						status.Error(locations.Field(f, "key"), fmt.Sprintf("cannot use field %s of type %s as a key, expected a string or integer", f.Name, ft.CanonicalName()))
+						all_ok = false
+						continue
+					}
+				}
+				constraints, ok := resolveConstraints(f, ft, locations, status)
 				if !ok {
-					panic("cannot resolve type " + f.Type)
+					all_ok = false
+					continue
 				}
 				ss.Fields = append(ss.Fields, &runtime.FieldSchema{
-					Name: f.Name,
-					Type: ft,
+					Name:        f.Name,
+					Type:        ft,
+					Doc:         f.Doc,
+					Default:     def,
+					Aliases:     f.Aliases,
+					Deprecated:  f.Deprecated,
+					Constraints: constraints,
+					Key:         f.Key,
 				})
 			}
+			// Aliases share the names of fields.
+			for _, f := range s.Fields {
+				for _, a := range f.Aliases {
+					if previous, ok := fields[a]; ok {
+						attr := "name"
+						if previous.Name != a {
+							attr = "aliases"
+						}
+						status.Error(locations.Field(f, "aliases"), fmt.Sprintf("%s has more than one field named %#v", s.Name, a), parser.Related{Loc: locations.Field(previous, attr), Message: "previous definition"})
+						all_ok = false
+					} else {
+						fields[a] = f
+					}
+				}
+			}
+			var key *Field
+			for _, f := range s.Fields {
+				if !f.Key {
+					continue
+				}
+				if key != nil {
+					status.Error(locations.Field(f, "key"), fmt.Sprintf("%s has more than one key field", s.Name), parser.Related{Loc: locations.Field(key, "key"), Message: "previous key"})
+					all_ok = false
+					continue
+				}
+				key = f
+			}
+			// The Go generator names the lookup method on the region after
+			// the struct.
+			if key != nil {
+				if member, ok := regionMembers(rw.built)[s.Name]; ok {
+					status.Error(locations.Field(key, "key"), fmt.Sprintf("%s cannot have a key field, the lookup method would conflict with %s on the generated region", s.Name, member))
+					all_ok = false
+				}
+			}
 		}
 	}
+	if !all_ok {
+		return nil, false
+	}
 
 	// Finalize.
 	region_list := make([]*runtime.RegionSchema, len(region_work))
@@ -91,5 +461,5 @@ func Resolve(schemas *Schemas) []*runtime.RegionSchema {
 		region_list[i] = rw.built
 	}
 
-	return region_list
+	return region_list, true
 }
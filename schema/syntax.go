@@ -0,0 +1,184 @@
+package schema
+
+import (
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+)
+
+// SchemaFile is a parsed schema definition file, for example:
+//
+//	region TypeDecl {
+//	  struct Field {
+//	    name: string;
+//	    type: string;
+//	    default: string = "";
+//	  }
+//	}
+type SchemaFile struct {
+	Regions []*RegionDecl
+	// Comments after the last region.
+	Dangling []*parser.Comment
+}
+
+type RegionDecl struct {
+	parser.Comments
+	Name  parser.SourceString
+	Decls []Decl
+	// Comments after the last declaration.
+	Dangling []*parser.Comment
+}
+
+// Decl is a type declared inside a region.
+type Decl interface {
+	isDecl()
+	Attached() *parser.Comments
+}
+
+type StructDecl struct {
+	parser.Comments
+	Name   parser.SourceString
+	Fields []*FieldDecl
+	// Comments after the last field.
+	Dangling []*parser.Comment
+}
+
+func (node *StructDecl) isDecl() {
+}
+
+// EnumDecl is a closed set of values, for example:
+//
+//	enum Element { fire, water, }
+type EnumDecl struct {
+	parser.Comments
+	Name   parser.SourceString
+	Values []*EnumValueDecl
+	// Comments after the last value.
+	Dangling []*parser.Comment
+}
+
+func (node *EnumDecl) isDecl() {
+}
+
+type EnumValueDecl struct {
+	parser.Comments
+	Name parser.SourceString
+}
+
+// UnionDecl is a reference to one of several structs, for example:
+//
+//	union Effect { Heal, Damage, }
+type UnionDecl struct {
+	parser.Comments
+	Name parser.SourceString
+	Arms []*UnionArmDecl
+	// Comments after the last arm.
+	Dangling []*parser.Comment
+}
+
+func (node *UnionDecl) isDecl() {
+}
+
+type UnionArmDecl struct {
+	parser.Comments
+	Name parser.SourceString
+}
+
+type FieldDecl struct {
+	parser.Comments
+	Name parser.SourceString
+	Type TypeExpr
+	// A literal in the data format, or nil.
+	Default    human.Expr
+	Attributes []*Attribute
+}
+
+// Attribute qualifies a field, for example:
+//
+//	hp: int32 [alias = health, deprecated];
+type Attribute struct {
+	Name parser.SourceString
+	// An expression in the data format, or nil.
+	Value human.Expr
+}
+
+type TypeExpr interface {
+	isTypeExpr()
+}
+
+type TypeName struct {
+	Raw parser.SourceString
+}
+
+func (node *TypeName) isTypeExpr() {
+}
+
+type ListType struct {
+	Loc     parser.Location
+	Element TypeExpr
+}
+
+func (node *ListType) isTypeExpr() {
+}
+
+// ArrayType is a list with a fixed number of elements.
+type ArrayType struct {
+	Loc     parser.Location
+	Length  parser.SourceString
+	Element TypeExpr
+}
+
+func (node *ArrayType) isTypeExpr() {
+}
+
+type OptionalType struct {
+	Loc     parser.Location
+	Element TypeExpr
+}
+
+func (node *OptionalType) isTypeExpr() {
+}
+
+type MapType struct {
+	Loc   parser.Location
+	Key   TypeExpr
+	Value TypeExpr
+}
+
+func (node *MapType) isTypeExpr() {
+}
+
+// The location to report problems with a type.
+func typeLocation(t TypeExpr) parser.Location {
+	switch t := t.(type) {
+	case *TypeName:
+		return t.Raw.Loc
+	case *ListType:
+		return t.Loc
+	case *ArrayType:
+		return t.Loc
+	case *OptionalType:
+		return t.Loc
+	case *MapType:
+		return t.Loc
+	default:
+		panic(t)
+	}
+}
+
+// The type in the string form used by Field.Type.
+func typeString(t TypeExpr) string {
+	switch t := t.(type) {
+	case *TypeName:
+		return t.Raw.Text
+	case *ListType:
+		return "[]" + typeString(t.Element)
+	case *ArrayType:
+		return "[" + t.Length.Text + "]" + typeString(t.Element)
+	case *OptionalType:
+		return "?" + typeString(t.Element)
+	case *MapType:
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
+	default:
+		panic(t)
+	}
+}
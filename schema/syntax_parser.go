@@ -0,0 +1,322 @@
+package schema
+
+import (
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+)
+
+func s(state *parser.RuneParserState) {
+	parser.SkipComments(state)
+}
+
+func keyword(state *parser.RuneParserState, text string) bool {
+	name, ok := parser.Identifier(state)
+	return ok && name.Text == text
+}
+
+// Parse a sequence of elements, attaching the surrounding comments to each.
+// Returns the comments following the last element.
+func parseSequence(state *parser.RuneParserState, element func(state *parser.RuneParserState) (*parser.Comments, bool)) []*parser.Comment {
+	for {
+		begin := state.Position()
+		leading := parser.SkipComments(state)
+		c, ok := element(state)
+		if !ok {
+			state.Recover(begin)
+			break
+		}
+		c.Leading = leading
+		c.Trailing = parser.TrailingComments(state)
+	}
+	return parser.SkipComments(state)
+}
+
+func parseType(state *parser.RuneParserState) (TypeExpr, bool) {
+	begin := state.Position()
+	if parser.Punc(state, '[') {
+		loc := state.Slice(begin).Loc
+		s(state)
+		lengthBegin := state.Position()
+		for state.IsDigit() {
+			state.GetNext()
+		}
+		length := state.Slice(lengthBegin)
+		s(state)
+		if !parser.Punc(state, ']') {
+			return nil, false
+		}
+		s(state)
+		element, ok := parseType(state)
+		if !ok {
+			return nil, false
+		}
+		if length.Text != "" {
+			return &ArrayType{Loc: loc, Length: length, Element: element}, true
+		}
+		return &ListType{Loc: loc, Element: element}, true
+	}
+	if parser.Punc(state, '?') {
+		loc := state.Slice(begin).Loc
+		s(state)
+		element, ok := parseType(state)
+		if !ok {
+			return nil, false
+		}
+		return &OptionalType{Loc: loc, Element: element}, true
+	}
+	name, ok := parser.Identifier(state)
+	if !ok {
+		return nil, false
+	}
+	if name.Text == "map" {
+		s(state)
+		if !parser.Punc(state, '[') {
+			return nil, false
+		}
+		s(state)
+		key, ok := parseType(state)
+		if !ok {
+			return nil, false
+		}
+		s(state)
+		if !parser.Punc(state, ']') {
+			return nil, false
+		}
+		s(state)
+		value, ok := parseType(state)
+		if !ok {
+			return nil, false
+		}
+		return &MapType{Loc: name.Loc, Key: key, Value: value}, true
+	}
+	return &TypeName{Raw: name}, true
+}
+
+func parseField(state *parser.RuneParserState) (*FieldDecl, bool) {
+	name, ok := parser.Identifier(state)
+	if !ok {
+		state.Expected("field name")
+		return nil, false
+	}
+	s(state)
+	if !parser.Punc(state, ':') {
+		state.Expected("':' after field name")
+		return nil, false
+	}
+	s(state)
+	t, ok := parseType(state)
+	if !ok {
+		state.Expected("type")
+		return nil, false
+	}
+	s(state)
+	var def human.Expr
+	if parser.Punc(state, '=') {
+		s(state)
+		def, ok = human.ParseExpr(state)
+		if !ok {
+			return nil, false
+		}
+		s(state)
+	}
+	var attrs []*Attribute
+	if parser.Punc(state, '[') {
+		attrs, ok = parseAttributes(state)
+		if !ok {
+			return nil, false
+		}
+		s(state)
+	}
+	if !parser.Punc(state, ';') {
+		state.Expected("';' after field")
+		return nil, false
+	}
+	return &FieldDecl{Name: name, Type: t, Default: def, Attributes: attrs}, true
+}
+
+// Parse the attributes of a field, after the opening bracket.
+func parseAttributes(state *parser.RuneParserState) ([]*Attribute, bool) {
+	attrs := []*Attribute{}
+	for {
+		s(state)
+		if parser.Punc(state, ']') {
+			return attrs, true
+		}
+		name, ok := parser.Identifier(state)
+		if !ok {
+			state.Expected("attribute name")
+			return nil, false
+		}
+		attr := &Attribute{Name: name}
+		s(state)
+		if parser.Punc(state, '=') {
+			s(state)
+			attr.Value, ok = human.ParseExpr(state)
+			if !ok {
+				return nil, false
+			}
+			s(state)
+		}
+		attrs = append(attrs, attr)
+		if parser.Punc(state, ',') {
+			continue
+		}
+		if !parser.Punc(state, ']') {
+			state.Expected("',' or ']' after attribute")
+			return nil, false
+		}
+		return attrs, true
+	}
+}
+
+func parseStructDecl(state *parser.RuneParserState) (*StructDecl, bool) {
+	if !keyword(state, "struct") {
+		return nil, false
+	}
+	s(state)
+	name, ok := parser.Identifier(state)
+	if !ok {
+		return nil, false
+	}
+	s(state)
+	if !parser.Punc(state, '{') {
+		return nil, false
+	}
+	node := &StructDecl{Name: name}
+	node.Dangling = parseSequence(state, func(state *parser.RuneParserState) (*parser.Comments, bool) {
+		f, ok := parseField(state)
+		if !ok {
+			return nil, false
+		}
+		node.Fields = append(node.Fields, f)
+		return &f.Comments, true
+	})
+	if !parser.Punc(state, '}') {
+		state.Expected("'}' to end struct")
+		return nil, false
+	}
+	return node, true
+}
+
+func parseEnumDecl(state *parser.RuneParserState) (*EnumDecl, bool) {
+	if !keyword(state, "enum") {
+		return nil, false
+	}
+	s(state)
+	name, ok := parser.Identifier(state)
+	if !ok {
+		return nil, false
+	}
+	s(state)
+	if !parser.Punc(state, '{') {
+		return nil, false
+	}
+	node := &EnumDecl{Name: name}
+	node.Dangling = parser.CommaSeparated(state, func(state *parser.RuneParserState) (*parser.Comments, bool) {
+		name, ok := parser.Identifier(state)
+		if !ok {
+			return nil, false
+		}
+		v := &EnumValueDecl{Name: name}
+		node.Values = append(node.Values, v)
+		return &v.Comments, true
+	})
+	if !parser.Punc(state, '}') {
+		return nil, false
+	}
+	return node, true
+}
+
+func parseUnionDecl(state *parser.RuneParserState) (*UnionDecl, bool) {
+	if !keyword(state, "union") {
+		return nil, false
+	}
+	s(state)
+	name, ok := parser.Identifier(state)
+	if !ok {
+		return nil, false
+	}
+	s(state)
+	if !parser.Punc(state, '{') {
+		return nil, false
+	}
+	node := &UnionDecl{Name: name}
+	node.Dangling = parser.CommaSeparated(state, func(state *parser.RuneParserState) (*parser.Comments, bool) {
+		name, ok := parser.Identifier(state)
+		if !ok {
+			return nil, false
+		}
+		arm := &UnionArmDecl{Name: name}
+		node.Arms = append(node.Arms, arm)
+		return &arm.Comments, true
+	})
+	if !parser.Punc(state, '}') {
+		return nil, false
+	}
+	return node, true
+}
+
+func parseDecl(state *parser.RuneParserState) (Decl, bool) {
+	begin := state.Position()
+	if d, ok := parseStructDecl(state); ok {
+		return d, true
+	}
+	state.Recover(begin)
+	if d, ok := parseEnumDecl(state); ok {
+		return d, true
+	}
+	state.Recover(begin)
+	if d, ok := parseUnionDecl(state); ok {
+		return d, true
+	}
+	state.Recover(begin)
+	state.Expected("declaration")
+	return nil, false
+}
+
+func parseRegionDecl(state *parser.RuneParserState) (*RegionDecl, bool) {
+	if !keyword(state, "region") {
+		return nil, false
+	}
+	s(state)
+	name, ok := parser.Identifier(state)
+	if !ok {
+		return nil, false
+	}
+	s(state)
+	if !parser.Punc(state, '{') {
+		return nil, false
+	}
+	node := &RegionDecl{Name: name}
+	node.Dangling = parseSequence(state, func(state *parser.RuneParserState) (*parser.Comments, bool) {
+		d, ok := parseDecl(state)
+		if !ok {
+			return nil, false
+		}
+		node.Decls = append(node.Decls, d)
+		return d.Attached(), true
+	})
+	if !parser.Punc(state, '}') {
+		return nil, false
+	}
+	return node, true
+}
+
+// Parse schema definition language text into an AST.
+func ParseSchemaFile(info *parser.SourceInfo, input []byte, status *parser.Status) *SchemaFile {
+	state := parser.CreateRuneParser(info, input)
+	file := &SchemaFile{}
+	file.Dangling = parseSequence(state, func(state *parser.RuneParserState) (*parser.Comments, bool) {
+		r, ok := parseRegionDecl(state)
+		if !ok {
+			return nil, false
+		}
+		file.Regions = append(file.Regions, r)
+		return &r.Comments, true
+	})
+	if !state.IsEndOfStream() {
+		state.Fail()
+	}
+	state.ReportErrors(status)
+	return file
+}
@@ -0,0 +1,551 @@
+package schema
+
+import (
+	"github.com/ncbray/rommy/parser"
+	"github.com/ncbray/rommy/runtime"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+)
+
+const typeDeclDefinition = `// The schema of schemas.
+region TypeDecl {
+  struct Field {
+    name: string;
+    type: string;
+    default: string;
+    aliases: []string;
+    deprecated: bool;
+    min: string;
+    max: string;
+    min_length: int32;
+    max_length: int32;
+    pattern: string;
+    non_empty: bool;
+    unique: bool;
+    key: bool;
+    doc: string;
+  }
+
+  struct Struct {
+    name: string;
+    fields: []Field; // In declaration order.
+    doc: string;
+  }
+
+  struct Enum {
+    name: string;
+    values: []string;
+  }
+
+  struct Union {
+    name: string;
+    arms: []string;
+  }
+
+  struct Region {
+    name: string;
+    struct: [] Struct;
+    enum: []Enum;
+    union: []Union;
+    doc: string;
+  }
+
+  struct Schemas {
+    region: []Region;
+  }
+}
+`
+
+func describe(regions []*runtime.RegionSchema) []string {
+	out := []string{}
+	for _, r := range regions {
+		out = append(out, "region "+r.Name)
+		for _, e := range r.Enums {
+			out = append(out, "enum "+e.Name+" "+strings.Join(e.Values, ", "))
+		}
+		for _, s := range r.Structs {
+			out = append(out, "struct "+s.Name)
+			for _, f := range s.Fields {
+				out = append(out, f.Name+": "+f.Type.CanonicalName())
+			}
+		}
+	}
+	return out
+}
+
+func resolve(t *testing.T, schemas *Schemas) []*runtime.RegionSchema {
+	regions, ok := Resolve(schemas, nil, &parser.Status{})
+	assert.True(t, ok)
+	return regions
+}
+
+func TestDefinitionsMatchData(t *testing.T) {
+	data, err := ioutil.ReadFile("schema.rommy")
+	assert.Nil(t, err)
+	_, expected, ok := ParseSchema("schema.rommy", data)
+	assert.True(t, ok)
+
+	_, actual, ok := ParseSchema("schema"+DefinitionExtension, []byte(typeDeclDefinition))
+	assert.True(t, ok)
+	assert.Equal(t, describe(resolve(t, expected)), describe(resolve(t, actual)))
+}
+
+func TestParseSchemaFile(t *testing.T) {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte(typeDeclDefinition)
+	info := sources.Add("t", data)
+	f := ParseSchemaFile(info, data, status)
+	assert.False(t, status.ShouldStop())
+	assert.Equal(t, 1, len(f.Regions))
+	r := f.Regions[0]
+	assert.Equal(t, "// The schema of schemas.", r.Leading[0].Raw.Text)
+	assert.Equal(t, 6, len(r.Decls))
+	s := r.Decls[1].(*StructDecl)
+	assert.Equal(t, "Struct", s.Name.Text)
+	assert.Equal(t, "// In declaration order.", s.Fields[1].Trailing[0].Raw.Text)
+	assert.Equal(t, "[]Field", typeString(s.Fields[1].Type))
+}
+
+func TestParseEnumDecl(t *testing.T) {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte(`region R {
+  enum Element {
+    fire, // Hot.
+    water
+  }
+}`)
+	info := sources.Add("t", data)
+	f := ParseSchemaFile(info, data, status)
+	assert.False(t, status.ShouldStop())
+	e := f.Regions[0].Decls[0].(*EnumDecl)
+	assert.Equal(t, "Element", e.Name.Text)
+	assert.Equal(t, 2, len(e.Values))
+	assert.Equal(t, "// Hot.", e.Values[0].Trailing[0].Raw.Text)
+	assert.Equal(t, "water", e.Values[1].Name.Text)
+}
+
+func TestParseMapType(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`region R {
+  enum E { a }
+  struct S {
+    by_name: map[string]S;
+    by_enum: map [E] []int32;
+  }
+}`))
+	assert.True(t, ok)
+	fields := schemas.Region[0].Struct[0].Fields
+	assert.Equal(t, "map[string]S", fields[0].Type)
+	assert.Equal(t, "map[E][]int32", fields[1].Type)
+	s := resolve(t, schemas)[0].Structs[0]
+	assert.Equal(t, "map[E][]int32", s.Fields[1].Type.CanonicalName())
+}
+
+func TestParseArrayType(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`region R {
+  struct S {
+    palette: [16]uint16;
+    corners: [ 4 ] ?S;
+    mask: bytes;
+  }
+}`))
+	assert.True(t, ok)
+	fields := schemas.Region[0].Struct[0].Fields
+	assert.Equal(t, "[16]uint16", fields[0].Type)
+	assert.Equal(t, "[4]?S", fields[1].Type)
+	s := resolve(t, schemas)[0].Structs[0]
+	assert.Equal(t, 16, s.Fields[0].Type.(*runtime.ArraySchema).Length)
+	assert.Equal(t, "[4]?S", s.Fields[1].Type.CanonicalName())
+	assert.Equal(t, "bytes", s.Fields[2].Type.CanonicalName())
+}
+
+func TestParseOptionalType(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`region R {
+  struct S {
+    next: ?S;
+    siblings: []? S;
+  }
+}`))
+	assert.True(t, ok)
+	s := resolve(t, schemas)[0].Structs[0]
+	assert.Equal(t, "?S", s.Fields[0].Type.CanonicalName())
+	assert.Equal(t, "[]?S", s.Fields[1].Type.CanonicalName())
+}
+
+func TestParseDefaults(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`region R {
+  enum E { a, b }
+  struct S {
+    durability: int32 = 1_00;
+    weight: float32 = 1;
+    name: string = "x"; // Comment.
+    e: E = b;
+  }
+}`))
+	assert.True(t, ok)
+	fields := schemas.Region[0].Struct[0].Fields
+	assert.Equal(t, "1_00", fields[0].Default)
+	assert.Equal(t, "\"x\"", fields[2].Default)
+	s := resolve(t, schemas)[0].Structs[0]
+	assert.Equal(t, int64(100), s.Fields[0].Default)
+	assert.Equal(t, float64(1), s.Fields[1].Default)
+	assert.Equal(t, "x", s.Fields[2].Default)
+	assert.Equal(t, 1, s.Fields[3].Default)
+}
+
+func TestParseUnionDecl(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`region R {
+  struct A {}
+  struct B {}
+  union U {
+    A, // First.
+    B
+  }
+  struct S {
+    u: U;
+    maybe: ?U;
+  }
+}`))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"A", "B"}, schemas.Region[0].Union[0].Arms)
+	r := resolve(t, schemas)[0]
+	u := r.Unions[0]
+	assert.Equal(t, "U", u.Name)
+	assert.Equal(t, 1, u.Tag(r.Structs[1]))
+	s := r.Structs[2]
+	assert.Equal(t, u, s.Fields[0].Type)
+	assert.Equal(t, "?U", s.Fields[1].Type.CanonicalName())
+}
+
+func TestParseFieldAttributes(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`region R {
+  struct S {
+    max_health: int32 = 10 [alias = hp, alias = ["health", life]];
+    legacy: bool [deprecated,];
+    plain: bool [];
+  }
+}`))
+	assert.True(t, ok)
+	fields := schemas.Region[0].Struct[0].Fields
+	assert.Equal(t, []string{"hp", "health", "life"}, fields[0].Aliases)
+	assert.True(t, fields[1].Deprecated)
+	s := resolve(t, schemas)[0].Structs[0]
+	assert.Equal(t, s.Fields[0], s.FieldLUT["life"])
+	assert.Equal(t, []string{"hp", "health", "life"}, s.Fields[0].Aliases)
+	assert.False(t, s.Fields[0].Deprecated)
+	assert.True(t, s.Fields[1].Deprecated)
+}
+
+func TestFieldAttributeErrors(t *testing.T) {
+	for _, text := range []string{
+		"region R { struct A { x: int32 [alias]; } }",
+		"region R { struct A { x: int32 [alias = 1]; } }",
+		"region R { struct A { x: int32 [deprecated = true]; } }",
+		"region R { struct A { x: int32 [color = red]; } }",
+		"region R { struct A { x: int32 [deprecated; } }",
+		"region R { struct A { x: int32 [= 1]; } }",
+		"region R { struct A { x: int32 [alias = y]; y: int32; } }",
+		"region R { struct A { x: int32 [alias = z]; y: int32 [alias = z]; } }",
+	} {
+		status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+		_, ok := LoadSchema("t"+DefinitionExtension, []byte(text), status)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestParseConstraints(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`region R {
+  enum Kind { a, b }
+  struct S {
+    level: uint8 [min = 1, max = 99];
+    scale: float32 [min = -0.5];
+    name: string [min_length = 1, max_length = 16, pattern = "[a-z]+", unique];
+    tags: []string [non_empty];
+    kind: Kind [unique];
+  }
+}`))
+	assert.True(t, ok)
+	fields := schemas.Region[0].Struct[0].Fields
+	assert.Equal(t, "1", fields[0].Min)
+	assert.Equal(t, "99", fields[0].Max)
+	assert.Equal(t, int32(16), fields[2].MaxLength)
+	s := resolve(t, schemas)[0].Structs[0]
+	assert.Equal(t, &runtime.Constraints{Min: uint64(1), Max: uint64(99)}, s.Fields[0].Constraints)
+	assert.Equal(t, &runtime.Constraints{Min: float64(-0.5)}, s.Fields[1].Constraints)
+	assert.Equal(t, &runtime.Constraints{MinLength: 1, MaxLength: 16, Pattern: "[a-z]+", Unique: true}, s.Fields[2].Constraints)
+	assert.Equal(t, &runtime.Constraints{NonEmpty: true}, s.Fields[3].Constraints)
+	assert.Equal(t, &runtime.Constraints{Unique: true}, s.Fields[4].Constraints)
+}
+
+func TestConstraintErrors(t *testing.T) {
+	for _, text := range []string{
+		"region R { struct A { x: int32 [min]; } }",
+		`region R { struct A { x: int32 [min = "1"]; } }`,
+		"region R { struct A { x: uint8 [max = 256]; } }",
+		"region R { struct A { x: int32 [min = 2, max = 1]; } }",
+		"region R { struct A { x: string [min = 1]; } }",
+		"region R { struct A { x: int32 [min_length = 1]; } }",
+		"region R { struct A { x: string [min_length = -1]; } }",
+		"region R { struct A { x: string [min_length = 3, max_length = 2]; } }",
+		"region R { struct A { x: string [max_length]; } }",
+		"region R { struct A { x: string [pattern = abc]; } }",
+		`region R { struct A { x: string [pattern = "("]; } }`,
+		`region R { struct A { x: int32 [pattern = "a"]; } }`,
+		"region R { struct A { x: int32 [non_empty]; } }",
+		"region R { struct A { x: []int32 [non_empty = true]; } }",
+		"region R { struct A { x: []int32 [unique]; } }",
+	} {
+		status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+		_, ok := LoadSchema("t"+DefinitionExtension, []byte(text), status)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`region R {
+  struct Item {
+    id: string [key];
+    count: int32;
+  }
+  struct Level {
+    number: uint16 [key];
+  }
+  struct Note {
+    text: string;
+  }
+}`))
+	assert.True(t, ok)
+	assert.True(t, schemas.Region[0].Struct[0].Fields[0].Key)
+	r := resolve(t, schemas)[0]
+	assert.Equal(t, r.Structs[0].Fields[0], r.Structs[0].Key)
+	assert.Equal(t, r.Structs[1].Fields[0], r.Structs[1].Key)
+	assert.Nil(t, r.Structs[2].Key)
+}
+
+func TestKeyErrors(t *testing.T) {
+	for _, text := range []string{
+		"region R { struct A { x: string [key]; y: string [key]; } }",
+		"region R { struct A { x: bool [key]; } }",
+		"region R { struct A { x: []string [key]; } }",
+		"region R { struct A { x: string [key = true]; } }",
+		"region R { struct Schema { x: string [key]; } }",
+		"region R { struct UnmarshalBinary { x: string [key]; } }",
+		"region R { struct A {} struct AllocateA { x: string [key]; } }",
+		"region R { struct A {} struct APool { x: string [key]; } }",
+	} {
+		status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+		_, ok := LoadSchema("t"+DefinitionExtension, []byte(text), status)
+		assert.False(t, ok, text)
+	}
+
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+	LoadSchema("t"+DefinitionExtension, []byte("region R { struct Schema { id: string [key]; } }"), status)
+	assert.Equal(t, []string{"Schema cannot have a key field, the lookup method would conflict with the method Schema on the generated region"}, sink.Messages())
+
+	// Without a key there is no lookup method.
+	_, _, ok := ParseSchema("t"+DefinitionExtension, []byte("region R { struct Schema { id: string; } }"))
+	assert.True(t, ok)
+}
+
+func TestDefinitionErrors(t *testing.T) {
+	for _, text := range []string{
+		"region R { struct A { x: int32 } }",
+		"region R { struct A { x int32; } }",
+		"region R { class A {} }",
+		"region R { enum E { a b } }",
+		"region R { struct A { x: map[string]; } }",
+		"region R { struct A { x: int32 = ; } }",
+		"region R { struct A { x: [-1]int32; } }",
+	} {
+		_, _, ok := ParseSchema("t"+DefinitionExtension, []byte(text))
+		assert.False(t, ok, text)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	for _, text := range []string{
+		`Schemas {region: [{name: "R", struct: [{name: "A", fields: [{name: "x", type: "Missing"}]}]}]}`,
+		`Schemas {region: [{name: "R", struct: [{name: "A"}, {name: "A"}]}]}`,
+		`Schemas {region: [{name: "R", struct: [{name: "A", fields: [{name: "x", type: "int32"}, {name: "x", type: "bool"}]}]}]}`,
+		`Schemas {region: [{name: "R", struct: [{name: "int32"}]}]}`,
+		`Schemas {region: [{name: "R", struct: [{name: "A"}], enum: [{name: "A", values: ["a"]}]}]}`,
+		`Schemas {region: [{name: "R", enum: [{name: "E", values: ["a", "a"]}]}]}`,
+		`Schemas {region: [{name: "R", enum: [{name: "E"}]}]}`,
+		`Schemas {region: [{name: "R", union: [{name: "U"}]}]}`,
+		`Schemas {region: [{name: "R", union: [{name: "U", arms: ["string"]}]}]}`,
+		`Schemas {region: [{name: "R", struct: [{name: "A", fields: [{name: "x", type: "uint8", default: "256"}]}]}]}`,
+		`Schemas {region: [{name: "R"}, {name: "R"}]}`,
+		`Region {name: "R"}`,
+	} {
+		status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+		_, ok := LoadSchema("t.rommy", []byte(text), status)
+		assert.False(t, ok, text)
+		assert.True(t, status.ShouldStop(), text)
+	}
+}
+
+func TestResolveDefinitionErrors(t *testing.T) {
+	for _, text := range []string{
+		"region R { struct A {} struct A {} }",
+		"region R { struct A { x: int32; x: bool; } }",
+		"region R { enum string { a } }",
+		"region R { enum E { a, a } }",
+		"region R { struct A {} enum A { a } }",
+		"region R { enum E { } }",
+		"region R { union U { } }",
+		"region R { struct A { x: Missing; } }",
+		"region R { struct A { x: []B; } }",
+		"region R { struct A { x: map[A]int32; } }",
+		"region R { struct A { x: map[float32]int32; } }",
+		"region R { struct A { x: map[[]string]int32; } }",
+		"region R { struct A { x: ?int32; } }",
+		"region R { struct A { x: ?[]A; } }",
+		"region R { struct A { x: int32 = \"a\"; } }",
+		"region R { struct A { x: uint8 = 256; } }",
+		"region R { struct A { x: []int32 = 1; } }",
+		"region R { struct A { x: A = {}; } }",
+		"region R { enum E { a } struct A { x: E = b; } }",
+		"region R { struct A { x: [0]int32; } }",
+		"region R { struct A { x: [99999999999999999999]int32; } }",
+		"region R { struct A { x: [2]Missing; } }",
+		"region R { struct A { x: map[bytes]int32; } }",
+		"region R { struct A { x: bytes = x\"00\"; } }",
+		"region R { union U { Missing } }",
+		"region R { enum E { a } union U { E } }",
+		"region R { struct A {} union U { A, A } }",
+		"region R { struct A {} union U { A } struct B { x: U = {}; } }",
+	} {
+		// These are only caught when resolving.
+		_, _, ok := ParseSchema("t"+DefinitionExtension, []byte(text))
+		assert.True(t, ok, text)
+
+		status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+		_, ok = LoadSchema("t"+DefinitionExtension, []byte(text), status)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestResolveDefinitionMessages(t *testing.T) {
+	for _, c := range []struct {
+		text    string
+		message string
+	}{
+		{"region R { struct int32 {} struct A { n: int32 = 3; } }", `cannot redefine built-in type "int32"`},
+		{"region R { struct A { x: [2]Missing; } }", `cannot resolve type "Missing"`},
+		{"region R { struct A { x: [0]int32; } }", "invalid array length 0"},
+		{"region R { struct A { x: ?[]A; } }", "cannot make []A optional, only references may be absent"},
+		{"region R { struct A { x: map[[]string]int32; } }", "cannot use []string as a map key"},
+		{"region R { struct A { x: map[string]map[A]int32; } }", "cannot use A as a map key"},
+		{"region R { struct A {} union U { A, A } }", `"A" is already an arm of U`},
+	} {
+		sink := &parser.MemorySink{}
+		status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+		_, ok := LoadSchema("t"+DefinitionExtension, []byte(c.text), status)
+		assert.False(t, ok, c.text)
+		assert.Equal(t, []string{c.message}, sink.Messages(), c.text)
+	}
+}
+
+func TestSpecialFloatNamesAsEnumValues(t *testing.T) {
+	status := parser.CreateStatus(parser.CreateSourceSet(), &parser.MemorySink{})
+	regions, ok := LoadSchema("t"+DefinitionExtension, []byte(`region R {
+  enum E { inf, nan }
+  struct A {
+    e: E = nan;
+    f: float32 = nan;
+    g: float64 = inf;
+  }
+}`), status)
+	assert.True(t, ok)
+	fields := regions[0].Structs[0].Fields
+	assert.Equal(t, 1, fields[0].Default)
+	assert.True(t, math.IsNaN(fields[1].Default.(float64)))
+	assert.True(t, math.IsInf(fields[2].Default.(float64), 1))
+}
+
+func TestEmptyDeclarations(t *testing.T) {
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+	_, ok := LoadSchema("t"+DefinitionExtension, []byte(`region R {
+  enum E {}
+  union U {}
+}`), status)
+	assert.False(t, ok)
+	assert.Equal(t, []string{"enum E has no values", "union U has no arms"}, sink.Messages())
+	assert.Equal(t, 2, sink.Diagnostics[0].Span.Begin.Line)
+	assert.Equal(t, 3, sink.Diagnostics[1].Span.Begin.Line)
+}
+
+func TestResolveReportsEverything(t *testing.T) {
+	text := `Schemas {region: [{name: "R", struct: [
+  {name: "A", fields: [{name: "x", type: "Missing"}, {name: "x", type: "uint8", default: "256"}]},
+  {name: "A"},
+]}]}`
+	sink := &parser.MemorySink{}
+	status := parser.CreateStatus(parser.CreateSourceSet(), sink)
+	_, ok := LoadSchema("t.rommy", []byte(text), status)
+	assert.False(t, ok)
+	assert.Equal(t, []string{
+		`type "A" is already defined`,
+		`cannot resolve type "Missing"`,
+		`A has more than one field named "x"`,
+		`invalid default 256 for type uint8, 256 out of range for an uint8, expected 0 to 255`,
+	}, sink.Messages())
+	dup := sink.Diagnostics[0]
+	assert.Equal(t, 3, dup.Span.Begin.Line)
+	assert.Equal(t, "previous definition", dup.Related[0].Message)
+	assert.Equal(t, 2, dup.Related[0].Span.Begin.Line)
+	assert.Equal(t, 2, sink.Diagnostics[1].Span.Begin.Line)
+}
+
+func TestParseDocs(t *testing.T) {
+	_, schemas, ok := ParseSchema("t"+DefinitionExtension, []byte(`// Copyright header, not documentation.
+
+/// Region doc.
+region R {
+  /**
+   * Struct doc.
+   *
+   * Second paragraph.
+   */
+  struct A { /// Not the doc for x.
+    x: int32;
+    /// y doc
+    y: int32; /// Ignored after a leading doc.
+    z: int32; /// z doc
+    // Not a doc comment.
+    w: int32;
+    /// Separated by a blank line.
+
+    v: int32;
+  }
+  /** B doc. */
+  struct B {}
+}`))
+	assert.True(t, ok)
+	r := resolve(t, schemas)[0]
+	assert.Equal(t, "Region doc.", r.Doc)
+	assert.Equal(t, "Struct doc.\n\nSecond paragraph.", r.Structs[0].Doc)
+	docs := []string{}
+	for _, f := range r.Structs[0].Fields {
+		docs = append(docs, f.Doc)
+	}
+	assert.Equal(t, []string{"", "y doc", "z doc", "", ""}, docs)
+	assert.Equal(t, "B doc.", r.Structs[1].Doc)
+
+	// A header comment is not the doc of the first region.
+	_, schemas, ok = ParseSchema("t"+DefinitionExtension, []byte(`/// Header.
+
+region R {}`))
+	assert.True(t, ok)
+	assert.Equal(t, "", resolve(t, schemas)[0].Doc)
+	_, schemas, ok = ParseSchema("t"+DefinitionExtension, []byte(`/// Region doc.
+region R {}`))
+	assert.True(t, ok)
+	assert.Equal(t, "Region doc.", resolve(t, schemas)[0].Doc)
+}
@@ -0,0 +1,181 @@
+package schema
+
+import (
+	"github.com/ncbray/compilerutil/writer"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+type schemaWriter struct {
+	style human.Style
+	out   *writer.TabbedWriter
+}
+
+func hasComments(c *parser.Comments) bool {
+	return len(c.Leading) > 0 || len(c.Trailing) > 0
+}
+
+func (w *schemaWriter) writeClose(c *parser.Comments) {
+	w.out.WriteString("}")
+	parser.EndLineWithComments(c.Trailing, w.out)
+}
+
+// Attribute values are written on one line, even lists.
+func attributeString(e human.Expr) string {
+	if l, ok := e.(*human.List); ok {
+		elements := make([]string, len(l.Args))
+		for i, arg := range l.Args {
+			elements[i] = attributeString(arg)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	}
+	return defaultString(e)
+}
+
+func (w *schemaWriter) writeStruct(d *StructDecl) {
+	out := w.out
+	out.WriteString("struct ")
+	out.WriteString(d.Name.Text)
+	if len(d.Fields) == 0 && len(d.Dangling) == 0 {
+		out.WriteString(" {")
+		w.writeClose(&d.Comments)
+		return
+	}
+	out.WriteLine(" {")
+	out.Indent()
+	for _, f := range d.Fields {
+		parser.WriteLeadingComments(f.Leading, out)
+		out.WriteString(f.Name.Text)
+		out.WriteString(": ")
+		out.WriteString(typeString(f.Type))
+		if f.Default != nil {
+			out.WriteString(" = ")
+			out.WriteString(defaultString(f.Default))
+		}
+		if len(f.Attributes) > 0 {
+			out.WriteString(" [")
+			for i, a := range f.Attributes {
+				if i > 0 {
+					out.WriteString(", ")
+				}
+				out.WriteString(a.Name.Text)
+				if a.Value != nil {
+					out.WriteString(" = ")
+					out.WriteString(attributeString(a.Value))
+				}
+			}
+			out.WriteString("]")
+		}
+		out.WriteString(";")
+		parser.EndLineWithComments(f.Trailing, out)
+	}
+	parser.WriteLeadingComments(d.Dangling, out)
+	out.Dedent()
+	w.writeClose(&d.Comments)
+}
+
+// Write the names of enum values or union arms, on one line if there are no
+// comments and they fit.
+func (w *schemaWriter) writeNames(keyword string, name string, names []*parser.Comments, text []string, dangling []*parser.Comment) {
+	out := w.out
+	out.WriteString(keyword)
+	out.WriteString(" ")
+	out.WriteString(name)
+	one_line := len(dangling) == 0
+	for _, c := range names {
+		if hasComments(c) {
+			one_line = false
+		}
+	}
+	if one_line {
+		line := " {}"
+		if len(text) > 0 {
+			line = " { " + strings.Join(text, ", ") + " }"
+		}
+		// Declarations are indented once, inside a region.
+		width := utf8.RuneCountInString(w.style.Indent + keyword + " " + name + line)
+		if w.style.Width <= 0 || width <= w.style.Width {
+			out.WriteString(line)
+			return
+		}
+	}
+	out.WriteLine(" {")
+	out.Indent()
+	for i, c := range names {
+		parser.WriteLeadingComments(c.Leading, out)
+		out.WriteString(text[i])
+		out.WriteString(",")
+		parser.EndLineWithComments(c.Trailing, out)
+	}
+	parser.WriteLeadingComments(dangling, out)
+	out.Dedent()
+	out.WriteString("}")
+}
+
+func (w *schemaWriter) writeDecl(d Decl) {
+	switch d := d.(type) {
+	case *StructDecl:
+		w.writeStruct(d)
+	case *EnumDecl:
+		names := make([]*parser.Comments, len(d.Values))
+		text := make([]string, len(d.Values))
+		for i, v := range d.Values {
+			names[i] = &v.Comments
+			text[i] = v.Name.Text
+		}
+		w.writeNames("enum", d.Name.Text, names, text, d.Dangling)
+		parser.EndLineWithComments(d.Trailing, w.out)
+	case *UnionDecl:
+		names := make([]*parser.Comments, len(d.Arms))
+		text := make([]string, len(d.Arms))
+		for i, a := range d.Arms {
+			names[i] = &a.Comments
+			text[i] = a.Name.Text
+		}
+		w.writeNames("union", d.Name.Text, names, text, d.Dangling)
+		parser.EndLineWithComments(d.Trailing, w.out)
+	default:
+		panic(d)
+	}
+}
+
+func (w *schemaWriter) writeRegion(r *RegionDecl) {
+	out := w.out
+	parser.WriteLeadingComments(r.Leading, out)
+	out.WriteString("region ")
+	out.WriteString(r.Name.Text)
+	if len(r.Decls) == 0 && len(r.Dangling) == 0 {
+		out.WriteString(" {")
+		w.writeClose(&r.Comments)
+		return
+	}
+	out.WriteLine(" {")
+	out.Indent()
+	for i, d := range r.Decls {
+		if i > 0 {
+			out.EndOfLine()
+		}
+		parser.WriteLeadingComments(d.Attached().Leading, out)
+		w.writeDecl(d)
+	}
+	parser.WriteLeadingComments(r.Dangling, out)
+	out.Dedent()
+	w.writeClose(&r.Comments)
+}
+
+// Convert a schema definition AST back to text in the given style.  Comments
+// are kept and declarations are separated by blank lines, so formatting the
+// output again does not change it.
+func WriteSchemaFile(file *SchemaFile, style human.Style, w io.Writer) {
+	sw := &schemaWriter{style: style, out: writer.MakeTabbedWriter(style.Indent, w)}
+	for i, r := range file.Regions {
+		if i > 0 {
+			sw.out.EndOfLine()
+		}
+		sw.writeRegion(r)
+	}
+	parser.WriteLeadingComments(file.Dangling, sw.out)
+}
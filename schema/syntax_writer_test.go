@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"bytes"
+	"github.com/ncbray/rommy/human"
+	"github.com/ncbray/rommy/parser"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func formatSchema(t *testing.T, text string, style human.Style) string {
+	sources := parser.CreateSourceSet()
+	status := parser.CreateStatus(sources, &parser.MemorySink{})
+	data := []byte(text)
+	info := sources.Add("t", data)
+	f := ParseSchemaFile(info, data, status)
+	assert.False(t, status.ShouldStop())
+	var out bytes.Buffer
+	WriteSchemaFile(f, style, &out)
+	return out.String()
+}
+
+func TestWriteSchemaFile(t *testing.T) {
+	expected := strings.Replace(typeDeclDefinition, "[] Struct", "[]Struct", 1)
+	assert.Equal(t, expected, formatSchema(t, typeDeclDefinition, human.DefaultStyle))
+	assert.Equal(t, expected, formatSchema(t, expected, human.DefaultStyle))
+}
+
+func TestWriteSchemaDecls(t *testing.T) {
+	text := `region R {
+struct Empty {}
+  enum E {a,b,}
+  union U {
+    A, // First.
+    B
+  }
+  struct S { n: int32=3 [ alias=[m,"o"] ,deprecated]; e: E = a; // Trailing.
+  // Dangling.
+  }
+} // End.
+region Other {}
+`
+	expected := `region R {
+  struct Empty {}
+
+  enum E { a, b }
+
+  union U {
+    A, // First.
+    B,
+  }
+
+  struct S {
+    n: int32 = 3 [alias = [m, "o"], deprecated];
+    e: E = a; // Trailing.
+    // Dangling.
+  }
+} // End.
+
+region Other {}
+`
+	assert.Equal(t, expected, formatSchema(t, text, human.DefaultStyle))
+	assert.Equal(t, expected, formatSchema(t, expected, human.DefaultStyle))
+
+	narrow := human.Style{Indent: "\t", Width: 12}
+	assert.Equal(t, "region R {\n\tenum E {\n\t\tfire,\n\t\twater,\n\t}\n}\n", formatSchema(t, "region R { enum E { fire, water } }", narrow))
+}